@@ -1,6 +1,10 @@
 package api
 
-import "time"
+import (
+	"time"
+
+	"github.com/goadapp/goad/goad/histogram"
+)
 
 // RunnerResult defines the common API for goad runners to send data back to the
 // cli.
@@ -19,4 +23,130 @@ type RunnerResult struct {
 	ConnectionErrors int            `json:"connection-errors"`
 	RequestCount     int            `json:"request-count"`
 	TimedOut         int            `json:"timed-out"`
+	Dropped          int            `json:"dropped"`
+	Late             int            `json:"late"`
+
+	TimeToFirstHistogram *histogram.Histogram `json:"time-to-first-histogram"`
+	TimeForReqHistogram  *histogram.Histogram `json:"time-for-req-histogram"`
+
+	Breakdown map[string]*RequestStats `json:"breakdown,omitempty"`
+	Phases    Phases                   `json:"phases"`
+
+	// FailedAssertions counts the failures of every assertion by its spec,
+	// FailedAssertionRequests the requests with a status below 400 which
+	// failed at least one assertion and therefore count as error.
+	FailedAssertions        map[string]int `json:"failed-assertions,omitempty"`
+	FailedAssertionRequests int            `json:"failed-assertion-requests"`
+
+	// Forked is set on the last result of a runner that invoked a fork to
+	// continue the test before the function timeout
+	Forked bool `json:"forked"`
+}
+
+// PhaseStats sums up the durations of a connection phase over all requests
+// the phase occurred in, eg. DNS lookups only happen for new connections.
+type PhaseStats struct {
+	Total int64 `json:"total"`
+	Count int64 `json:"count"`
+}
+
+// Add records a single duration
+func (p *PhaseStats) Add(duration int64) {
+	p.Total += duration
+	p.Count++
+}
+
+// Mean returns the average duration of the phase
+func (p PhaseStats) Mean() int64 {
+	if p.Count == 0 {
+		return 0
+	}
+	return p.Total / p.Count
+}
+
+// Phases breaks the time spent on requests down into DNS lookup, TCP connect,
+// TLS handshake, server processing (request written until first response
+// byte) and transfer of the response body.
+type Phases struct {
+	DNS      PhaseStats `json:"dns"`
+	Connect  PhaseStats `json:"connect"`
+	TLS      PhaseStats `json:"tls"`
+	Server   PhaseStats `json:"server"`
+	Transfer PhaseStats `json:"transfer"`
+}
+
+// Merge adds the durations of other to p
+func (p *Phases) Merge(other Phases) {
+	for i, phase := range p.list() {
+		otherPhase := other.list()[i]
+		phase.Total += otherPhase.Total
+		phase.Count += otherPhase.Count
+	}
+}
+
+func (p *Phases) list() []*PhaseStats {
+	return []*PhaseStats{&p.DNS, &p.Connect, &p.TLS, &p.Server, &p.Transfer}
+}
+
+// RequestStats holds the counters kept per scenario step or named request
+type RequestStats struct {
+	RequestCount        int                  `json:"request-count"`
+	TimedOut            int                  `json:"timed-out"`
+	ConnectionErrors    int                  `json:"connection-errors"`
+	BytesRead           int                  `json:"bytes-read"`
+	Statuses            map[string]int       `json:"statuses"`
+	TimeForReqTotal     int64                `json:"time-for-req-total"`
+	TimeForReqHistogram *histogram.Histogram `json:"time-for-req-histogram"`
+
+	FailedAssertionRequests int `json:"failed-assertion-requests"`
+}
+
+// NewRequestStats creates empty RequestStats
+func NewRequestStats() *RequestStats {
+	return &RequestStats{
+		Statuses:            make(map[string]int),
+		TimeForReqHistogram: histogram.New(),
+	}
+}
+
+// Merge adds the counters of other to s
+func (s *RequestStats) Merge(other *RequestStats) {
+	if other == nil {
+		return
+	}
+	if s.Statuses == nil {
+		s.Statuses = make(map[string]int)
+	}
+	if s.TimeForReqHistogram == nil {
+		s.TimeForReqHistogram = histogram.New()
+	}
+	s.RequestCount += other.RequestCount
+	s.TimedOut += other.TimedOut
+	s.ConnectionErrors += other.ConnectionErrors
+	s.BytesRead += other.BytesRead
+	for key, value := range other.Statuses {
+		s.Statuses[key] += value
+	}
+	s.TimeForReqTotal += other.TimeForReqTotal
+	s.TimeForReqHistogram.Merge(other.TimeForReqHistogram)
+	s.FailedAssertionRequests += other.FailedAssertionRequests
+}
+
+// AveTimeForReq returns the mean duration of all successful requests
+func (s *RequestStats) AveTimeForReq() int64 {
+	countOk := int64(s.RequestCount - s.TimedOut - s.ConnectionErrors)
+	if countOk <= 0 {
+		return 0
+	}
+	return s.TimeForReqTotal / countOk
+}
+
+// MergeBreakdown adds the per name stats in other to breakdown
+func MergeBreakdown(breakdown map[string]*RequestStats, other map[string]*RequestStats) {
+	for name, stats := range other {
+		if _, ok := breakdown[name]; !ok {
+			breakdown[name] = NewRequestStats()
+		}
+		breakdown[name].Merge(stats)
+	}
 }
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goadapp/goad/goad/scenario"
+)
+
+// RunnerConfigVersion is increased with every incompatible change of
+// RunnerConfig, runners reject configurations of other versions
+const RunnerConfigVersion = 1
+
+// RunnerConfig is the payload every runner is invoked with, including the
+// runners forked to continue long running tests.
+type RunnerConfig struct {
+	Version int `json:"version"`
+
+	URL        string   `json:"url"`
+	Method     string   `json:"method"`
+	Body       string   `json:"body,omitempty"`
+	Headers    []string `json:"headers,omitempty"`
+	Assertions []string `json:"assertions,omitempty"`
+
+	RunnerID    int    `json:"runner-id"`
+	RunnerCount int    `json:"runner-count"`
+	Region      string `json:"region"`
+	QueueRegion string `json:"queue-region"`
+	ResultsURL  string `json:"results-url"`
+	ControlURL  string `json:"control-url,omitempty"`
+	// FunctionName is invoked to continue the test once FunctionTimeout (in
+	// seconds) is about to be reached
+	FunctionName    string `json:"function-name,omitempty"`
+	FunctionTimeout int    `json:"function-timeout,omitempty"`
+	// AWSEndpoint replaces the endpoints of AWS, eg. with a local emulator
+	AWSEndpoint string `json:"aws-endpoint,omitempty"`
+
+	Concurrency        int           `json:"concurrency"`
+	Requests           int           `json:"requests"`
+	CompletedRequests  int           `json:"completed-requests"`
+	ExecutionTime      int           `json:"execution-time"`
+	ClientTimeout      time.Duration `json:"client-timeout"`
+	ReportingFrequency time.Duration `json:"reporting-frequency"`
+	Rate               float64       `json:"rate,omitempty"`
+	// Stages are given in the "duration:target" notation
+	Stages         []string           `json:"stages,omitempty"`
+	StageOffset    time.Duration      `json:"stage-offset,omitempty"`
+	Scenario       *scenario.Scenario `json:"scenario,omitempty"`
+	RequestsFile   string             `json:"requests-file,omitempty"`
+	DataFile       string             `json:"data-file,omitempty"`
+	Feeder         string             `json:"feeder,omitempty"`
+	FeederPosition int                `json:"feeder-position,omitempty"`
+
+	AbortErrorRatio          float64 `json:"abort-error-ratio"`
+	AbortMinSamples          int     `json:"abort-min-samples"`
+	AbortConsecutiveTimeouts int     `json:"abort-consecutive-timeouts"`
+	AbortStatuses            []int   `json:"abort-statuses,omitempty"`
+}
+
+// NewRunnerConfig returns an empty configuration of the current version
+func NewRunnerConfig() RunnerConfig {
+	return RunnerConfig{Version: RunnerConfigVersion}
+}
+
+// DecodeRunnerConfig reads and validates a configuration, the version is
+// checked first so other versions are reported as such
+func DecodeRunnerConfig(payload []byte) (RunnerConfig, error) {
+	var versioned struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(payload, &versioned); err != nil {
+		return RunnerConfig{}, fmt.Errorf("invalid runner configuration: %s", err)
+	}
+	if versioned.Version != RunnerConfigVersion {
+		return RunnerConfig{}, fmt.Errorf("runner configuration version %d is not supported, this runner requires version %d, make sure goad and the deployed runner are of the same release", versioned.Version, RunnerConfigVersion)
+	}
+	config := RunnerConfig{}
+	if err := json.Unmarshal(payload, &config); err != nil {
+		return RunnerConfig{}, fmt.Errorf("invalid runner configuration: %s", err)
+	}
+	return config, config.Validate()
+}
+
+// Validate checks the configuration before a runner starts
+func (c RunnerConfig) Validate() error {
+	if c.Version != RunnerConfigVersion {
+		return fmt.Errorf("runner configuration version %d is not supported, this runner requires version %d", c.Version, RunnerConfigVersion)
+	}
+	if c.URL == "" {
+		return errors.New("no URL to load test")
+	}
+	if c.ResultsURL == "" {
+		return errors.New("no results URL")
+	}
+	if c.Concurrency < 0 || c.Requests < 0 || c.CompletedRequests < 0 || c.ExecutionTime < 0 {
+		return errors.New("concurrency, requests and execution time must not be negative")
+	}
+	if c.RunnerID < 0 || (c.RunnerCount > 0 && c.RunnerID >= c.RunnerCount) {
+		return fmt.Errorf("invalid runner id %d", c.RunnerID)
+	}
+	if c.AbortErrorRatio < 0 || c.AbortErrorRatio > 1 {
+		return errors.New("the abort error ratio must be between 0 and 1")
+	}
+	return nil
+}
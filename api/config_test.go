@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeRunnerConfig(t *testing.T) {
+	config := NewRunnerConfig()
+	config.URL = "http://example.com"
+	config.ResultsURL = "mem://results"
+	config.Headers = []string{"Authorization: Bearer secret"}
+	payload, _ := json.Marshal(config)
+
+	decoded, err := DecodeRunnerConfig(payload)
+	assert.NoError(t, err)
+	assert.Equal(t, config.Headers, decoded.Headers)
+}
+
+func TestDecodeRunnerConfigRejectsOtherVersions(t *testing.T) {
+	_, err := DecodeRunnerConfig([]byte(`{"version": 0, "args": ["--concurrency=10"]}`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "version 0 is not supported")
+	}
+	_, err = DecodeRunnerConfig([]byte(`{"version": 2, "url": 1}`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "version 2 is not supported")
+	}
+}
+
+func TestRunnerConfigValidate(t *testing.T) {
+	config := NewRunnerConfig()
+	config.ResultsURL = "mem://results"
+	assert.Error(t, config.Validate(), "the URL is required")
+
+	config.URL = "http://example.com"
+	assert.NoError(t, config.Validate())
+
+	config.AbortErrorRatio = 2
+	assert.Error(t, config.Validate())
+}
@@ -2,7 +2,7 @@ package cli
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -19,8 +20,19 @@ import (
 	ini "gopkg.in/ini.v1"
 
 	"github.com/dustin/go-humanize"
+	"github.com/goadapp/goad/api"
 	"github.com/goadapp/goad/goad"
+	"github.com/goadapp/goad/goad/compare"
+	"github.com/goadapp/goad/goad/export"
+	"github.com/goadapp/goad/goad/histogram"
+	"github.com/goadapp/goad/goad/report"
+	"github.com/goadapp/goad/goad/requestfile"
+	"github.com/goadapp/goad/goad/scenario"
+	"github.com/goadapp/goad/goad/templating"
+	"github.com/goadapp/goad/goad/threshold"
 	"github.com/goadapp/goad/goad/types"
+	"github.com/goadapp/goad/infrastructure"
+	"github.com/goadapp/goad/infrastructure/aws"
 	"github.com/goadapp/goad/result"
 	"github.com/goadapp/goad/version"
 	"github.com/nsf/termbox-go"
@@ -34,45 +46,188 @@ const (
 	methodKey      = "method"
 	bodyKey        = "body"
 	concurrencyKey = "concurrency"
+	rateKey        = "rate"
+	stageKey       = "stage"
+	stagesKey      = "stages"
 	requestsKey    = "requests"
 	timelimitKey   = "timelimit"
 	timeoutKey     = "timeout"
 	jsonOutputKey  = "json-output"
+	seriesKey      = "series-interval"
+	htmlReportKey  = "html-report"
 	headerKey      = "header"
-	regionKey      = "region"
-	writeIniKey    = "create-ini-template"
-	runDockerKey   = "run-docker"
+	assertKey      = "assert"
+	thresholdKey   = "threshold"
+	thresholdsKey  = "thresholds"
+
+	abortErrorRatioKey          = "abort-error-ratio"
+	abortMinSamplesKey          = "abort-min-samples"
+	abortConsecutiveTimeoutsKey = "abort-consecutive-timeouts"
+	abortStatusKey              = "abort-status"
+	abortStatusesKey            = "abort-statuses"
+	abortAllKey                 = "abort-all"
+	scenarioKey                 = "scenario"
+	requestsFileKey             = "requests-file"
+	dataFileKey                 = "data-file"
+	feederKey                   = "feeder"
+	regionKey                   = "region"
+	writeIniKey                 = "create-ini-template"
+	runDockerKey                = "run-docker"
+	runLocalKey                 = "run-local"
+	lambdaSection               = "lambda"
+	namespaceKey                = "namespace"
+	functionNameKey             = "function-name"
+	roleNameKey                 = "role-name"
+	memoryKey                   = "memory"
+	lambdaTimeoutKey            = "timeout"
+	architectureKey             = "architecture"
+	tagKey                      = "tag"
+	awsSection                  = "aws"
+	profileKey                  = "profile"
+	assumeRoleARNKey            = "assume-role-arn"
+	externalIDKey               = "external-id"
+	mfaSerialKey                = "mfa-serial"
+	endpointKey                 = "endpoint"
+	vpcSectionPrefix            = "vpc."
+	subnetsKey                  = "subnets"
+	securityGroupsKey           = "security-groups"
+	exportSection               = "export"
+	testIDKey                   = "test-id"
+	exportIntervalKey           = "interval"
+	influxDBURLKey              = "influxdb-url"
+	influxDBTokenKey            = "influxdb-token"
+	pushgatewayURLKey           = "pushgateway-url"
+	statsDAddressKey            = "statsd-address"
 )
 
 var (
 	iniFile         = "goad.ini"
 	app             = kingpin.New("goad", "An AWS Lambda powered load testing tool")
-	urlArg          = app.Arg(urlKey, "[http[s]://]hostname[:port]/path optional if defined in goad.ini")
+	runCmd          = app.Command("run", "Run a load test, the default command").Default()
+	urlArg          = runCmd.Arg(urlKey, "[http[s]://]hostname[:port]/path optional if defined in goad.ini")
 	url             = urlArg.String()
 	requestsFlag    = app.Flag(requestsKey, "Number of requests to perform. Set to 0 in combination with a specified timelimit allows for unlimited requests for the specified time.").Short('n').Default("1000")
 	requests        = requestsFlag.Int()
 	concurrencyFlag = app.Flag(concurrencyKey, "Number of multiple requests to make at a time").Short('c').Default("10")
 	concurrency     = concurrencyFlag.Int()
+	rateFlag        = app.Flag(rateKey, "Target request rate for an open workload model, eg. 100/s. Concurrency then limits the number of requests in flight")
+	rate            = rateFlag.String()
+	stagesFlag      = app.Flag(stageKey, "Load profile stage as duration:concurrency, eg. 2m:500. Concurrency ramps linearly towards the target of each stage (repeatable)")
+	stages          = stagesFlag.Strings()
 	timelimitFlag   = app.Flag(timelimitKey, "Seconds to max. to spend on benchmarking").Short('t').Default("3600")
 	timelimit       = timelimitFlag.Int()
 	timeoutFlag     = app.Flag(timeoutKey, "Seconds to max. wait for each response").Short('s').Default("15")
 	timeout         = timeoutFlag.Int()
 	headersFlag     = app.Flag(headerKey, "Add Arbitrary header line, eg. 'Accept-Encoding: gzip' (repeatable)").Short('H')
 	headers         = headersFlag.Strings()
-	methodFlag      = app.Flag(methodKey, "HTTP method").Short('m').Default("GET")
-	method          = methodFlag.String()
-	bodyFlag        = app.Flag(bodyKey, "HTTP request body\n\n")
-	body            = bodyFlag.String()
-	outputFileFlag  = app.Flag(jsonOutputKey, "Optional path to file for JSON result storage")
-	outputFile      = outputFileFlag.String()
-	regionsFlag     = app.Flag(regionKey, "AWS regions to run in. Repeat flag to run in more then one region. (repeatable)")
-	regions         = regionsFlag.Strings()
-	runDockerFlag   = app.Flag(runDockerKey, "execute in docker container instead of aws lambda")
-	runDocker       = runDockerFlag.Bool()
-	writeIniFlag    = app.Flag(writeIniKey, "create sample configuration file \""+iniFile+"\" in current working directory")
-	writeIni        = writeIniFlag.Bool()
+	assertFlag      = app.Flag(assertKey, "Check every response, failed checks count as errors: status:200,201 max-latency:500ms body-contains:text body-regex:expr json:$.path=value header:Name[=value] (repeatable)")
+	assertions      = assertFlag.Strings()
+	thresholdFlag   = app.Flag(thresholdKey, "Pass/fail criterion as [region:]metric<value, eg. p95<300ms, error_rate<1% or eu-west-1:rps>200. A trailing ! aborts the test once violated. Exits non-zero if a threshold fails (repeatable)")
+	thresholds      = thresholdFlag.Strings()
+
+	abortErrorRatioFlag          = app.Flag(abortErrorRatioKey, "Share of failed requests (0 - 1) at which a runner aborts, 0 disables the check").Default("0.5")
+	abortErrorRatio              = abortErrorRatioFlag.Float64()
+	abortMinSamplesFlag          = app.Flag(abortMinSamplesKey, "Requests a runner completes before checking the error ratio").Default("100")
+	abortMinSamples              = abortMinSamplesFlag.Int()
+	abortConsecutiveTimeoutsFlag = app.Flag(abortConsecutiveTimeoutsKey, "Number of timeouts in a row at which a runner aborts, 0 disables the check").Default("0")
+	abortConsecutiveTimeouts     = abortConsecutiveTimeoutsFlag.Int()
+	abortStatusFlag              = app.Flag(abortStatusKey, "Status code at which a runner aborts, eg. 429 or 503 (repeatable)")
+	abortStatuses                = abortStatusFlag.Strings()
+	abortAllFlag                 = app.Flag(abortAllKey, "Stop the whole test as soon as one runner aborts")
+	abortAll                     = abortAllFlag.Bool()
+	methodFlag                   = app.Flag(methodKey, "HTTP method").Short('m').Default("GET")
+	method                       = methodFlag.String()
+	bodyFlag                     = app.Flag(bodyKey, "HTTP request body\n\n")
+	body                         = bodyFlag.String()
+	scenarioFlag                 = app.Flag(scenarioKey, "Path to a JSON scenario file with the steps every worker executes, the url is used as base for relative step URLs")
+	scenarioFile                 = scenarioFlag.String()
+	requestsFileFlag             = app.Flag(requestsFileKey, "Path to a JSONL file with one request (name, method, url, headers, body, weight) per line, requests are picked by weight")
+	requestsFile                 = requestsFileFlag.String()
+	dataFileFlag                 = app.Flag(dataFileKey, "Path to a CSV file with a header line, its rows are available in request templates as {{csv \"column\"}}")
+	dataFile                     = dataFileFlag.String()
+	feederFlag                   = app.Flag(feederKey, "Strategy to feed rows of the data file: sequential, random or unique (rows partitioned between runners)").Default(templating.Sequential)
+	feeder                       = feederFlag.String()
+	outputFileFlag               = app.Flag(jsonOutputKey, "Optional path to file for JSON result storage")
+	outputFile                   = outputFileFlag.String()
+	htmlReportFlag               = app.Flag(htmlReportKey, "Optional path to a self-contained HTML report of the test, eg. to attach to a ticket")
+	htmlReport                   = htmlReportFlag.String()
+	seriesIntervalFlag           = app.Flag(seriesKey, "Seconds per interval of the time series of the results, at least the reporting frequency of the runners").Default(strconv.Itoa(int(result.DefaultSeriesInterval.Seconds())))
+	seriesInterval               = seriesIntervalFlag.Int()
+	regionsFlag                  = app.Flag(regionKey, "AWS regions to run in. Repeat flag to run in more then one region. (repeatable)")
+	regions                      = regionsFlag.Strings()
+	runDockerFlag                = app.Flag(runDockerKey, "execute in docker container instead of aws lambda")
+	runDocker                    = runDockerFlag.Bool()
+	runLocalFlag                 = app.Flag(runLocalKey, "execute the runners on this machine, eg. to try a configuration")
+	runLocal                     = runLocalFlag.Bool()
+	namespaceFlag                = app.Flag(namespaceKey, "Prefix of the names of all AWS resources goad creates, use a namespace per team to share an account").Default(types.DefaultFunctionConfig.Namespace)
+	namespace                    = namespaceFlag.String()
+	functionNameFlag             = app.Flag(functionNameKey, "Name of the lambda function, defaults to the namespace")
+	functionName                 = functionNameFlag.String()
+	roleNameFlag                 = app.Flag(roleNameKey, "Name of the IAM role of the lambda function, defaults to <namespace>-lambda-role")
+	roleName                     = roleNameFlag.String()
+	lambdaMemoryFlag             = app.Flag("lambda-memory", "Memory of the lambda function in MB").Default(strconv.Itoa(types.DefaultFunctionConfig.MemorySize))
+	lambdaMemory                 = lambdaMemoryFlag.Int()
+	lambdaTimeoutFlag            = app.Flag("lambda-timeout", "Timeout of a lambda invocation in seconds, longer tests are continued by a new invocation").Default(strconv.Itoa(types.DefaultFunctionConfig.Timeout))
+	lambdaTimeout                = lambdaTimeoutFlag.Int()
+	architectureFlag             = app.Flag(architectureKey, "Architecture of the lambda function: x86_64 or arm64").Default(types.DefaultFunctionConfig.Architecture)
+	architecture                 = architectureFlag.String()
+	tagFlag                      = app.Flag(tagKey, "Tag the AWS resources goad creates as key=value (repeatable)")
+	tags                         = tagFlag.Strings()
+	awsProfileFlag               = app.Flag("aws-"+profileKey, "Profile of the AWS credentials and config files to use")
+	awsProfile                   = awsProfileFlag.String()
+	assumeRoleARNFlag            = app.Flag(assumeRoleARNKey, "ARN of a role to assume with the AWS credentials, eg. to run in another account")
+	assumeRoleARN                = assumeRoleARNFlag.String()
+	externalIDFlag               = app.Flag(externalIDKey, "External id required by the role to assume")
+	externalID                   = externalIDFlag.String()
+	mfaSerialFlag                = app.Flag(mfaSerialKey, "Serial number or ARN of the MFA device required by the role to assume, the token is read from the terminal")
+	mfaSerial                    = mfaSerialFlag.String()
+	awsEndpointFlag              = app.Flag("aws-"+endpointKey, "Endpoint replacing the ones of all AWS services, eg. http://localhost:4566 for a local emulator")
+	awsEndpoint                  = awsEndpointFlag.String()
+	testIDFlag                   = app.Flag(testIDKey, "Id of the test in the exported metrics, defaults to the start time")
+	testID                       = testIDFlag.String()
+	exportIntervalFlag           = app.Flag("export-"+exportIntervalKey, "Seconds between the exports of the results to metrics systems").Default(strconv.Itoa(types.DefaultExportInterval))
+	exportInterval               = exportIntervalFlag.Int()
+	influxDBURLFlag              = app.Flag(influxDBURLKey, "Write endpoint of an InfluxDB to stream the results to, eg. http://localhost:8086/write?db=goad")
+	influxDBURL                  = influxDBURLFlag.String()
+	influxDBTokenFlag            = app.Flag(influxDBTokenKey, "Token for the InfluxDB write endpoint").Envar("GOAD_INFLUXDB_TOKEN")
+	influxDBToken                = influxDBTokenFlag.String()
+	pushgatewayURLFlag           = app.Flag(pushgatewayURLKey, "URL of a Prometheus Pushgateway to stream the results to, eg. http://localhost:9091")
+	pushgatewayURL               = pushgatewayURLFlag.String()
+	statsDAddressFlag            = app.Flag(statsDAddressKey, "host:port of a StatsD daemon to stream the results to, eg. localhost:8125")
+	statsDAddress                = statsDAddressFlag.String()
+	writeIniFlag                 = app.Flag(writeIniKey, "create sample configuration file \""+iniFile+"\" in current working directory")
+	writeIni                     = writeIniFlag.Bool()
+	cleanupCmd                   = app.Command("cleanup", "Delete the lambda functions and aliases, SQS queues and IAM role goad created for the namespace in all regions")
+	dryRun                       = cleanupCmd.Flag("dry-run", "Only list the resources that would be deleted").Bool()
+	reportCmd                    = app.Command("report", "Render a result document written with --"+jsonOutputKey+" again")
+	reportFile                   = reportCmd.Arg("file", "Path to the result document").Required().ExistingFile()
+	reportFormat                 = reportCmd.Flag("format", "Format of the report: summary, html, markdown, csv or json").Default(summaryFormat).Enum(summaryFormat, "html", "markdown", "csv", "json")
+	reportOutput                 = reportCmd.Flag("output", "Path to write the report to instead of the terminal").String()
+	diffCmd                      = app.Command("diff", "Compare the result documents of two tests, exits non-zero if the candidate regressed")
+	baselineFile                 = diffCmd.Arg("baseline", "Path to the result document of the baseline").Required().ExistingFile()
+	candidateFile                = diffCmd.Arg("candidate", "Path to the result document of the candidate").Required().ExistingFile()
+	latencyTolerance             = diffCmd.Flag("latency-tolerance", "Percent by which the mean and percentile latencies may increase").Default(prepareFloat(compare.DefaultTolerances.Latency)).Float64()
+	rpsTolerance                 = diffCmd.Flag("rps-tolerance", "Percent by which the requests per second may drop").Default(prepareFloat(compare.DefaultTolerances.Rate)).Float64()
+	errorRateTolerance           = diffCmd.Flag("error-rate-tolerance", "Percentage points by which the error rate may rise").Default(prepareFloat(compare.DefaultTolerances.ErrorRate)).Float64()
 )
 
+// thresholdsFailedExitCode is returned when at least one threshold failed
+const thresholdsFailedExitCode = 1
+
+// regressionExitCode is returned by goad diff if the candidate regressed
+const regressionExitCode = 1
+
+// summaryFormat prints a report like the summary after a test
+const summaryFormat = "summary"
+
+// reportWriters render a result document in the formats of goad report
+var reportWriters = map[string]func(io.Writer, *report.Document) error{
+	"html":     report.WriteHTML,
+	"markdown": report.WriteMarkdown,
+	"csv":      report.WriteCSV,
+	"json":     report.WriteJSON,
+}
+
 // Run the goad cli
 func Run() {
 	app.HelpFlag.Short('h')
@@ -86,10 +241,31 @@ func Run() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM) // but interrupts from kbd are blocked by termbox
 
-	result := start(config, sigChan)
-	defer printSummary(result)
+	criteria, err := threshold.ParseAll(config.Thresholds)
+	goad.HandleErr(err)
+
+	result, abortReason := start(config, criteria, sigChan)
+	doc := report.NewDocument(config, result, time.Now())
 	if config.Output != "" {
-		defer saveJSONSummary(*outputFile, result)
+		if err := writeReport(config.Output, doc, report.WriteJSON); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if config.HTMLReport != "" {
+		if err := writeReport(config.HTMLReport, doc, report.WriteHTML); err != nil {
+			fmt.Println(err)
+		}
+	}
+	printSummary(doc)
+	if abortReason != "" {
+		fmt.Printf("Test aborted, %s\n\n", abortReason)
+	}
+	if len(criteria) > 0 {
+		outcomes := threshold.Evaluate(criteria, result)
+		printThresholds(outcomes)
+		if !threshold.Passed(outcomes) {
+			os.Exit(thresholdsFailedExitCode)
+		}
 	}
 }
 
@@ -105,19 +281,35 @@ func writeConfigStream(writer io.Writer) {
 }
 
 func aggregateConfiguration() *types.TestConfig {
-	config := parseSettings()
+	config, err := parseSettings()
+	app.FatalIfError(err, "")
 	applyDefaultsFromConfig(config)
 	config = parseCommandline()
 	applyExtendedConfiguration(config)
+	config.ApplyStages()
 	return config
 }
 
 func applyDefaultsFromConfig(config *types.TestConfig) {
 	applyDefaultIfNotZero(bodyFlag, config.Body)
 	applyDefaultIfNotZero(concurrencyFlag, prepareInt(config.Concurrency))
+	applyDefaultIfNotZero(rateFlag, prepareFloat(config.Rate))
+	applyDefaultIfNotZero(stagesFlag, prepareStages(config.Stages))
 	applyDefaultIfNotZero(headersFlag, config.Headers)
+	applyDefaultIfNotZero(assertFlag, config.Assertions)
+	applyDefaultIfNotZero(thresholdFlag, config.Thresholds)
+	abortErrorRatioFlag.Default(strconv.FormatFloat(config.AbortPolicy.ErrorRatio, 'f', -1, 64))
+	abortMinSamplesFlag.Default(strconv.Itoa(config.AbortPolicy.MinSamples))
+	abortConsecutiveTimeoutsFlag.Default(strconv.Itoa(config.AbortPolicy.ConsecutiveTimeouts))
+	applyDefaultIfNotZero(abortStatusFlag, prepareStatuses(config.AbortPolicy.Statuses))
 	applyDefaultIfNotZero(methodFlag, config.Method)
 	applyDefaultIfNotZero(outputFileFlag, config.Output)
+	applyDefaultIfNotZero(htmlReportFlag, config.HTMLReport)
+	applyDefaultIfNotZero(seriesIntervalFlag, prepareInt(config.SeriesInterval))
+	applyDefaultIfNotZero(scenarioFlag, config.ScenarioFile)
+	applyDefaultIfNotZero(requestsFileFlag, config.RequestsFile)
+	applyDefaultIfNotZero(dataFileFlag, config.DataFile)
+	applyDefaultIfNotZero(feederFlag, config.Feeder)
 	applyDefaultIfNotZero(regionsFlag, config.Regions)
 	applyDefaultIfNotZero(requestsFlag, prepareInt(config.Requests))
 	applyDefaultIfNotZero(timelimitFlag, prepareInt(config.Timelimit))
@@ -131,6 +323,30 @@ func applyDefaultsFromConfig(config *types.TestConfig) {
 	if config.RunDocker {
 		runDockerFlag.Default("true")
 	}
+	if config.RunLocal {
+		runLocalFlag.Default("true")
+	}
+	if config.AbortAll {
+		abortAllFlag.Default("true")
+	}
+	namespaceFlag.Default(config.Function.Namespace)
+	applyDefaultIfNotZero(functionNameFlag, config.Function.Name)
+	applyDefaultIfNotZero(roleNameFlag, config.Function.Role)
+	lambdaMemoryFlag.Default(strconv.Itoa(config.Function.MemorySize))
+	lambdaTimeoutFlag.Default(strconv.Itoa(config.Function.Timeout))
+	architectureFlag.Default(config.Function.Architecture)
+	applyDefaultIfNotZero(tagFlag, prepareTags(config.Function.Tags))
+	applyDefaultIfNotZero(awsProfileFlag, config.AWS.Profile)
+	applyDefaultIfNotZero(assumeRoleARNFlag, config.AWS.AssumeRoleARN)
+	applyDefaultIfNotZero(externalIDFlag, config.AWS.ExternalID)
+	applyDefaultIfNotZero(mfaSerialFlag, config.AWS.MFASerial)
+	applyDefaultIfNotZero(awsEndpointFlag, config.AWS.Endpoint)
+	applyDefaultIfNotZero(testIDFlag, config.Export.TestID)
+	applyDefaultIfNotZero(exportIntervalFlag, prepareInt(config.Export.Interval))
+	applyDefaultIfNotZero(influxDBURLFlag, config.Export.InfluxDBURL)
+	applyDefaultIfNotZero(influxDBTokenFlag, config.Export.InfluxDBToken)
+	applyDefaultIfNotZero(pushgatewayURLFlag, config.Export.PushgatewayURL)
+	applyDefaultIfNotZero(statsDAddressFlag, config.Export.StatsDAddress)
 }
 
 func applyDefaultIfNotZero(flag *kingpin.FlagClause, def interface{}) {
@@ -156,6 +372,47 @@ func prepareInt(value int) string {
 	return strconv.Itoa(value)
 }
 
+func prepareFloat(value float64) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func prepareStages(stages []types.Stage) []string {
+	if len(stages) == 0 {
+		return nil
+	}
+	strs := make([]string, 0)
+	for _, stage := range stages {
+		strs = append(strs, stage.String())
+	}
+	return strs
+}
+
+func prepareTags(tags map[string]string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	strs := make([]string, 0)
+	for key, value := range tags {
+		strs = append(strs, key+"="+value)
+	}
+	sort.Strings(strs)
+	return strs
+}
+
+func prepareStatuses(statuses []int) []string {
+	if len(statuses) == 0 {
+		return nil
+	}
+	strs := make([]string, 0)
+	for _, status := range statuses {
+		strs = append(strs, strconv.Itoa(status))
+	}
+	return strs
+}
+
 func isNotZero(v reflect.Value) bool {
 	return !isZero(v)
 }
@@ -183,7 +440,7 @@ func isZero(v reflect.Value) bool {
 }
 
 func loadIni() *ini.File {
-	cfg, err := ini.LoadSources(ini.LoadOptions{AllowBooleanKeys: true}, iniFile)
+	cfg, err := ini.LoadSources(ini.LoadOptions{AllowBooleanKeys: true, AllowShadows: true}, iniFile)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			fmt.Println(err.Error())
@@ -193,23 +450,45 @@ func loadIni() *ini.File {
 	return cfg
 }
 
-func parseSettings() *types.TestConfig {
-	config := &types.TestConfig{}
+func parseSettings() (*types.TestConfig, error) {
+	config := &types.TestConfig{AbortPolicy: types.DefaultAbortPolicy, Function: types.DefaultFunctionConfig}
 	cfg := loadIni()
 	if cfg == nil {
-		return config
+		return config, nil
 	}
+	var err error
 
 	generalSection := cfg.Section(general)
 	config.URL = generalSection.Key(urlKey).String()
 	config.Method = generalSection.Key(methodKey).String()
 	config.Body = generalSection.Key(bodyKey).String()
 	config.Concurrency, _ = generalSection.Key(concurrencyKey).Int()
+	config.Rate, err = types.ParseRate(generalSection.Key(rateKey).String())
+	if err != nil {
+		return nil, err
+	}
+	config.Stages, err = types.ParseStages([]string{generalSection.Key(stagesKey).String()})
+	if err != nil {
+		return nil, err
+	}
 	config.Requests, _ = generalSection.Key(requestsKey).Int()
 	config.Timelimit, _ = generalSection.Key(timelimitKey).Int()
 	config.Timeout, _ = generalSection.Key(timeoutKey).Int()
 	config.Output = generalSection.Key(jsonOutputKey).String()
+	config.HTMLReport = generalSection.Key(htmlReportKey).String()
+	config.SeriesInterval, _ = generalSection.Key(seriesKey).Int()
+	config.ScenarioFile = generalSection.Key(scenarioKey).String()
+	config.RequestsFile = generalSection.Key(requestsFileKey).String()
+	config.DataFile = generalSection.Key(dataFileKey).String()
+	config.Feeder = generalSection.Key(feederKey).String()
 	config.RunDocker, _ = generalSection.Key(runDockerKey).Bool()
+	config.RunLocal, _ = generalSection.Key(runLocalKey).Bool()
+	config.Thresholds = splitList([]string{generalSection.Key(thresholdsKey).String()})
+	config.AbortPolicy.ErrorRatio = generalSection.Key(abortErrorRatioKey).MustFloat64(config.AbortPolicy.ErrorRatio)
+	config.AbortPolicy.MinSamples = generalSection.Key(abortMinSamplesKey).MustInt(config.AbortPolicy.MinSamples)
+	config.AbortPolicy.ConsecutiveTimeouts = generalSection.Key(abortConsecutiveTimeoutsKey).MustInt(config.AbortPolicy.ConsecutiveTimeouts)
+	config.AbortPolicy.Statuses, _ = types.ParseStatuses([]string{generalSection.Key(abortStatusesKey).String()})
+	config.AbortAll, _ = generalSection.Key(abortAllKey).Bool()
 
 	regionsSection := cfg.Section("regions")
 	config.Regions = regionsSection.KeyStrings()
@@ -218,7 +497,35 @@ func parseSettings() *types.TestConfig {
 	headerHash := headersSection.KeysHash()
 	config.Headers = foldHeaders(headerHash)
 
-	return config
+	config.Assertions = foldAssertions(cfg.Section("assertions"))
+
+	functionSection := cfg.Section(lambdaSection)
+	config.Function.Namespace = functionSection.Key(namespaceKey).MustString(config.Function.Namespace)
+	config.Function.Name = functionSection.Key(functionNameKey).String()
+	config.Function.Role = functionSection.Key(roleNameKey).String()
+	config.Function.MemorySize = functionSection.Key(memoryKey).MustInt(config.Function.MemorySize)
+	config.Function.Timeout = functionSection.Key(lambdaTimeoutKey).MustInt(config.Function.Timeout)
+	config.Function.Architecture = functionSection.Key(architectureKey).MustString(config.Function.Architecture)
+	if tags := cfg.Section("tags").KeysHash(); len(tags) > 0 {
+		config.Function.Tags = tags
+	}
+
+	credentialsSection := cfg.Section(awsSection)
+	config.AWS.Profile = credentialsSection.Key(profileKey).String()
+	config.AWS.AssumeRoleARN = credentialsSection.Key(assumeRoleARNKey).String()
+	config.AWS.ExternalID = credentialsSection.Key(externalIDKey).String()
+	config.AWS.MFASerial = credentialsSection.Key(mfaSerialKey).String()
+	config.AWS.Endpoint = credentialsSection.Key(endpointKey).String()
+
+	metricsSection := cfg.Section(exportSection)
+	config.Export.TestID = metricsSection.Key(testIDKey).String()
+	config.Export.Interval, _ = metricsSection.Key(exportIntervalKey).Int()
+	config.Export.InfluxDBURL = metricsSection.Key(influxDBURLKey).String()
+	config.Export.InfluxDBToken = metricsSection.Key(influxDBTokenKey).String()
+	config.Export.PushgatewayURL = metricsSection.Key(pushgatewayURLKey).String()
+	config.Export.StatsDAddress = metricsSection.Key(statsDAddressKey).String()
+
+	return config, nil
 }
 
 func applyExtendedConfiguration(config *types.TestConfig) {
@@ -226,6 +533,7 @@ func applyExtendedConfiguration(config *types.TestConfig) {
 	if cfg == nil {
 		return
 	}
+	config.Function.Networks = loadNetworks(cfg)
 	taskSection := cfg.Section("task")
 	runnerPathKey, err := taskSection.GetKey("runner")
 	if err != nil {
@@ -234,6 +542,25 @@ func applyExtendedConfiguration(config *types.TestConfig) {
 	config.RunnerPath = runnerPathKey.String()
 }
 
+// loadNetworks reads the VPC settings of the [vpc.<region>] sections
+func loadNetworks(cfg *ini.File) map[string]types.Network {
+	var networks map[string]types.Network
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(section.Name(), vpcSectionPrefix) {
+			continue
+		}
+		if networks == nil {
+			networks = make(map[string]types.Network)
+		}
+		region := strings.TrimPrefix(section.Name(), vpcSectionPrefix)
+		networks[region] = types.Network{
+			Subnets:        splitList([]string{section.Key(subnetsKey).String()}),
+			SecurityGroups: splitList([]string{section.Key(securityGroupsKey).String()}),
+		}
+	}
+	return networks
+}
+
 func foldHeaders(hash map[string]string) []string {
 	headersList := make([]string, 0)
 	for k, v := range hash {
@@ -242,27 +569,78 @@ func foldHeaders(hash map[string]string) []string {
 	return headersList
 }
 
+// foldAssertions reads the assertions section, every line holds a spec like
+// "status: 200,201", the same kind may be used more than once
+func foldAssertions(section *ini.Section) []string {
+	assertionsList := make([]string, 0)
+	for _, key := range section.Keys() {
+		for _, value := range key.ValueWithShadows() {
+			assertionsList = append(assertionsList, fmt.Sprintf("%s:%s", key.Name(), value))
+		}
+	}
+	return assertionsList
+}
+
 func parseCommandline() *types.TestConfig {
 	args := os.Args[1:]
 
-	kingpin.MustParse(app.Parse(args))
+	command := kingpin.MustParse(app.Parse(args))
 	if *writeIni {
 		writeIniFile()
 		fmt.Printf("Sample configuration written to: %s\n", iniFile)
 		os.Exit(0)
 	}
+	function, err := functionConfig()
+	app.FatalIfError(err, "")
+	awsConfig := types.AWSConfig{
+		Profile:       *awsProfile,
+		AssumeRoleARN: *assumeRoleARN,
+		ExternalID:    *externalID,
+		MFASerial:     *mfaSerial,
+		Endpoint:      *awsEndpoint,
+	}
+	if command == cleanupCmd.FullCommand() {
+		app.FatalIfError(function.Check(), "")
+		app.FatalIfError(awsConfig.Check(), "")
+		sess, err := awsinfra.NewSession(awsConfig)
+		app.FatalIfError(err, "")
+		err = awsinfra.Cleanup(sess, function, types.SupportedRegions, *dryRun, os.Stdout)
+		app.FatalIfError(err, "")
+		os.Exit(0)
+	}
+	if command == reportCmd.FullCommand() {
+		app.FatalIfError(renderReport(*reportFile, *reportFormat, *reportOutput), "")
+		os.Exit(0)
+	}
+	if command == diffCmd.FullCommand() {
+		tolerances := compare.Tolerances{Latency: *latencyTolerance, Rate: *rpsTolerance, ErrorRate: *errorRateTolerance}
+		regressed, err := diffReports(*baselineFile, *candidateFile, tolerances)
+		app.FatalIfError(err, "")
+		if regressed {
+			os.Exit(regressionExitCode)
+		}
+		os.Exit(0)
+	}
 
-	if *url == "" {
+	if *url == "" && *scenarioFile == "" && *requestsFile == "" {
 		fmt.Println("No URL provided")
 		app.Usage(args)
 		os.Exit(1)
 	}
 
 	regionsArray := parseRegionsForBackwardsCompatibility(*regions)
+	requestRate, err := types.ParseRate(*rate)
+	app.FatalIfError(err, "")
+	loadStages, err := types.ParseStages(*stages)
+	app.FatalIfError(err, "")
+	abortOnStatuses, err := types.ParseStatuses(*abortStatuses)
+	app.FatalIfError(err, "")
 
 	config := &types.TestConfig{}
 	config.URL = *url
 	config.Concurrency = *concurrency
+	config.Rate = requestRate
+	config.Stages = loadStages
 	config.Requests = *requests
 	config.Timelimit = *timelimit
 	config.Timeout = *timeout
@@ -270,11 +648,89 @@ func parseCommandline() *types.TestConfig {
 	config.Method = *method
 	config.Body = *body
 	config.Headers = *headers
+	config.Assertions = *assertions
+	config.Thresholds = splitList(*thresholds)
+	config.AbortPolicy = types.AbortPolicy{
+		ErrorRatio:          *abortErrorRatio,
+		MinSamples:          *abortMinSamples,
+		ConsecutiveTimeouts: *abortConsecutiveTimeouts,
+		Statuses:            abortOnStatuses,
+	}
+	config.AbortAll = *abortAll
 	config.Output = *outputFile
+	config.HTMLReport = *htmlReport
+	config.SeriesInterval = *seriesInterval
 	config.RunDocker = *runDocker
+	config.RunLocal = *runLocal
+	config.Function = function
+	config.AWS = awsConfig
+	config.Export = types.ExportConfig{
+		TestID:         *testID,
+		Interval:       *exportInterval,
+		InfluxDBURL:    *influxDBURL,
+		InfluxDBToken:  *influxDBToken,
+		PushgatewayURL: *pushgatewayURL,
+		StatsDAddress:  *statsDAddress,
+	}
+	if config.Export.Enabled() && config.Export.TestID == "" {
+		config.Export.TestID = time.Now().Format("20060102-150405")
+	}
+	config.ScenarioFile = *scenarioFile
+	if config.ScenarioFile != "" {
+		config.Scenario, err = scenario.Load(config.ScenarioFile)
+		app.FatalIfError(err, "")
+		if config.URL == "" && len(config.Scenario.Steps) > 0 {
+			config.URL = config.Scenario.Steps[0].URL
+		}
+	}
+	config.RequestsFile = *requestsFile
+	if config.RequestsFile != "" {
+		requestMix, err := requestfile.Load(config.RequestsFile)
+		app.FatalIfError(err, "")
+		if config.URL == "" {
+			config.URL = requestMix[0].URL
+		}
+	}
+	config.DataFile = *dataFile
+	config.Feeder = *feeder
+	if config.DataFile != "" {
+		_, err := templating.LoadFeeder(config.DataFile, config.Feeder, 0, 1)
+		app.FatalIfError(err, "")
+	}
 	return config
 }
 
+// functionConfig returns the settings of the lambda function given on the
+// command-line
+func functionConfig() (types.FunctionConfig, error) {
+	resourceTags, err := types.ParseTags(*tags)
+	if err != nil {
+		return types.FunctionConfig{}, err
+	}
+	return types.FunctionConfig{
+		Namespace:    *namespace,
+		Name:         *functionName,
+		Role:         *roleName,
+		MemorySize:   *lambdaMemory,
+		Timeout:      *lambdaTimeout,
+		Architecture: *architecture,
+		Tags:         resourceTags,
+	}, nil
+}
+
+// splitList splits comma separated values and drops empty ones
+func splitList(values []string) []string {
+	list := make([]string, 0)
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
 func parseRegionsForBackwardsCompatibility(regions []string) []string {
 	parsedRegions := make([]string, 0)
 	for _, str := range regions {
@@ -283,13 +739,30 @@ func parseRegionsForBackwardsCompatibility(regions []string) []string {
 	return parsedRegions
 }
 
-func start(test *types.TestConfig, sigChan chan os.Signal) result.LambdaResults {
+// start runs the test until all runners finished or the user interrupts. The
+// test is aborted early when an aborting threshold is violated, receiving the
+// results failed or, with AbortAll, a runner aborted, the reason is returned
+// in that case.
+func start(test *types.TestConfig, criteria []*threshold.Threshold, sigChan chan os.Signal) (result.LambdaResults, string) {
 	var currentResult result.LambdaResults
-	resultChan, teardown := goad.Start(test)
+	resultChan, errChan, teardown := goad.Start(test)
 	defer teardown()
+	if test.Export.Enabled() {
+		feed := export.NewFeed(test.Export.TestID, time.Duration(test.Export.Interval)*time.Second, export.New(test.Export))
+		resultChan = feed.Run(resultChan)
+		// runs once the terminal is restored, the last results are exported
+		// before the runners are torn down
+		defer func() {
+			if err := feed.Close(); err != nil {
+				fmt.Println(err)
+			}
+		}()
+	}
 
 	platform := "AWS"
-	if test.RunDocker {
+	if test.RunLocal {
+		platform = "this machine"
+	} else if test.RunDocker {
 		platform = "Docker"
 	}
 	launchingOn := fmt.Sprintf("Launching on %s... (be patient)", platform)
@@ -324,6 +797,9 @@ outer:
 		select {
 		case result, ok := <-resultChan:
 			if !ok {
+				if err := <-errChan; err != nil {
+					return currentResult, err.Error()
+				}
 				break outer
 			}
 			currentResult = *result
@@ -332,7 +808,9 @@ outer:
 				firstTime = false
 			}
 
-			y := 3
+			// the progress bar and the health of the runners take the
+			// first three lines
+			y := 4
 			totalReqs := 0
 			regionsData := currentResult.RegionsData()
 			if render {
@@ -341,26 +819,48 @@ outer:
 					y = renderRegion(regionsData[region], y)
 					y++
 				}
+				renderSeries(currentResult.SumAllLambdas().Series, y)
 			}
 
 			if render {
 				y = 0
 				var percentDone float64
 				if test.Requests > 0 {
-					percentDone = float64(totalReqs) / float64(test.Requests)
+					percentDone = float64(totalReqs) / float64(expectedRequests(test))
 				} else {
 					percentDone = math.Min(float64(time.Since(startTime).Seconds())/float64(test.Timelimit), 1.0)
 				}
 				drawProgressBar(percentDone, y)
+				if len(test.Stages) > 0 {
+					renderString(10, y, stageDescription(test.Stages, time.Since(startTime)), coldef, coldef)
+				}
+				renderHealth(currentResult.Health(time.Now(), infrastructure.HeartbeatTimeout), y+2)
 
 				termbox.Flush()
 			}
+			if violated, ok := threshold.Violated(criteria, currentResult); ok {
+				return currentResult, fmt.Sprintf("threshold %s was violated", violated.Spec)
+			}
+			if test.AbortAll {
+				if fatalError := currentResult.SumAllLambdas().FatalError; fatalError != "" {
+					return currentResult, fmt.Sprintf("a runner failed: %s", fatalError)
+				}
+			}
 
 		case <-sigChan:
 			break outer
 		}
 	}
-	return currentResult
+	return currentResult, ""
+}
+
+// expectedRequests returns the total number of requests of the test, every
+// iteration of a scenario makes one request per step
+func expectedRequests(test *types.TestConfig) int {
+	if test.Scenario != nil {
+		return test.Requests * len(test.Scenario.Steps)
+	}
+	return test.Requests
 }
 
 func renderLogo() {
@@ -407,19 +907,47 @@ func renderRegion(data result.AggData, y int) int {
 	resultStr = fmt.Sprintf("  %7.3fs   %7.3fs %10d %10d", float64(data.Slowest)/nano, float64(data.Fastest)/nano, data.TotalTimedOut, totErrors(data))
 	renderString(x, y, resultStr, coldef, coldef)
 	y++
+	if data.TotalDropped > 0 || data.TotalLate > 0 {
+		renderString(x, y, schedulerHeading, coldef|termbox.AttrBold, coldef)
+		y++
+		renderString(x, y, formatSchedulerStats(data), coldef, coldef)
+		y++
+	}
+	renderString(x, y, percentilesHeading, coldef|termbox.AttrBold, coldef)
+	y++
+	for _, row := range percentileRows(data) {
+		renderString(x, y, row, coldef, coldef)
+		y++
+	}
+	if data.FatalError != "" {
+		renderString(x, y, "Aborted: "+data.FatalError, termbox.ColorRed, coldef)
+		y++
+	}
 
 	return y
 }
 
-func totErrors(data result.AggData) int {
-	var okReqs int
-	for statusStr, value := range data.Statuses {
-		status, _ := strconv.Atoi(statusStr)
-		if status < 400 {
-			okReqs += value
-		}
+const schedulerHeading = "   Dropped       Late"
+
+func formatSchedulerStats(data result.AggData) string {
+	return fmt.Sprintf("%10d %10d", data.TotalDropped, data.TotalLate)
+}
+
+const percentilesHeading = "              p50       p90       p95       p99     p99.9"
+
+func percentileRows(data result.AggData) []string {
+	return []string{
+		formatPercentiles("TTFB", data.TimeToFirstPercentiles),
+		formatPercentiles("Total", data.TimeForReqPercentiles),
 	}
-	return data.TotalReqs - okReqs
+}
+
+func formatPercentiles(label string, p histogram.Percentiles) string {
+	return fmt.Sprintf("%6s %8.3fs %8.3fs %8.3fs %8.3fs %8.3fs", label, float64(p.P50)/nano, float64(p.P90)/nano, float64(p.P95)/nano, float64(p.P99)/nano, float64(p.P999)/nano)
+}
+
+func totErrors(data result.AggData) int {
+	return result.Errors(data)
 }
 
 func drawProgressBar(percent float64, y int) {
@@ -440,6 +968,30 @@ func drawProgressBar(percent float64, y int) {
 	renderString(width+1, y, "]", coldef, coldef)
 }
 
+// renderHealth shows how many runners report, the line turns red once a
+// runner failed or went missing
+func renderHealth(health result.RunnerHealth, y int) {
+	w, _ := termbox.Size()
+	fg := coldef
+	if health.Failed > 0 || health.Missing > 0 {
+		fg = termbox.ColorRed
+	}
+	renderString(0, y, fmt.Sprintf("%-*s", w, formatHealth(health)), fg, coldef)
+}
+
+func formatHealth(health result.RunnerHealth) string {
+	return fmt.Sprintf("Runners: %d/%d running, %d finished, %d failed, %d missing", health.Running, health.Expected, health.Finished, health.Failed, health.Missing)
+}
+
+func stageDescription(stages []types.Stage, elapsed time.Duration) string {
+	index, concurrency, done := types.StageAt(stages, elapsed)
+	if done {
+		return fmt.Sprintf("All %d stages done, waiting for results…        ", len(stages))
+	}
+	stage := stages[index]
+	return fmt.Sprintf("Stage %d/%d: %s, concurrency %.0f → %d        ", index+1, len(stages), stage.Duration, concurrency, stage.Target)
+}
+
 func renderString(x int, y int, str string, f termbox.Attribute, b termbox.Attribute) {
 	for i, c := range str {
 		termbox.SetCell(x+i, y, c, f, b)
@@ -456,23 +1008,46 @@ func printData(data result.AggData) {
 	boldPrintln("   Slowest    Fastest   Timeouts  TotErrors")
 	fmt.Printf("  %7.3fs   %7.3fs %10d %10d", float64(data.Slowest)/nano, float64(data.Fastest)/nano, data.TotalTimedOut, totErrors(data))
 	fmt.Println("")
+	if data.TotalDropped > 0 || data.TotalLate > 0 {
+		boldPrintln(schedulerHeading)
+		fmt.Println(formatSchedulerStats(data))
+	}
+	boldPrintln(percentilesHeading)
+	for _, row := range percentileRows(data) {
+		fmt.Println(row)
+	}
+	if data.Phases.Server.Count > 0 {
+		boldPrintln(phasesHeading)
+		fmt.Println(formatPhases(data.Phases))
+	}
+	if data.FatalError != "" {
+		fmt.Println("Aborted: " + data.FatalError)
+	}
+}
+
+const phasesHeading = "       DNS    Connect        TLS     Server   Transfer"
+
+// formatPhases prints the average duration of every connection phase over
+// the requests it occurred in
+func formatPhases(p api.Phases) string {
+	return fmt.Sprintf("  %7.3fs   %7.3fs   %7.3fs   %7.3fs   %7.3fs", float64(p.DNS.Mean())/nano, float64(p.Connect.Mean())/nano, float64(p.TLS.Mean())/nano, float64(p.Server.Mean())/nano, float64(p.Transfer.Mean())/nano)
 }
 
-func printSummary(results result.LambdaResults) {
-	if len(results.Regions()) == 0 {
+func printSummary(doc *report.Document) {
+	if len(doc.Regions) == 0 {
 		boldPrintln("No results received")
+		printRunners(doc.Results(), doc.Finished)
 		return
 	}
 	boldPrintln("Regional results")
 	fmt.Println("")
 
-	regionsData := results.RegionsData()
-	for _, region := range results.Regions() {
+	for _, region := range doc.RegionNames() {
 		fmt.Println("Region: " + region)
-		printData(regionsData[region])
+		printData(doc.Regions[region])
 	}
 
-	overall := results.SumAllLambdas()
+	overall := doc.Overall
 
 	fmt.Println("")
 	boldPrintln("Overall")
@@ -484,25 +1059,171 @@ func printSummary(results result.LambdaResults) {
 		fmt.Printf("%10s %10d\n", statusStr, value)
 	}
 	fmt.Println("")
+
+	if len(overall.Breakdown) > 0 {
+		printBreakdown(overall.Breakdown)
+	}
+	if len(overall.FailedAssertions) > 0 {
+		printFailedAssertions(overall.FailedAssertions)
+	}
+	printRunners(doc.Results(), doc.Finished)
 }
 
-func saveJSONSummary(path string, results result.LambdaResults) {
-	if len(results.Regions()) == 0 {
+// logTailLines limits the log of a failed runner in the summary
+const logTailLines = 10
+
+// printRunners lists the runners that failed or went missing by the end of
+// the test, it prints nothing if all runners reported
+func printRunners(results result.LambdaResults, finished time.Time) {
+	health := results.Health(finished, infrastructure.HeartbeatTimeout)
+	if health.Failed == 0 && health.Missing == 0 {
 		return
 	}
-	dataForRegions := results.RegionsData()
+	boldPrintln(formatHealth(health))
+	for id, runner := range results.Lambdas {
+		switch {
+		case runner.Failed:
+			fmt.Printf("Runner %d%s failed: %s\n", id, runnerRegion(runner), runner.FatalError)
+			for _, line := range tailLines(runner.FailureLog, logTailLines) {
+				fmt.Println("    " + line)
+			}
+		case results.RunnerMissing(runner, finished, infrastructure.HeartbeatTimeout):
+			fmt.Printf("Runner %d%s is missing, it did not report for %s\n", id, runnerRegion(runner), infrastructure.HeartbeatTimeout)
+		}
+	}
+	fmt.Println("")
+}
+
+func runnerRegion(runner result.AggData) string {
+	if runner.Region == "" {
+		return ""
+	}
+	return " in " + runner.Region
+}
+
+// tailLines returns the last n lines of log
+func tailLines(log string, n int) []string {
+	if log == "" {
+		return nil
+	}
+	lines := strings.Split(log, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+func printFailedAssertions(failed map[string]int) {
+	boldPrintln("Assertion                          Failures")
+	specs := make([]string, 0, len(failed))
+	for spec := range failed {
+		specs = append(specs, spec)
+	}
+	sort.Strings(specs)
+	for _, spec := range specs {
+		fmt.Printf("%-32s %10d\n", spec, failed[spec])
+	}
+	fmt.Println("")
+}
+
+func printThresholds(outcomes []threshold.Outcome) {
+	boldPrintln("Threshold                            Actual   Result")
+	for _, outcome := range outcomes {
+		verdict := "pass"
+		if !outcome.Passed {
+			verdict = "FAIL"
+		}
+		fmt.Printf("%-28s %14s   %s\n", outcome.Threshold.Spec, outcome.Actual, verdict)
+	}
+	fmt.Println("")
+}
 
-	overall := results.SumAllLambdas()
+func printBreakdown(breakdown map[string]*api.RequestStats) {
+	boldPrintln("Name                   TotReqs  TotErrors    AvgTime        p50        p95        p99")
+	names := make([]string, 0, len(breakdown))
+	for name := range breakdown {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		stats := breakdown[name]
+		p := stats.TimeForReqHistogram.Percentiles()
+		fmt.Printf("%-20s %9d %10d   %7.3fs   %7.3fs   %7.3fs   %7.3fs\n", name, stats.RequestCount, requestStatsErrors(stats), float64(stats.AveTimeForReq())/nano, float64(p.P50)/nano, float64(p.P95)/nano, float64(p.P99)/nano)
+	}
+	fmt.Println("")
+}
 
-	dataForRegions["overall"] = overall
-	b, err := json.MarshalIndent(dataForRegions, "", "  ")
+func requestStatsErrors(stats *api.RequestStats) int {
+	var okReqs int
+	for statusStr, value := range stats.Statuses {
+		status, _ := strconv.Atoi(statusStr)
+		if status < 400 {
+			okReqs += value
+		}
+	}
+	return stats.RequestCount - okReqs + stats.FailedAssertionRequests
+}
+
+// writeReport writes the document to path in the format of write
+func writeReport(path string, doc *report.Document, write func(io.Writer, *report.Document) error) error {
+	var content bytes.Buffer
+	if err := write(&content, doc); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content.Bytes(), 0644)
+}
+
+func readDocument(path string) (*report.Document, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return nil, err
 	}
-	err = ioutil.WriteFile(path, b, 0644)
+	defer file.Close()
+	doc, err := report.ReadDocument(file)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return doc, nil
+}
+
+// renderReport renders the result document at path in format to the terminal
+// or the output file
+func renderReport(path, format, output string) error {
+	doc, err := readDocument(path)
+	if err != nil {
+		return err
+	}
+	if format == summaryFormat {
+		if output != "" {
+			return errors.New("the summary is printed to the terminal, choose another format to write a file")
+		}
+		printSummary(doc)
+		return nil
+	}
+	if output == "" {
+		return reportWriters[format](os.Stdout, doc)
+	}
+	return writeReport(output, doc, reportWriters[format])
+}
+
+// diffReports prints the comparison of the result documents and reports
+// whether the candidate regressed
+func diffReports(baselinePath, candidatePath string, tolerances compare.Tolerances) (bool, error) {
+	baseline, err := readDocument(baselinePath)
+	if err != nil {
+		return false, err
+	}
+	candidate, err := readDocument(candidatePath)
+	if err != nil {
+		return false, err
+	}
+	comparison := compare.Compare(baseline, candidate, tolerances)
+	compare.Write(os.Stdout, comparison)
+	regressions := comparison.Regressions()
+	if len(regressions) == 0 {
+		boldPrintln("No regressions")
+		return false, nil
 	}
+	boldPrintln("Regressions: " + strings.Join(regressions, ", "))
+	return true, nil
 }
@@ -8,6 +8,15 @@ const template = `[general]
 # Number of concurrent lambda functions.
 concurrency = 10
 
+# Target request rate (open workload model), eg. 100/s. When set, the
+# concurrency limits the number of requests in flight.
+;rate = 100/s
+
+# Load profile as a list of duration:concurrency stages. The concurrency is
+# ramped linearly towards the target of each stage, the total duration of the
+# stages replaces the timelimit and the number of requests is not limited.
+;stages = 2m:500, 10m:500, 1m:0
+
 # Total count of requests to be executed.
 requests = 1000
 
@@ -20,12 +29,54 @@ requests = 1000
 # Store output in json
 ;json-output = result.json
 
+# Write a self-contained HTML report with the settings, results and charts
+;html-report = report.html
+
+# Seconds per interval of the time series of requests, errors and latency
+# kept alongside the totals. The series is part of the JSON output and drawn
+# as sparklines while the test runs.
+;series-interval = 10
+
 # The HTTP method to be used
 ;method = GET
 
 # The requst body passed with each requests
 ;body = Hello world
 
+# JSON file describing a multi-step scenario every worker executes, relative
+# step URLs are resolved against the url setting
+;scenario = scenario.json
+
+# JSONL file with one request per line (name, method, url, headers, body,
+# weight). Workers pick requests in proportion to their weight, results are
+# broken down per request name.
+;requests-file = requests.jsonl
+
+# URL, headers and body are Go templates. Besides {{uuid}}, {{randInt 1 100}}
+# and {{now.Unix}} the columns of a CSV data file (first line holds the column
+# names) are available as {{csv "column"}}. The feeder decides which row is
+# used for a request: sequential, random or unique (rows are partitioned
+# between the runners).
+;data-file = users.csv
+;feeder = sequential
+
+# Pass/fail criteria checked after the test as [region:]metric<value. Metrics
+# are p50, p90, p95, p99, p99.9, avg, max, ttfb_p95 (durations), error_rate
+# (percent), errors, rps and requests. goad exits non-zero if one fails, a
+# trailing ! aborts the test as soon as the threshold is violated.
+;thresholds = p95<300ms, error_rate<1%, eu-west-1:rps>200, p99<2s!
+
+# A runner stops its workers when more than abort-error-ratio of its requests
+# timed out or failed to connect (checked after abort-min-samples requests),
+# after abort-consecutive-timeouts timeouts in a row or when it receives one
+# of the abort-statuses. 0 disables a check. With abort-all the whole test is
+# stopped as soon as one runner aborts.
+;abort-error-ratio = 0.5
+;abort-min-samples = 100
+;abort-consecutive-timeouts = 10
+;abort-statuses = 429, 503
+;abort-all = true
+
 [regions]
 # You can specify various aws region to run lambda functions.
 
@@ -41,10 +92,77 @@ ap-northeast-1 ;Tokyo
 ;ap-northeast-2 ;Seoul
 ;sa-east-1 ;Sao Paulo
 
+[lambda]
+# Settings of the lambda function. All AWS resources are named after the
+# namespace: the function (unless function-name is set), the IAM role
+# <namespace>-lambda-role (unless role-name is set) and the SQS queues of a
+# test. Use a namespace per team to share an account. The timeout of an
+# invocation is given in seconds, longer tests are continued by a new one.
+
+;namespace = goad
+;function-name = goad
+;role-name = goad-lambda-role
+;memory = 1536
+;timeout = 300
+;architecture = arm64
+
+[tags]
+# These tags are added to the function, its role and the queues goad creates
+
+;team: performance
+;cost-center: 1234
+
+# To load test endpoints only reachable inside a VPC the function is placed
+# in the subnets and security groups of a [vpc.<region>] section. Once one
+# region has a section, every region of the test needs one. The subnets need
+# a route to SQS (NAT gateway or VPC endpoint) to send the results.
+
+;[vpc.us-east-1]
+;subnets = subnet-0123456789abcdef0, subnet-0fedcba9876543210
+;security-groups = sg-0123456789abcdef0
+
+[aws]
+# Credentials of the AWS clients, by default the credentials of the
+# environment or the default profile are used. A role can be assumed with
+# them, eg. to run in another account, the token of an MFA device is read
+# from the terminal. The endpoint replaces the ones of all AWS services, eg.
+# to use a local emulator.
+
+;profile = load-testing
+;assume-role-arn = arn:aws:iam::123456789012:role/goad
+;external-id = 1234
+;mfa-serial = arn:aws:iam::123456789012:mfa/user
+;endpoint = http://localhost:4566
+
+[export]
+# Stream the results per region to metrics systems while the test runs, eg.
+# to show them next to the metrics of the servers on a dashboard. The metrics
+# are tagged with the test id, the start time of the test by default. The
+# InfluxDB token may be set in GOAD_INFLUXDB_TOKEN instead.
+
+;test-id = release-1.2
+;interval = 10
+;influxdb-url = http://localhost:8086/write?db=goad
+;influxdb-token = YOUR-INFLUXDB-TOKEN
+;pushgateway-url = http://localhost:9091
+;statsd-address = localhost:8125
+
 [headers]
 # These headers are used in the HTTP request header
 
 ;cache-control: no-cache
 ;auth-token: YOUR-SECRET-AUTH-TOKEN
 ;base64-header: dGV4dG8gZGUgcHJ1ZWJhIA==
+
+[assertions]
+# Every response is checked against these assertions, responses failing one
+# count as errors even with a successful status code. A kind may be listed
+# more than once.
+
+;status: 200,201
+;max-latency: 500ms
+;body-contains: success
+;body-regex: "id":\s*\d+
+;json: $.status=ok
+;header: Content-Type=application/json
 `
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
+	"os"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/goadapp/goad/goad/types"
 	"github.com/stretchr/testify/assert"
@@ -18,9 +20,43 @@ var expectedHeader = []string{"cache-control: no-cache", "auth-token: YOUR-SECRE
 
 func TestLoadStandardConfig(t *testing.T) {
 	iniFile = testDataFile
-	config := parseSettings()
+	config, err := parseSettings()
+	assert.NoError(t, err)
 	applyExtendedConfiguration(config)
 	assertConfigContent(config, t)
+
+	config.ApplyStages()
+	assert.Equal(t, 0, config.Requests, "Stages should not limit the requests")
+	assert.Equal(t, 10, config.Concurrency, "Stages should set the peak concurrency")
+	assert.Equal(t, 90, config.Timelimit, "Stages should set the timelimit")
+}
+
+func TestInvalidStagesInConfig(t *testing.T) {
+	iniFile = writeTestIni(t, "[general]\nstages = 2m:500, 1m\n")
+	defer os.Remove(iniFile)
+	_, err := parseSettings()
+	assert.EqualError(t, err, `Stage 1m not valid. Make sure your stage is of the form "duration:concurrency", eg. 2m:500`)
+}
+
+func TestInvalidRateInConfig(t *testing.T) {
+	iniFile = writeTestIni(t, "[general]\nrate = 100/d\n")
+	defer os.Remove(iniFile)
+	_, err := parseSettings()
+	assert.EqualError(t, err, `Invalid rate unit in "100/d" (use /s, /m or /h)`)
+}
+
+// writeTestIni writes the settings to a temporary ini file and returns its
+// name
+func writeTestIni(t *testing.T, settings string) string {
+	file, err := ioutil.TempFile("", "goad-ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(settings); err != nil {
+		t.Fatal(err)
+	}
+	return file.Name()
 }
 
 func assertConfigContent(config *types.TestConfig, t *testing.T) {
@@ -34,10 +70,23 @@ func assertConfigContent(config *types.TestConfig, t *testing.T) {
 	assert.Equal(13, config.Timelimit, "Should load the execution timelimit")
 	assert.Equal(expectedRegions, config.Regions, "Should load the regions")
 	assert.Equal("test-result.json", config.Output, "Should load the output file")
+	assert.Equal(5, config.SeriesInterval, "Should load the series interval")
+	assert.Equal([]types.Stage{{Duration: time.Minute, Target: 10}, {Duration: 30 * time.Second, Target: 0}}, config.Stages, "Should load the stages")
 	sort.Strings(expectedHeader)
 	sort.Strings(config.Headers)
 	assert.Equal(expectedHeader, config.Headers, "Should load the output file")
+	assert.Equal([]string{"status:200,201", "body-contains:success", "body-contains:id"}, config.Assertions, "Should load the assertions")
+	assert.Equal([]string{"p95<300ms", "eu-west-1:error_rate<1%!"}, config.Thresholds, "Should load the thresholds")
+	assert.Equal(types.AbortPolicy{ErrorRatio: 0, MinSamples: 100, ConsecutiveTimeouts: 5, Statuses: []int{429, 503}}, config.AbortPolicy, "Should load the abort policy")
+	assert.True(config.AbortAll, "Should load abort-all")
 	assert.Equal("default-runner", config.RunnerPath, "Should load runner path configuration")
+	assert.Equal(types.AWSConfig{Profile: "load-testing", Endpoint: "http://localhost:4566"}, config.AWS, "Should load the AWS settings")
+	assert.Equal(types.ExportConfig{TestID: "release-1.2", PushgatewayURL: "http://localhost:9091"}, config.Export, "Should load the export settings")
+	assert.Equal(map[string]types.Network{"us-east-1": {Subnets: []string{"subnet-1", "subnet-2"}, SecurityGroups: []string{"sg-1"}}}, config.Function.Networks, "Should load the VPC settings")
+	assert.Error(config.Function.CheckNetworks(config.Regions), "Should require VPC settings for every region")
+	config.Function.Networks = nil
+	assert.Equal(types.FunctionConfig{Namespace: "team-a", MemorySize: 2048, Timeout: 300, Architecture: "arm64", Tags: map[string]string{"team": "performance"}}, config.Function, "Should load the lambda settings")
+	assert.Equal("team-a-lambda-role", config.Function.RoleName(), "Should derive the role from the namespace")
 }
 
 func TestSaveConfig(t *testing.T) {
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/goadapp/goad/result"
+	"github.com/nsf/termbox-go"
+)
+
+// sparkTicks are the bars of a sparkline from the lowest to the highest value
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws the last width values as bars scaled between zero and the
+// largest of them
+func sparkline(values []float64, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	var max float64
+	for _, value := range values {
+		if value > max {
+			max = value
+		}
+	}
+	bars := make([]rune, len(values))
+	for i, value := range values {
+		tick := 0
+		if max > 0 {
+			tick = int(value / max * float64(len(sparkTicks)-1))
+		}
+		bars[i] = sparkTicks[tick]
+	}
+	return string(bars)
+}
+
+// completeIntervals drops the last interval of the series, it is still
+// filling up
+func completeIntervals(series []result.Interval) []result.Interval {
+	if len(series) == 0 {
+		return series
+	}
+	return series[:len(series)-1]
+}
+
+// renderSeries draws sparklines of the request rate and the p95 latency of
+// the complete intervals and returns the y for the next empty line
+func renderSeries(series []result.Interval, y int) int {
+	series = completeIntervals(series)
+	if len(series) == 0 {
+		return y
+	}
+	rps := make([]float64, len(series))
+	p95 := make([]float64, len(series))
+	for i, interval := range series {
+		rps[i] = interval.ReqPerSec()
+		p95[i] = float64(interval.TimeForReqPercentiles.P95) / nano
+	}
+	last := series[len(series)-1]
+	w, _ := termbox.Size()
+	width := w - 24
+	renderString(0, y, "Per "+last.Length.String(), coldef|termbox.AttrBold, coldef)
+	y++
+	renderString(0, y, fmt.Sprintf("%-*s", w, fmt.Sprintf("Req/s  %s %10.2f", sparkline(rps, width), rps[len(rps)-1])), coldef, coldef)
+	y++
+	renderString(0, y, fmt.Sprintf("%-*s", w, fmt.Sprintf("p95    %s %9.3fs", sparkline(p95, width), p95[len(p95)-1])), coldef, coldef)
+	y++
+	return y
+}
@@ -0,0 +1,17 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSparklineScalesToLargestValue(t *testing.T) {
+	assert.Equal(t, "▁▄█", sparkline([]float64{0, 50, 100}, 10))
+	assert.Equal(t, "▁▁", sparkline([]float64{0, 0}, 10))
+}
+
+func TestSparklineScrollsToLastValues(t *testing.T) {
+	assert.Equal(t, "▁█", sparkline([]float64{100, 200, 0, 10}, 2))
+	assert.Equal(t, "", sparkline([]float64{1, 2}, 0))
+}
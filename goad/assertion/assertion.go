@@ -0,0 +1,188 @@
+package assertion
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goadapp/goad/goad/scenario"
+)
+
+const (
+	// Status expects one of a list of status codes or classes, eg.
+	// "status:200,201" or "status:2xx"
+	Status = "status"
+	// MaxLatency limits the time until the last byte, eg. "max-latency:500ms"
+	MaxLatency = "max-latency"
+	// BodyContains expects a substring of the body, eg. "body-contains:ok"
+	BodyContains = "body-contains"
+	// BodyRegex expects the body to match a regular expression, eg.
+	// "body-regex:\"id\":\s*\d+"
+	BodyRegex = "body-regex"
+	// JSON expects a value at a JSON path, eg. "json:$.status=ok". Without a
+	// value the path only needs to exist.
+	JSON = "json"
+	// Header expects a response header, eg. "header:ETag" or
+	// "header:Content-Type=application/json"
+	Header = "header"
+)
+
+// Kinds lists the supported assertion kinds
+var Kinds = []string{Status, MaxLatency, BodyContains, BodyRegex, JSON, Header}
+
+// Assertion is a check of a response. A response failing an assertion counts
+// as error even if the status code signals success. The spec doubles as name
+// of the assertion in the results.
+type Assertion struct {
+	Spec       string
+	kind       string
+	argument   string
+	statuses   []string
+	maxLatency time.Duration
+	regex      *regexp.Regexp
+	key        string
+	value      string
+	hasValue   bool
+}
+
+// Response is the part of a response assertions are evaluated against
+type Response struct {
+	Status  int
+	Elapsed time.Duration
+	Header  http.Header
+	Body    []byte
+}
+
+// Parse reads an assertion spec of the form kind:argument
+func Parse(spec string) (*Assertion, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("Assertion %q not valid. Make sure your assertion is of the form \"kind:argument\"", spec)
+	}
+	a := &Assertion{Spec: spec, kind: strings.TrimSpace(parts[0]), argument: parts[1]}
+	switch a.kind {
+	case Status:
+		for _, status := range strings.Split(a.argument, ",") {
+			status = strings.ToLower(strings.TrimSpace(status))
+			if !isStatus(status) {
+				return nil, fmt.Errorf("Assertion %q has invalid status %q", spec, status)
+			}
+			a.statuses = append(a.statuses, status)
+		}
+	case MaxLatency:
+		latency, err := time.ParseDuration(strings.TrimSpace(a.argument))
+		if err != nil {
+			return nil, fmt.Errorf("Assertion %q has invalid latency: %s", spec, err)
+		}
+		a.maxLatency = latency
+	case BodyContains:
+	case BodyRegex:
+		re, err := regexp.Compile(a.argument)
+		if err != nil {
+			return nil, fmt.Errorf("Assertion %q has invalid regex: %s", spec, err)
+		}
+		a.regex = re
+	case JSON, Header:
+		keyValue := strings.SplitN(a.argument, "=", 2)
+		a.key = strings.TrimSpace(keyValue[0])
+		if len(keyValue) == 2 {
+			a.value = keyValue[1]
+			a.hasValue = true
+		}
+	default:
+		return nil, fmt.Errorf("Unknown assertion kind %q in %q (use %s)", a.kind, spec, strings.Join(Kinds, ", "))
+	}
+	return a, nil
+}
+
+// ParseAll parses a list of specs
+func ParseAll(specs []string) ([]*Assertion, error) {
+	assertions := make([]*Assertion, 0, len(specs))
+	for _, spec := range specs {
+		a, err := Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, a)
+	}
+	return assertions, nil
+}
+
+// Validate reports the first invalid spec
+func Validate(specs []string) error {
+	for _, spec := range specs {
+		if _, err := Parse(spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check evaluates the assertion, the error describes why it failed
+func (a *Assertion) Check(r Response) error {
+	switch a.kind {
+	case Status:
+		code := strconv.Itoa(r.Status)
+		for _, status := range a.statuses {
+			if status == code || (strings.HasSuffix(status, "xx") && status[0] == code[0]) {
+				return nil
+			}
+		}
+		return fmt.Errorf("status %d not in %s", r.Status, strings.Join(a.statuses, ","))
+	case MaxLatency:
+		if r.Elapsed > a.maxLatency {
+			return fmt.Errorf("latency %s above %s", r.Elapsed, a.maxLatency)
+		}
+	case BodyContains:
+		if !bytes.Contains(r.Body, []byte(a.argument)) {
+			return fmt.Errorf("body does not contain %q", a.argument)
+		}
+	case BodyRegex:
+		if !a.regex.Match(r.Body) {
+			return fmt.Errorf("body does not match %s", a.argument)
+		}
+	case JSON:
+		value, err := scenario.JSONPath(r.Body, a.key)
+		if err != nil {
+			return err
+		}
+		if a.hasValue && value != a.value {
+			return fmt.Errorf("%s is %s, expected %s", a.key, value, a.value)
+		}
+	case Header:
+		values, ok := r.Header[http.CanonicalHeaderKey(a.key)]
+		if !ok {
+			return fmt.Errorf("header %s missing", a.key)
+		}
+		if a.hasValue && (len(values) == 0 || values[0] != a.value) {
+			return fmt.Errorf("header %s is %v, expected %s", a.key, values, a.value)
+		}
+	}
+	return nil
+}
+
+// Failed evaluates all assertions and returns the specs of those which failed
+func Failed(assertions []*Assertion, r Response) []string {
+	var failed []string
+	for _, a := range assertions {
+		if a.Check(r) != nil {
+			failed = append(failed, a.Spec)
+		}
+	}
+	return failed
+}
+
+func isStatus(status string) bool {
+	if len(status) != 3 || status[0] < '1' || status[0] > '5' {
+		return false
+	}
+	if status[1:] == "xx" {
+		return true
+	}
+	_, err := strconv.Atoi(status)
+	return err == nil
+}
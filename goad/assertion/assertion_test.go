@@ -0,0 +1,68 @@
+package assertion
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func check(t *testing.T, spec string, r Response) error {
+	a, err := Parse(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.Check(r)
+}
+
+func TestStatus(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(check(t, "status:200,201", Response{Status: 201}))
+	assert.Error(check(t, "status:200,201", Response{Status: 204}))
+	assert.NoError(check(t, "status:2xx", Response{Status: 204}))
+	assert.Error(check(t, "status:2xx", Response{Status: 302}))
+}
+
+func TestMaxLatency(t *testing.T) {
+	assert.NoError(t, check(t, "max-latency:500ms", Response{Elapsed: 499 * time.Millisecond}))
+	assert.Error(t, check(t, "max-latency:500ms", Response{Elapsed: 501 * time.Millisecond}))
+}
+
+func TestBody(t *testing.T) {
+	assert := assert.New(t)
+	r := Response{Body: []byte(`{"status":"error","data":{"items":[{"id":17}]}}`)}
+	assert.Error(check(t, "body-contains:\"ok\"", r))
+	assert.NoError(check(t, "body-contains:\"error\"", r))
+	assert.NoError(check(t, `body-regex:"id":\s*\d+`, r))
+	assert.Error(check(t, "json:$.status=ok", r))
+	assert.NoError(check(t, "json:$.status=error", r))
+	assert.NoError(check(t, "json:$.data.items[0].id=17", r))
+	assert.NoError(check(t, "json:$.data.items", r))
+	assert.Error(check(t, "json:$.data.missing", r))
+	assert.Error(check(t, "json:$.status", Response{Body: []byte("<html>")}))
+}
+
+func TestHeader(t *testing.T) {
+	assert := assert.New(t)
+	r := Response{Header: http.Header{"Content-Type": []string{"application/json"}}}
+	assert.NoError(check(t, "header:content-type", r))
+	assert.NoError(check(t, "header:Content-Type=application/json", r))
+	assert.Error(check(t, "header:Content-Type=text/html", r))
+	assert.Error(check(t, "header:ETag", r))
+}
+
+func TestInvalidSpecs(t *testing.T) {
+	for _, spec := range []string{"status", "status:", "status:abc", "status:6xx", "max-latency:soon", "body-regex:(", "unknown:x"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Expected %q to be invalid", spec)
+		}
+	}
+}
+
+func TestFailed(t *testing.T) {
+	assertions, err := ParseAll([]string{"status:200", "body-contains:ok", "header:ETag"})
+	assert.NoError(t, err)
+	failed := Failed(assertions, Response{Status: 200, Body: []byte("not quite")})
+	assert.Equal(t, []string{"body-contains:ok", "header:ETag"}, failed)
+}
@@ -0,0 +1,272 @@
+// Package compare aligns the results of two tests, eg. of two releases, and
+// detects regressions of the candidate against the baseline
+package compare
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/goadapp/goad/goad/report"
+	"github.com/goadapp/goad/goad/threshold"
+	"github.com/goadapp/goad/result"
+)
+
+// Overall is the name of the comparison of the whole tests
+const Overall = "overall"
+
+// Tolerances limit how much worse than the baseline the candidate may be
+// before a metric counts as regression
+type Tolerances struct {
+	// Latency is the increase of the mean and percentile latencies in
+	// percent of the baseline
+	Latency float64
+	// Rate is the decrease of the requests per second in percent of the
+	// baseline
+	Rate float64
+	// ErrorRate is the increase of the error rate in percentage points
+	ErrorRate float64
+}
+
+// DefaultTolerances are used if none are configured
+var DefaultTolerances = Tolerances{Latency: 10, Rate: 10, ErrorRate: 1}
+
+const (
+	kindRate = iota
+	kindDuration
+	kindPercent
+)
+
+// metrics are compared in this order, the names are the ones of thresholds
+var metrics = []struct {
+	name string
+	kind int
+}{
+	{"rps", kindRate},
+	{"avg", kindDuration},
+	{"p50", kindDuration},
+	{"p90", kindDuration},
+	{"p95", kindDuration},
+	{"p99", kindDuration},
+	{"error_rate", kindPercent},
+}
+
+// Delta is the change of a metric from the baseline to the candidate
+type Delta struct {
+	Metric     string
+	Baseline   float64
+	Candidate  float64
+	Regression bool
+	kind       int
+}
+
+// Change returns the difference of the candidate to the baseline
+func (d Delta) Change() float64 {
+	return d.Candidate - d.Baseline
+}
+
+// RelativeChange returns the change in percent of the baseline, it is not
+// defined for a baseline of zero
+func (d Delta) RelativeChange() (float64, bool) {
+	if d.Baseline == 0 {
+		return 0, false
+	}
+	return 100 * d.Change() / d.Baseline, true
+}
+
+// Region is the comparison of the results of a region or of the whole tests
+type Region struct {
+	Name     string
+	Metrics  []Delta
+	Statuses []Delta
+	// Missing names the test without results for the region
+	Missing string
+	// Regression is set if the baseline has requests for the region but the
+	// candidate has none
+	Regression bool
+}
+
+// Comparison holds the regions of both tests in alphabetical order followed
+// by the whole tests
+type Comparison struct {
+	Regions []Region
+}
+
+// Regressions returns the metrics that regressed, prefixed by their region
+func (c Comparison) Regressions() []string {
+	var regressions []string
+	for _, region := range c.Regions {
+		if region.Regression {
+			regressions = append(regressions, region.Name+":requests")
+		}
+		for _, delta := range region.Metrics {
+			if delta.Regression {
+				regressions = append(regressions, region.Name+":"+delta.Metric)
+			}
+		}
+	}
+	return regressions
+}
+
+// Compare aligns the regions and the overall results of the tests
+func Compare(baseline, candidate *report.Document, tolerances Tolerances) Comparison {
+	var comparison Comparison
+	for _, name := range regionNames(baseline, candidate) {
+		baselineData, inBaseline := baseline.Regions[name]
+		candidateData, inCandidate := candidate.Regions[name]
+		switch {
+		case !inBaseline:
+			comparison.Regions = append(comparison.Regions, Region{Name: name, Missing: "baseline"})
+		case !inCandidate:
+			comparison.Regions = append(comparison.Regions, Region{Name: name, Missing: "candidate", Regression: baselineData.TotalReqs > 0})
+		default:
+			comparison.Regions = append(comparison.Regions, compareRegion(name, baselineData, candidateData, tolerances))
+		}
+	}
+	comparison.Regions = append(comparison.Regions, compareRegion(Overall, baseline.Overall, candidate.Overall, tolerances))
+	return comparison
+}
+
+func regionNames(docs ...*report.Document) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, doc := range docs {
+		for _, name := range doc.RegionNames() {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func compareRegion(name string, baseline, candidate result.AggData, tolerances Tolerances) Region {
+	// a candidate without requests failed where the baseline did not
+	region := Region{Name: name, Regression: baseline.TotalReqs > 0 && candidate.TotalReqs == 0}
+	for _, metric := range metrics {
+		delta := Delta{
+			Metric:    metric.name,
+			Baseline:  threshold.Value(metric.name, baseline),
+			Candidate: threshold.Value(metric.name, candidate),
+			kind:      metric.kind,
+		}
+		// without requests in both tests there is nothing to compare
+		if baseline.TotalReqs > 0 && candidate.TotalReqs > 0 {
+			delta.Regression = regressed(delta, tolerances)
+		}
+		region.Metrics = append(region.Metrics, delta)
+	}
+	for _, status := range statuses(baseline, candidate) {
+		region.Statuses = append(region.Statuses, Delta{
+			Metric:    status,
+			Baseline:  share(baseline, status),
+			Candidate: share(candidate, status),
+			kind:      kindPercent,
+		})
+	}
+	return region
+}
+
+func regressed(delta Delta, tolerances Tolerances) bool {
+	switch delta.kind {
+	case kindRate:
+		change, ok := delta.RelativeChange()
+		return ok && -change > tolerances.Rate
+	case kindDuration:
+		change, ok := delta.RelativeChange()
+		return ok && change > tolerances.Latency
+	case kindPercent:
+		return delta.Change() > tolerances.ErrorRate
+	}
+	return false
+}
+
+func statuses(results ...result.AggData) []string {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, data := range results {
+		for code := range data.Statuses {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// share returns the percentage of the requests with the status
+func share(data result.AggData, status string) float64 {
+	if data.TotalReqs == 0 {
+		return 0
+	}
+	return 100 * float64(data.Statuses[status]) / float64(data.TotalReqs)
+}
+
+// Write prints the comparison as one table per region
+func Write(w io.Writer, comparison Comparison) {
+	for _, region := range comparison.Regions {
+		fmt.Fprintf(w, "Region: %s\n", region.Name)
+		if region.Missing != "" {
+			fmt.Fprintf(w, "No results in the %s%s\n\n", region.Missing, regionVerdict(region))
+			continue
+		}
+		if region.Regression {
+			fmt.Fprintf(w, "No requests in the candidate%s\n", regionVerdict(region))
+		}
+		fmt.Fprintln(w, "Metric            Baseline   Candidate       Change   Relative")
+		for _, delta := range region.Metrics {
+			writeDelta(w, delta.Metric, delta)
+		}
+		for _, delta := range region.Statuses {
+			writeDelta(w, "status "+delta.Metric, delta)
+		}
+		fmt.Fprintln(w, "")
+	}
+}
+
+func regionVerdict(region Region) string {
+	if region.Regression {
+		return "  REGRESSION"
+	}
+	return ""
+}
+
+func writeDelta(w io.Writer, label string, delta Delta) {
+	relative := ""
+	if change, ok := delta.RelativeChange(); ok && delta.kind != kindPercent {
+		relative = fmt.Sprintf("%+.1f%%", change)
+	}
+	verdict := ""
+	if delta.Regression {
+		verdict = "  REGRESSION"
+	}
+	line := fmt.Sprintf("%-14s %11s %11s %12s %10s%s", label, format(delta.kind, delta.Baseline), format(delta.kind, delta.Candidate), formatChange(delta.kind, delta.Change()), relative, verdict)
+	fmt.Fprintln(w, strings.TrimRight(line, " "))
+}
+
+func format(kind int, value float64) string {
+	switch kind {
+	case kindDuration:
+		return fmt.Sprintf("%.3fs", value/1e9)
+	case kindPercent:
+		return fmt.Sprintf("%.2f%%", value)
+	}
+	return fmt.Sprintf("%.2f", value)
+}
+
+// formatChange signs the change, changes of percentages are in percentage
+// points
+func formatChange(kind int, change float64) string {
+	switch kind {
+	case kindDuration:
+		return fmt.Sprintf("%+.3fs", change/1e9)
+	case kindPercent:
+		return fmt.Sprintf("%+.2fpp", change)
+	}
+	return fmt.Sprintf("%+.2f", change)
+}
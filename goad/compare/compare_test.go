@@ -0,0 +1,97 @@
+package compare
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/goadapp/goad/goad/histogram"
+	"github.com/goadapp/goad/goad/report"
+	"github.com/goadapp/goad/result"
+	"github.com/stretchr/testify/assert"
+)
+
+func aggData(requests int, rps float64, p95 time.Duration, statuses map[string]int) result.AggData {
+	return result.AggData{
+		TotalReqs:             requests,
+		AveReqPerSec:          rps,
+		AveTimeForReq:         int64(p95 / 2),
+		Statuses:              statuses,
+		TimeForReqHistogram:   histogram.New(),
+		TimeForReqPercentiles: histogram.Percentiles{P50: int64(p95 / 2), P90: int64(p95), P95: int64(p95), P99: int64(p95)},
+	}
+}
+
+func document(regions map[string]result.AggData, overall result.AggData) *report.Document {
+	return &report.Document{Version: report.DocumentVersion, Regions: regions, Overall: overall}
+}
+
+func TestLatencyIncreaseBeyondToleranceIsRegression(t *testing.T) {
+	assert := assert.New(t)
+	baseline := document(map[string]result.AggData{
+		"eu-west-1": aggData(1000, 100, 200*time.Millisecond, map[string]int{"200": 1000}),
+	}, aggData(1000, 100, 200*time.Millisecond, map[string]int{"200": 1000}))
+	candidate := document(map[string]result.AggData{
+		"eu-west-1": aggData(1000, 95, 250*time.Millisecond, map[string]int{"200": 980, "503": 20}),
+	}, aggData(1000, 95, 210*time.Millisecond, map[string]int{"200": 980, "503": 20}))
+
+	comparison := Compare(baseline, candidate, DefaultTolerances)
+	assert.Len(comparison.Regions, 2)
+	assert.Equal(Overall, comparison.Regions[1].Name)
+	assert.Equal([]string{"eu-west-1:avg", "eu-west-1:p50", "eu-west-1:p90", "eu-west-1:p95", "eu-west-1:p99", "eu-west-1:error_rate", "overall:error_rate"}, comparison.Regressions())
+
+	rps := comparison.Regions[1].Metrics[0]
+	assert.Equal("rps", rps.Metric)
+	assert.Equal(-5.0, rps.Change())
+	relative, ok := rps.RelativeChange()
+	assert.True(ok)
+	assert.Equal(-5.0, relative)
+	assert.False(rps.Regression, "within the tolerance")
+
+	statuses := comparison.Regions[1].Statuses
+	assert.Equal("503", statuses[1].Metric)
+	assert.Equal(2.0, statuses[1].Candidate)
+}
+
+func TestTolerancesAreConfigurable(t *testing.T) {
+	baseline := document(nil, aggData(1000, 100, 200*time.Millisecond, map[string]int{"200": 1000}))
+	candidate := document(nil, aggData(1000, 80, 260*time.Millisecond, map[string]int{"200": 1000}))
+
+	assert.Empty(t, Compare(baseline, candidate, Tolerances{Latency: 50, Rate: 25, ErrorRate: 1}).Regressions())
+	assert.Equal(t, []string{"overall:rps"}, Compare(baseline, candidate, Tolerances{Latency: 50, Rate: 10, ErrorRate: 1}).Regressions())
+}
+
+func TestRegionsOfOneTestOnly(t *testing.T) {
+	assert := assert.New(t)
+	data := aggData(10, 1, time.Second, map[string]int{"200": 10})
+	baseline := document(map[string]result.AggData{"eu-west-1": data}, data)
+	candidate := document(map[string]result.AggData{"us-east-1": data}, data)
+
+	comparison := Compare(baseline, candidate, DefaultTolerances)
+	assert.Equal("candidate", comparison.Regions[0].Missing)
+	assert.Equal("baseline", comparison.Regions[1].Missing)
+	assert.Equal([]string{"eu-west-1:requests"}, comparison.Regressions(), "a region without results in the candidate regressed")
+
+	var out bytes.Buffer
+	Write(&out, comparison)
+	assert.Contains(out.String(), "Region: eu-west-1\nNo results in the candidate  REGRESSION\n")
+	assert.Contains(out.String(), "Region: us-east-1\nNo results in the baseline\n")
+	assert.Contains(out.String(), "p95                 1.000s      1.000s      +0.000s      +0.0%\n")
+}
+
+func TestCandidateWithoutRequestsIsRegression(t *testing.T) {
+	assert := assert.New(t)
+	data := aggData(10, 1, time.Second, map[string]int{"200": 10})
+	empty := aggData(0, 0, 0, map[string]int{})
+	baseline := document(map[string]result.AggData{"eu-west-1": data}, data)
+	candidate := document(map[string]result.AggData{"eu-west-1": empty}, empty)
+
+	comparison := Compare(baseline, candidate, DefaultTolerances)
+	assert.Equal([]string{"eu-west-1:requests", "overall:requests"}, comparison.Regressions())
+
+	var out bytes.Buffer
+	Write(&out, comparison)
+	assert.Contains(out.String(), "Region: eu-west-1\nNo requests in the candidate  REGRESSION\n")
+
+	assert.Empty(Compare(candidate, candidate, DefaultTolerances).Regressions(), "without requests in the baseline there is nothing to regress")
+}
@@ -0,0 +1,196 @@
+// Package export streams the results of a running test to metrics systems,
+// so the load of goad can be shown next to the metrics of the servers under
+// test
+package export
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/goadapp/goad/goad/histogram"
+	"github.com/goadapp/goad/goad/types"
+	"github.com/goadapp/goad/result"
+)
+
+// requestTimeout limits every push to a metrics system
+const requestTimeout = 5 * time.Second
+
+// Sample holds the metrics of a region at a point of the test
+type Sample struct {
+	TestID string
+	Region string
+	Time   time.Time
+
+	// Requests, errors and statuses are counted since the start of the test
+	Requests         int
+	Errors           int
+	TimedOut         int
+	ConnectionErrors int
+	Statuses         map[string]int
+
+	// ReqPerSec and Latency are the ones of the last complete interval of
+	// the time series, or of the whole test until an interval completed
+	ReqPerSec float64
+	Latency   histogram.Percentiles
+}
+
+// Exporter pushes samples of all regions to a metrics system
+type Exporter interface {
+	Export(samples []Sample) error
+}
+
+// New returns the exporters configured for the test
+func New(config types.ExportConfig) []Exporter {
+	client := &http.Client{Timeout: requestTimeout}
+	var exporters []Exporter
+	if config.InfluxDBURL != "" {
+		exporters = append(exporters, &influxDB{client: client, url: config.InfluxDBURL, token: config.InfluxDBToken})
+	}
+	if config.PushgatewayURL != "" {
+		exporters = append(exporters, &pushgateway{client: client, url: config.PushgatewayURL})
+	}
+	if config.StatsDAddress != "" {
+		exporters = append(exporters, &statsD{address: config.StatsDAddress})
+	}
+	return exporters
+}
+
+// Samples returns a sample of every region of the results
+func Samples(testID string, results *result.LambdaResults, now time.Time) []Sample {
+	regionsData := results.RegionsData()
+	samples := make([]Sample, 0, len(regionsData))
+	for _, region := range results.Regions() {
+		data := regionsData[region]
+		sample := Sample{
+			TestID:           testID,
+			Region:           region,
+			Time:             now,
+			Requests:         data.TotalReqs,
+			Errors:           result.Errors(data),
+			TimedOut:         data.TotalTimedOut,
+			ConnectionErrors: data.TotalConnectionError,
+			Statuses:         data.Statuses,
+			ReqPerSec:        data.AveReqPerSec,
+			Latency:          data.TimeForReqPercentiles,
+		}
+		// the last interval is still filling up
+		if len(data.Series) > 1 {
+			interval := data.Series[len(data.Series)-2]
+			sample.ReqPerSec = interval.ReqPerSec()
+			sample.Latency = interval.TimeForReqPercentiles
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// Feed passes the results of a test on and exports samples of them every
+// interval and once more when the test finished. The samples are taken
+// before the results are passed on, the feed never reads results the
+// receiver may be working with. Exports run in the background, a slow
+// metrics system skips samples instead of delaying the results.
+type Feed struct {
+	testID    string
+	interval  time.Duration
+	exporters []Exporter
+
+	done      chan struct{}
+	closeOnce sync.Once
+	finished  chan struct{}
+
+	mu       sync.Mutex
+	failures int
+	firstErr error
+}
+
+// NewFeed returns a feed exporting every interval
+func NewFeed(testID string, interval time.Duration, exporters []Exporter) *Feed {
+	return &Feed{
+		testID:    testID,
+		interval:  interval,
+		exporters: exporters,
+		done:      make(chan struct{}),
+		finished:  make(chan struct{}),
+	}
+}
+
+// Run passes the results on until they are closed or the feed is closed
+func (f *Feed) Run(results <-chan *result.LambdaResults) <-chan *result.LambdaResults {
+	out := make(chan *result.LambdaResults)
+	go func() {
+		defer close(f.finished)
+		pending := make(chan []Sample, 1)
+		exported := make(chan struct{})
+		go func() {
+			for samples := range pending {
+				f.export(samples)
+			}
+			close(exported)
+		}()
+
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+		var latest []Sample
+	loop:
+		for {
+			select {
+			case data, ok := <-results:
+				if !ok {
+					break loop
+				}
+				latest = Samples(f.testID, data, time.Now())
+				select {
+				case out <- data:
+				case <-f.done:
+					break loop
+				}
+			case <-ticker.C:
+				if len(latest) == 0 {
+					continue
+				}
+				select {
+				case pending <- latest:
+				default:
+				}
+			case <-f.done:
+				break loop
+			}
+		}
+		close(out)
+		close(pending)
+		<-exported
+		f.export(latest)
+	}()
+	return out
+}
+
+// Close stops passing results on, waits for the last export and returns an
+// error if exports failed
+func (f *Feed) Close() error {
+	f.closeOnce.Do(func() { close(f.done) })
+	<-f.finished
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d exports of metrics failed, the first with: %s", f.failures, f.firstErr)
+}
+
+func (f *Feed) export(samples []Sample) {
+	if len(samples) == 0 {
+		return
+	}
+	for _, exporter := range f.exporters {
+		if err := exporter.Export(samples); err != nil {
+			f.mu.Lock()
+			if f.failures == 0 {
+				f.firstErr = err
+			}
+			f.failures++
+			f.mu.Unlock()
+		}
+	}
+}
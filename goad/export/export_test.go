@@ -0,0 +1,116 @@
+package export
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goadapp/goad/api"
+	"github.com/goadapp/goad/goad/histogram"
+	"github.com/goadapp/goad/result"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingExporter keeps the samples of every export
+type recordingExporter struct {
+	mu      sync.Mutex
+	exports [][]Sample
+	err     error
+}
+
+func (r *recordingExporter) Export(samples []Sample) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exports = append(r.exports, samples)
+	return r.err
+}
+
+func testResults(requests int) *result.LambdaResults {
+	results := result.SetupRegionsAggData(2)
+	for runnerID, region := range []string{"eu-west-1", "us-east-1"} {
+		timeForReq := histogram.New()
+		timeForReq.Record(int64(100 * time.Millisecond))
+		results.AddRunnerResult(&api.RunnerResult{
+			RunnerID:            runnerID,
+			Region:              region,
+			RequestCount:        requests,
+			TimedOut:            1,
+			Statuses:            map[string]int{"200": requests - 2, "503": 1},
+			TimeDelta:           time.Second,
+			TimeForReqHistogram: timeForReq,
+		}, results.Started)
+	}
+	return results
+}
+
+func TestSamplesPerRegion(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	samples := Samples("release-1.2", testResults(10), now)
+
+	assert.Len(samples, 2)
+	sample := samples[0]
+	assert.Equal("release-1.2", sample.TestID)
+	assert.Equal("eu-west-1", sample.Region)
+	assert.Equal(now, sample.Time)
+	assert.Equal(10, sample.Requests)
+	assert.Equal(2, sample.Errors)
+	assert.Equal(1, sample.TimedOut)
+	assert.Equal(map[string]int{"200": 8, "503": 1}, sample.Statuses)
+	assert.Equal(10.0, sample.ReqPerSec)
+}
+
+func TestFeedPassesResultsOnAndExportsTheLastOnes(t *testing.T) {
+	assert := assert.New(t)
+	exporter := &recordingExporter{}
+	feed := NewFeed("release-1.2", time.Hour, []Exporter{exporter})
+	results := make(chan *result.LambdaResults)
+	out := feed.Run(results)
+
+	go func() {
+		results <- testResults(10)
+		results <- testResults(20)
+		close(results)
+	}()
+	var received int
+	for range out {
+		received++
+	}
+	assert.NoError(feed.Close())
+	assert.Equal(2, received)
+	assert.Len(exporter.exports, 1, "the results are exported once the test finished")
+	assert.Equal(20, exporter.exports[0][0].Requests)
+}
+
+func TestFeedReportsFailedExports(t *testing.T) {
+	exporter := &recordingExporter{err: errors.New("connection refused")}
+	feed := NewFeed("release-1.2", time.Hour, []Exporter{exporter})
+	results := make(chan *result.LambdaResults, 1)
+	out := feed.Run(results)
+	results <- testResults(10)
+	<-out
+
+	assert.EqualError(t, feed.Close(), "1 exports of metrics failed, the first with: connection refused")
+}
+
+func TestFeedDoesNotReadResultsPassedOn(t *testing.T) {
+	exporter := &recordingExporter{}
+	feed := NewFeed("release-1.2", time.Millisecond, []Exporter{exporter})
+	results := make(chan *result.LambdaResults)
+	out := feed.Run(results)
+
+	data := testResults(10)
+	go func() { results <- data }()
+	<-out
+	// the receiver owns the results now while the feed keeps exporting
+	for i := 0; i < 100; i++ {
+		data.AddRunnerResult(&api.RunnerResult{RunnerID: 0, Region: "eu-west-1", RequestCount: 1, Statuses: map[string]int{"200": 1}}, data.Started)
+		time.Sleep(50 * time.Microsecond)
+	}
+	close(results)
+	for range out {
+	}
+	assert.NoError(t, feed.Close())
+	assert.Equal(t, 10, exporter.exports[len(exporter.exports)-1][0].Requests)
+}
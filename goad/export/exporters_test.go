@@ -0,0 +1,115 @@
+package export
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goadapp/goad/goad/histogram"
+	"github.com/goadapp/goad/goad/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSample() Sample {
+	return Sample{
+		TestID:    "release 1.2",
+		Region:    "eu-west-1",
+		Time:      time.Unix(1500000000, 0),
+		Requests:  100,
+		Errors:    3,
+		TimedOut:  1,
+		Statuses:  map[string]int{"200": 97, "503": 3},
+		ReqPerSec: 12.5,
+		Latency:   histogram.Percentiles{P50: int64(100 * time.Millisecond), P95: int64(250 * time.Millisecond), P99: int64(time.Second)},
+	}
+}
+
+// captureRequest returns a server recording the last request and its body
+func captureRequest(status int) (*httptest.Server, *http.Request, *string) {
+	var request http.Request
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = *r
+		content, _ := ioutil.ReadAll(r.Body)
+		body = string(content)
+		w.WriteHeader(status)
+	}))
+	return server, &request, &body
+}
+
+func TestInfluxDBLineProtocol(t *testing.T) {
+	assert := assert.New(t)
+	server, request, body := captureRequest(http.StatusNoContent)
+	defer server.Close()
+	exporters := New(types.ExportConfig{InfluxDBURL: server.URL + "/write?db=goad", InfluxDBToken: "secret"})
+
+	assert.NoError(exporters[0].Export([]Sample{testSample()}))
+	assert.Equal("POST", request.Method)
+	assert.Equal("/write?db=goad", request.URL.String())
+	assert.Equal("Token secret", request.Header.Get("Authorization"))
+	assert.Equal(`goad,region=eu-west-1,test_id=release\ 1.2 requests=100i,errors=3i,timeouts=1i,connection_errors=0i,rps=12.5,latency_p50=0.1,latency_p95=0.25,latency_p99=1 1500000000000000000
+goad_status,region=eu-west-1,status=200,test_id=release\ 1.2 count=97i 1500000000000000000
+goad_status,region=eu-west-1,status=503,test_id=release\ 1.2 count=3i 1500000000000000000
+`, *body)
+}
+
+func TestPushgatewayExposition(t *testing.T) {
+	assert := assert.New(t)
+	server, request, body := captureRequest(http.StatusOK)
+	defer server.Close()
+	exporters := New(types.ExportConfig{PushgatewayURL: server.URL + "/"})
+
+	assert.NoError(exporters[0].Export([]Sample{testSample()}))
+	assert.Equal("PUT", request.Method)
+	assert.Equal("/metrics/job/goad/test_id/release%201.2", request.URL.EscapedPath())
+	assert.Contains(*body, "# TYPE goad_requests_total counter\ngoad_requests_total{region=\"eu-west-1\"} 100\n")
+	assert.Contains(*body, "goad_responses_total{region=\"eu-west-1\",status=\"503\"} 3\n")
+	assert.Contains(*body, "goad_requests_per_second{region=\"eu-west-1\"} 12.5\n")
+	assert.Contains(*body, "goad_latency_seconds{region=\"eu-west-1\",quantile=\"0.95\"} 0.25\n")
+}
+
+func TestFailedPushIsReported(t *testing.T) {
+	server, _, _ := captureRequest(http.StatusBadRequest)
+	defer server.Close()
+	exporters := New(types.ExportConfig{PushgatewayURL: server.URL})
+
+	err := exporters[0].Export([]Sample{testSample()})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "400 Bad Request")
+}
+
+func TestStatsDSendsIncrements(t *testing.T) {
+	assert := assert.New(t)
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(err)
+	defer listener.Close()
+	exporters := New(types.ExportConfig{StatsDAddress: listener.LocalAddr().String()})
+
+	first := testSample()
+	second := testSample()
+	second.Requests = 150
+	second.Statuses = map[string]int{"200": 147, "503": 3}
+	assert.NoError(exporters[0].Export([]Sample{first}))
+	assert.NoError(exporters[0].Export([]Sample{second}))
+
+	var lines []string
+	buffer := make([]byte, 512)
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		n, _, err := listener.ReadFrom(buffer)
+		if err != nil {
+			break
+		}
+		lines = append(lines, string(buffer[:n]))
+	}
+	assert.Len(lines, 20)
+	assert.Equal("goad.release_1_2.eu-west-1.requests:100|c", lines[0])
+	assert.Equal("goad.release_1_2.eu-west-1.requests:50|c", lines[10])
+	assert.Contains(lines, "goad.release_1_2.eu-west-1.status.200:50|c")
+	assert.Contains(lines, "goad.release_1_2.eu-west-1.latency.p95:250|g")
+	assert.True(strings.HasSuffix(lines[19], "latency.p99:1000|g"))
+}
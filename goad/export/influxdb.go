@@ -0,0 +1,95 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// influxDB writes samples in the line protocol to the write endpoint of an
+// InfluxDB, eg. http://localhost:8086/write?db=goad or the /api/v2/write
+// endpoint with a token
+type influxDB struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+func (i *influxDB) Export(samples []Sample) error {
+	var body bytes.Buffer
+	for _, sample := range samples {
+		writeLines(&body, sample)
+	}
+	header := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+	if i.token != "" {
+		header.Set("Authorization", "Token "+i.token)
+	}
+	return send(i.client, "POST", i.url, header, &body)
+}
+
+// writeLines writes the measurement of the region and one per status code
+func writeLines(w io.Writer, sample Sample) {
+	timestamp := sample.Time.UnixNano()
+	fmt.Fprintf(w, "goad%s requests=%di,errors=%di,timeouts=%di,connection_errors=%di,rps=%g,latency_p50=%g,latency_p95=%g,latency_p99=%g %d\n",
+		lineTags(sample, ""), sample.Requests, sample.Errors, sample.TimedOut, sample.ConnectionErrors, sample.ReqPerSec,
+		seconds(sample.Latency.P50), seconds(sample.Latency.P95), seconds(sample.Latency.P99), timestamp)
+	for _, status := range statusCodes(sample.Statuses) {
+		fmt.Fprintf(w, "goad_status%s count=%di %d\n", lineTags(sample, status), sample.Statuses[status], timestamp)
+	}
+}
+
+// lineTags returns the tags sorted by key as recommended for the line
+// protocol
+func lineTags(sample Sample, status string) string {
+	tags := ",region=" + escapeTag(sample.Region)
+	if status != "" {
+		tags += ",status=" + escapeTag(status)
+	}
+	return tags + ",test_id=" + escapeTag(sample.TestID)
+}
+
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+func escapeTag(value string) string {
+	return tagEscaper.Replace(value)
+}
+
+func seconds(nanos int64) float64 {
+	return float64(nanos) / float64(time.Second)
+}
+
+// statusCodes returns the status codes in ascending order
+func statusCodes(statuses map[string]int) []string {
+	codes := make([]string, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// send makes a request to a metrics system and fails unless it succeeded
+func send(client *http.Client, method, url string, header http.Header, body io.Reader) error {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s %s", method, url, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// pushgateway replaces the metrics of the test at a Prometheus Pushgateway,
+// they are grouped by job "goad" and the test id
+type pushgateway struct {
+	client *http.Client
+	url    string
+}
+
+// quantiles are the latency percentiles pushed as summary quantiles
+var quantiles = []struct {
+	label string
+	value func(Sample) int64
+}{
+	{"0.5", func(s Sample) int64 { return s.Latency.P50 }},
+	{"0.95", func(s Sample) int64 { return s.Latency.P95 }},
+	{"0.99", func(s Sample) int64 { return s.Latency.P99 }},
+}
+
+func (p *pushgateway) Export(samples []Sample) error {
+	var body bytes.Buffer
+	writeExposition(&body, samples)
+	target := fmt.Sprintf("%s/metrics/job/goad/test_id/%s", strings.TrimRight(p.url, "/"), url.PathEscape(samples[0].TestID))
+	header := http.Header{"Content-Type": {"text/plain; version=0.0.4"}}
+	return send(p.client, "PUT", target, header, &body)
+}
+
+// writeExposition writes the samples in the Prometheus text format, every
+// metric family once with a series per region
+func writeExposition(w io.Writer, samples []Sample) {
+	counters := []struct {
+		name  string
+		help  string
+		value func(Sample) int
+	}{
+		{"goad_requests_total", "Requests completed", func(s Sample) int { return s.Requests }},
+		{"goad_errors_total", "Requests that failed", func(s Sample) int { return s.Errors }},
+		{"goad_timeouts_total", "Requests that timed out", func(s Sample) int { return s.TimedOut }},
+		{"goad_connection_errors_total", "Requests that could not connect", func(s Sample) int { return s.ConnectionErrors }},
+	}
+	for _, counter := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", counter.name, counter.help, counter.name)
+		for _, sample := range samples {
+			fmt.Fprintf(w, "%s{region=%q} %d\n", counter.name, sample.Region, counter.value(sample))
+		}
+	}
+	fmt.Fprintf(w, "# HELP goad_responses_total Responses per status code\n# TYPE goad_responses_total counter\n")
+	for _, sample := range samples {
+		for _, status := range statusCodes(sample.Statuses) {
+			fmt.Fprintf(w, "goad_responses_total{region=%q,status=%q} %d\n", sample.Region, status, sample.Statuses[status])
+		}
+	}
+	fmt.Fprintf(w, "# HELP goad_requests_per_second Request rate\n# TYPE goad_requests_per_second gauge\n")
+	for _, sample := range samples {
+		fmt.Fprintf(w, "goad_requests_per_second{region=%q} %g\n", sample.Region, sample.ReqPerSec)
+	}
+	fmt.Fprintf(w, "# HELP goad_latency_seconds Latency of the requests\n# TYPE goad_latency_seconds summary\n")
+	for _, sample := range samples {
+		for _, quantile := range quantiles {
+			fmt.Fprintf(w, "goad_latency_seconds{region=%q,quantile=%q} %g\n", sample.Region, quantile.label, seconds(quantile.value(sample)))
+		}
+	}
+}
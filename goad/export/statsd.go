@@ -0,0 +1,71 @@
+package export
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+)
+
+// statsD sends the samples as UDP datagrams to a StatsD daemon. StatsD has
+// no tags, the test id and the region are part of the metric names:
+// goad.<test id>.<region>.<metric>. Counters are sent as the increase since
+// the last export, rates and latencies as gauges in milliseconds.
+type statsD struct {
+	address string
+	conn    net.Conn
+	// last holds the previous sample of every region
+	last map[string]Sample
+}
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+func (s *statsD) Export(samples []Sample) error {
+	if s.conn == nil {
+		conn, err := net.Dial("udp", s.address)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		s.last = make(map[string]Sample)
+	}
+	for _, sample := range samples {
+		for _, line := range statsDLines(sample, s.last[sample.Region]) {
+			if _, err := s.conn.Write([]byte(line)); err != nil {
+				return err
+			}
+		}
+		s.last[sample.Region] = sample
+	}
+	return nil
+}
+
+// statsDLines returns the metrics of the sample, counters relative to the
+// previous sample of the region
+func statsDLines(sample, previous Sample) []string {
+	prefix := fmt.Sprintf("goad.%s.%s.", statsDName(sample.TestID), statsDName(sample.Region))
+	lines := []string{
+		fmt.Sprintf("%srequests:%d|c", prefix, sample.Requests-previous.Requests),
+		fmt.Sprintf("%serrors:%d|c", prefix, sample.Errors-previous.Errors),
+		fmt.Sprintf("%stimeouts:%d|c", prefix, sample.TimedOut-previous.TimedOut),
+		fmt.Sprintf("%sconnection_errors:%d|c", prefix, sample.ConnectionErrors-previous.ConnectionErrors),
+	}
+	for _, status := range statusCodes(sample.Statuses) {
+		lines = append(lines, fmt.Sprintf("%sstatus.%s:%d|c", prefix, statsDName(status), sample.Statuses[status]-previous.Statuses[status]))
+	}
+	lines = append(lines,
+		fmt.Sprintf("%srps:%g|g", prefix, sample.ReqPerSec),
+		fmt.Sprintf("%slatency.p50:%g|g", prefix, milliseconds(sample.Latency.P50)),
+		fmt.Sprintf("%slatency.p95:%g|g", prefix, milliseconds(sample.Latency.P95)),
+		fmt.Sprintf("%slatency.p99:%g|g", prefix, milliseconds(sample.Latency.P99)),
+	)
+	return lines
+}
+
+// statsDName replaces the characters with a meaning in StatsD
+func statsDName(name string) string {
+	return invalidNameChars.ReplaceAllString(name, "_")
+}
+
+func milliseconds(nanos int64) float64 {
+	return float64(nanos) / 1e6
+}
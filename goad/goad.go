@@ -5,14 +5,18 @@ import (
 	"github.com/goadapp/goad/infrastructure"
 	"github.com/goadapp/goad/infrastructure/aws"
 	"github.com/goadapp/goad/infrastructure/docker"
+	"github.com/goadapp/goad/infrastructure/local"
 	"github.com/goadapp/goad/result"
 )
 
-// Start a test
-func Start(t *types.TestConfig) (<-chan *result.LambdaResults, func()) {
+// Start a test, a failure while receiving the results is sent on the error
+// channel once the results are closed
+func Start(t *types.TestConfig) (<-chan *result.LambdaResults, <-chan error, func()) {
 
 	var infra infrastructure.Infrastructure
-	if t.RunDocker {
+	if t.RunLocal {
+		infra = localinfra.New(t)
+	} else if t.RunDocker {
 		infra = dockerinfra.New(t)
 	} else {
 		infra = awsinfra.New(t)
@@ -21,17 +25,8 @@ func Start(t *types.TestConfig) (<-chan *result.LambdaResults, func()) {
 	HandleErr(err)
 	t.Lambdas = numberOfLambdas(t.Concurrency, len(t.Regions))
 	infrastructure.InvokeLambdas(infra)
-
-	results := make(chan *result.LambdaResults)
-
-	go func() {
-		for result := range infrastructure.Aggregate(infra) {
-			results <- result
-		}
-		close(results)
-	}()
-
-	return results, teardown
+	results, errs := infrastructure.Aggregate(infra)
+	return results, errs, teardown
 }
 
 func HandleErr(err error) {
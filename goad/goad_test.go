@@ -0,0 +1,43 @@
+package goad
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goadapp/goad/goad/types"
+	"github.com/goadapp/goad/result"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartRunsLocally(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	config := &types.TestConfig{
+		URL:         server.URL,
+		Concurrency: 2,
+		Requests:    20,
+		Timeout:     5,
+		Method:      "GET",
+		Regions:     []string{"local"},
+		AbortPolicy: types.DefaultAbortPolicy,
+		RunLocal:    true,
+	}
+	results, errs, teardown := Start(config)
+	defer teardown()
+
+	var last *result.LambdaResults
+	for current := range results {
+		last = current
+	}
+	if assert.NotNil(t, last) {
+		sum := last.SumAllLambdas()
+		assert.True(t, sum.Finished)
+		assert.Equal(t, 20, sum.TotalReqs)
+		assert.Equal(t, 20, sum.Statuses["200"])
+	}
+	assert.NoError(t, <-errs)
+}
@@ -0,0 +1,190 @@
+package histogram
+
+import (
+	"sort"
+)
+
+const (
+	// precisionBits controls the number of linear sub-buckets per power of two,
+	// 2^7 sub-buckets keep the relative error of a recorded value below 1%.
+	precisionBits = 7
+	subBuckets    = 1 << precisionBits
+)
+
+// Histogram is a compact, mergeable log-linear histogram in the spirit of
+// HdrHistogram. Values below 2*subBuckets are stored exactly, larger values
+// are grouped in buckets whose width grows with each power of two. Only
+// non-empty buckets are kept, so the histogram stays small enough to be
+// shipped with every runner report.
+type Histogram struct {
+	Counts map[int]int64 `json:"counts"`
+	Total  int64         `json:"total"`
+	Min    int64         `json:"min"`
+	Max    int64         `json:"max"`
+}
+
+// Percentiles summarizes a histogram with the quantiles goad reports.
+type Percentiles struct {
+	P50  int64 `json:"p50"`
+	P90  int64 `json:"p90"`
+	P95  int64 `json:"p95"`
+	P99  int64 `json:"p99"`
+	P999 int64 `json:"p99.9"`
+}
+
+// New creates an empty histogram
+func New() *Histogram {
+	return &Histogram{
+		Counts: make(map[int]int64),
+	}
+}
+
+// Record adds a single value, negative values are recorded as 0
+func (h *Histogram) Record(value int64) {
+	if value < 0 {
+		value = 0
+	}
+	if h.Counts == nil {
+		h.Counts = make(map[int]int64)
+	}
+	h.Counts[bucketIndex(value)]++
+	if h.Total == 0 || value < h.Min {
+		h.Min = value
+	}
+	if value > h.Max {
+		h.Max = value
+	}
+	h.Total++
+}
+
+// Merge adds all values recorded in other to h
+func (h *Histogram) Merge(other *Histogram) {
+	if other == nil || other.Total == 0 {
+		return
+	}
+	if h.Counts == nil {
+		h.Counts = make(map[int]int64)
+	}
+	for index, count := range other.Counts {
+		h.Counts[index] += count
+	}
+	if h.Total == 0 || other.Min < h.Min {
+		h.Min = other.Min
+	}
+	if other.Max > h.Max {
+		h.Max = other.Max
+	}
+	h.Total += other.Total
+}
+
+// Count returns the number of recorded values
+func (h *Histogram) Count() int64 {
+	if h == nil {
+		return 0
+	}
+	return h.Total
+}
+
+// Quantile returns the value below which the fraction q (0 - 1) of all
+// recorded values fall.
+func (h *Histogram) Quantile(q float64) int64 {
+	if h == nil || h.Total == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.Min
+	}
+	if q >= 1 {
+		return h.Max
+	}
+	rank := int64(q*float64(h.Total) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for _, index := range h.sortedIndexes() {
+		seen += h.Counts[index]
+		if seen >= rank {
+			return h.clamp(bucketValue(index))
+		}
+	}
+	return h.Max
+}
+
+// Percentiles calculates the standard set of quantiles
+func (h *Histogram) Percentiles() Percentiles {
+	return Percentiles{
+		P50:  h.Quantile(0.5),
+		P90:  h.Quantile(0.9),
+		P95:  h.Quantile(0.95),
+		P99:  h.Quantile(0.99),
+		P999: h.Quantile(0.999),
+	}
+}
+
+// Buckets returns the lower bound and count of every non-empty bucket in
+// ascending order.
+func (h *Histogram) Buckets() (values []int64, counts []int64) {
+	if h == nil {
+		return
+	}
+	for _, index := range h.sortedIndexes() {
+		values = append(values, bucketLowerBound(index))
+		counts = append(counts, h.Counts[index])
+	}
+	return
+}
+
+func (h *Histogram) sortedIndexes() []int {
+	indexes := make([]int, 0, len(h.Counts))
+	for index := range h.Counts {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+func (h *Histogram) clamp(value int64) int64 {
+	if value < h.Min {
+		return h.Min
+	}
+	if value > h.Max {
+		return h.Max
+	}
+	return value
+}
+
+func bitLength(value int64) uint {
+	var length uint
+	for ; value > 0; value >>= 1 {
+		length++
+	}
+	return length
+}
+
+func bucketIndex(value int64) int {
+	if value < 2*subBuckets {
+		return int(value)
+	}
+	shift := bitLength(value) - (precisionBits + 1)
+	return int(shift+1)*subBuckets + int(value>>shift) - subBuckets
+}
+
+func bucketLowerBound(index int) int64 {
+	if index < 2*subBuckets {
+		return int64(index)
+	}
+	shift := uint(index/subBuckets - 1)
+	mantissa := int64(index%subBuckets + subBuckets)
+	return mantissa << shift
+}
+
+// bucketValue returns the midpoint of the bucket as its representative value
+func bucketValue(index int) int64 {
+	if index < 2*subBuckets {
+		return int64(index)
+	}
+	shift := uint(index/subBuckets - 1)
+	lower := bucketLowerBound(index)
+	return lower + (int64(1)<<shift)/2
+}
@@ -0,0 +1,70 @@
+package histogram
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketIndexRoundTrip(t *testing.T) {
+	for _, value := range []int64{0, 1, 255, 256, 257, 1000, 123456789, math.MaxInt64 / 2} {
+		index := bucketIndex(value)
+		lower := bucketLowerBound(index)
+		if lower > value {
+			t.Errorf("lower bound %d of bucket %d is above recorded value %d", lower, index, value)
+		}
+		if float64(value-lower) > float64(value)/subBuckets {
+			t.Errorf("bucket %d is too coarse for value %d (lower bound %d)", index, value, lower)
+		}
+	}
+}
+
+func TestQuantiles(t *testing.T) {
+	assert := assert.New(t)
+	h := New()
+	for i := int64(1); i <= 10000; i++ {
+		h.Record(i * 1000)
+	}
+	assert.Equal(int64(10000), h.Count())
+	assert.Equal(int64(1000), h.Min)
+	assert.Equal(int64(10000000), h.Max)
+	assert.InEpsilon(5000000, h.Quantile(0.5), 0.01)
+	assert.InEpsilon(9900000, h.Quantile(0.99), 0.01)
+	assert.Equal(h.Min, h.Quantile(0))
+	assert.Equal(h.Max, h.Quantile(1))
+}
+
+func TestMergeEqualsRecordingAll(t *testing.T) {
+	assert := assert.New(t)
+	all, first, second := New(), New(), New()
+	for i := int64(0); i < 5000; i++ {
+		all.Record(i * 37)
+		if i%2 == 0 {
+			first.Record(i * 37)
+		} else {
+			second.Record(i * 37)
+		}
+	}
+	first.Merge(second)
+	assert.Equal(all.Percentiles(), first.Percentiles())
+	assert.Equal(all.Count(), first.Count())
+}
+
+func TestEmptyHistogram(t *testing.T) {
+	var h *Histogram
+	assert.Equal(t, Percentiles{}, h.Percentiles())
+	assert.Equal(t, int64(0), New().Quantile(0.5))
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	h := New()
+	h.Record(42)
+	h.Record(4200000)
+	b, err := json.Marshal(h)
+	assert.NoError(t, err)
+	decoded := &Histogram{}
+	assert.NoError(t, json.Unmarshal(b, decoded))
+	assert.Equal(t, h.Percentiles(), decoded.Percentiles())
+}
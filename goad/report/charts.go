@@ -0,0 +1,185 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"time"
+
+	"github.com/goadapp/goad/goad/histogram"
+	"github.com/goadapp/goad/result"
+)
+
+// Charts are drawn as inline SVG so the report needs no scripts or fonts
+// from the network
+const (
+	chartWidth  = 720
+	chartHeight = 240
+	marginLeft  = 70
+	marginRight = 20
+	marginTop   = 30
+	marginBelow = 30
+	plotWidth   = chartWidth - marginLeft - marginRight
+	plotHeight  = chartHeight - marginTop - marginBelow
+)
+
+var seriesColors = []string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e"}
+
+type line struct {
+	Name   string
+	Values []float64
+}
+
+// throughputChart draws the rate of requests and errors per interval
+func throughputChart(series []result.Interval) template.HTML {
+	requests := line{Name: "requests/s"}
+	errors := line{Name: "errors/s"}
+	for _, interval := range series {
+		requests.Values = append(requests.Values, interval.ReqPerSec())
+		var errorRate float64
+		if interval.Length > 0 {
+			errorRate = float64(interval.Errors) / interval.Length.Seconds()
+		}
+		errors.Values = append(errors.Values, errorRate)
+	}
+	return lineChart("Throughput", series, []line{requests, errors}, func(v float64) string {
+		return fmt.Sprintf("%.1f/s", v)
+	})
+}
+
+// latencyChart draws the latency percentiles per interval
+func latencyChart(series []result.Interval) template.HTML {
+	p50 := line{Name: "p50"}
+	p95 := line{Name: "p95"}
+	p99 := line{Name: "p99"}
+	for _, interval := range series {
+		p := interval.TimeForReqPercentiles
+		p50.Values = append(p50.Values, float64(p.P50))
+		p95.Values = append(p95.Values, float64(p.P95))
+		p99.Values = append(p99.Values, float64(p.P99))
+	}
+	return lineChart("Latency", series, []line{p50, p95, p99}, func(v float64) string {
+		return formatSeconds(int64(v))
+	})
+}
+
+// lineChart draws one line per series of values over the intervals
+func lineChart(title string, intervals []result.Interval, lines []line, format func(float64) string) template.HTML {
+	var max float64
+	for _, l := range lines {
+		for _, value := range l.Values {
+			max = math.Max(max, value)
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+	x := func(i int) float64 {
+		if len(intervals) < 2 {
+			return marginLeft + plotWidth/2
+		}
+		return marginLeft + float64(i)*plotWidth/float64(len(intervals)-1)
+	}
+	y := func(value float64) float64 {
+		return marginTop + plotHeight - value/max*plotHeight
+	}
+
+	var svg bytes.Buffer
+	openChart(&svg, title)
+	yAxis(&svg, max, format)
+	for _, i := range []int{0, len(intervals) / 2, len(intervals) - 1} {
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x(i), chartHeight-10, intervals[i].Offset)
+	}
+	for n, l := range lines {
+		color := seriesColors[n%len(seriesColors)]
+		fmt.Fprintf(&svg, `<text x="%d" y="18" fill="%s">%s</text>`, marginLeft+n*110, color, template.HTMLEscapeString(l.Name))
+		fmt.Fprintf(&svg, `<polyline fill="none" stroke="%s" stroke-width="2" points="`, color)
+		for i, value := range l.Values {
+			fmt.Fprintf(&svg, "%.1f,%.1f ", x(i), y(value))
+		}
+		svg.WriteString(`"/>`)
+		for i, value := range l.Values {
+			fmt.Fprintf(&svg, `<circle cx="%.1f" cy="%.1f" r="2.5" fill="%s"><title>%s %s: %s</title></circle>`,
+				x(i), y(value), color, intervals[i].Offset, template.HTMLEscapeString(l.Name), format(value))
+		}
+	}
+	svg.WriteString("</svg>")
+	return template.HTML(svg.String())
+}
+
+// latencyDistribution draws the number of requests per latency range, the
+// ranges double from 1ms on
+func latencyDistribution(h *histogram.Histogram) template.HTML {
+	values, counts := h.Buckets()
+	bins := make(map[int]int64)
+	first, last := math.MaxInt32, math.MinInt32
+	for i, value := range values {
+		bin := latencyBin(value)
+		bins[bin] += counts[i]
+		if bin < first {
+			first = bin
+		}
+		if bin > last {
+			last = bin
+		}
+	}
+	var svg bytes.Buffer
+	openChart(&svg, "Latency distribution")
+	if len(bins) == 0 {
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="middle">No requests</text></svg>`, chartWidth/2, chartHeight/2)
+		return template.HTML(svg.String())
+	}
+	var max int64
+	for _, count := range bins {
+		if count > max {
+			max = count
+		}
+	}
+	yAxis(&svg, float64(max), func(v float64) string { return fmt.Sprintf("%.0f", v) })
+	barWidth := float64(plotWidth) / float64(last-first+1)
+	for bin := first; bin <= last; bin++ {
+		height := float64(bins[bin]) / float64(max) * plotHeight
+		x := marginLeft + float64(bin-first)*barWidth
+		fmt.Fprintf(&svg, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %d requests</title></rect>`,
+			x+1, marginTop+plotHeight-height, math.Max(barWidth-2, 1), height, seriesColors[0], binLabel(bin), bins[bin])
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x+barWidth/2, chartHeight-10, binLabel(bin))
+	}
+	svg.WriteString("</svg>")
+	return template.HTML(svg.String())
+}
+
+// latencyBin returns the bin of a latency, bin 0 holds values below 1ms and
+// bin n values from 2^(n-1)ms on
+func latencyBin(nanos int64) int {
+	if nanos < int64(time.Millisecond) {
+		return 0
+	}
+	return int(math.Log2(float64(nanos)/float64(time.Millisecond))) + 1
+}
+
+func binLabel(bin int) string {
+	if bin == 0 {
+		return "<1ms"
+	}
+	lower := time.Duration(1<<uint(bin-1)) * time.Millisecond
+	if lower < time.Second {
+		return fmt.Sprintf("%dms", lower/time.Millisecond)
+	}
+	return fmt.Sprintf("%.3gs", lower.Seconds())
+}
+
+func openChart(svg *bytes.Buffer, title string) {
+	fmt.Fprintf(svg, `<svg class="chart" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg" role="img"><title>%s</title>`,
+		chartWidth, chartHeight, template.HTMLEscapeString(title))
+	fmt.Fprintf(svg, `<text class="title" x="%d" y="18" text-anchor="end">%s</text>`, chartWidth-marginRight, template.HTMLEscapeString(title))
+}
+
+// yAxis draws grid lines at zero, half of max and max
+func yAxis(svg *bytes.Buffer, max float64, format func(float64) string) {
+	for _, share := range []float64{0, 0.5, 1} {
+		y := marginTop + plotHeight - share*plotHeight
+		fmt.Fprintf(svg, `<line class="grid" x1="%d" y1="%.1f" x2="%d" y2="%.1f"/>`, marginLeft, y, chartWidth-marginRight, y)
+		fmt.Fprintf(svg, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`, marginLeft-6, y+4, format(share*max))
+	}
+}
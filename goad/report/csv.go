@@ -0,0 +1,59 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/goadapp/goad/result"
+)
+
+var csvHeader = []string{
+	"region", "requests", "req_per_sec", "avg_ns", "p50_ns", "p90_ns", "p95_ns",
+	"p99_ns", "p99.9_ns", "fastest_ns", "slowest_ns", "timeouts",
+	"connection_errors", "errors", "bytes_read",
+}
+
+// WriteCSV writes one line of results per region and one for the whole test,
+// durations are in nanoseconds so spreadsheets can compute with them
+func WriteCSV(w io.Writer, doc *Document) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, name := range doc.RegionNames() {
+		if err := out.Write(csvRecord(name, doc.Regions[name])); err != nil {
+			return err
+		}
+	}
+	if err := out.Write(csvRecord("overall", doc.Overall)); err != nil {
+		return err
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func csvRecord(name string, data result.AggData) []string {
+	p := data.TimeForReqPercentiles
+	fastest := data.Fastest
+	if data.TotalReqs == 0 {
+		fastest = 0
+	}
+	return []string{
+		name,
+		strconv.Itoa(data.TotalReqs),
+		strconv.FormatFloat(data.AveReqPerSec, 'f', 2, 64),
+		strconv.FormatInt(data.AveTimeForReq, 10),
+		strconv.FormatInt(p.P50, 10),
+		strconv.FormatInt(p.P90, 10),
+		strconv.FormatInt(p.P95, 10),
+		strconv.FormatInt(p.P99, 10),
+		strconv.FormatInt(p.P999, 10),
+		strconv.FormatInt(fastest, 10),
+		strconv.FormatInt(data.Slowest, 10),
+		strconv.Itoa(data.TotalTimedOut),
+		strconv.Itoa(data.TotalConnectionError),
+		strconv.Itoa(result.Errors(data)),
+		strconv.Itoa(data.TotBytesRead),
+	}
+}
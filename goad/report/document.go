@@ -0,0 +1,164 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goadapp/goad/goad/scenario"
+	"github.com/goadapp/goad/goad/types"
+	"github.com/goadapp/goad/result"
+	"github.com/goadapp/goad/version"
+)
+
+// DocumentVersion is the version of the result documents this goad writes,
+// it is raised on incompatible changes of the layout
+const DocumentVersion = 1
+
+// redactedValue replaces the header values in documents
+const redactedValue = "[redacted]"
+
+// Document holds everything known about a test once it finished, it is
+// written with --json-output and read back to render reports later
+type Document struct {
+	Version     int              `json:"version"`
+	GoadVersion string           `json:"goad-version,omitempty"`
+	Config      types.TestConfig `json:"config"`
+	Started     time.Time        `json:"started"`
+	Finished    time.Time        `json:"finished"`
+	// SeriesInterval is the length of the intervals of the time series
+	SeriesInterval time.Duration             `json:"series-interval"`
+	Runners        []result.AggData          `json:"runners,omitempty"`
+	Regions        map[string]result.AggData `json:"regions"`
+	Overall        result.AggData            `json:"overall"`
+}
+
+// NewDocument returns the document of a test that finished at finished.
+// Documents are shared with people who did not run the test, so the AWS
+// settings and the request bodies are left out, header values are redacted
+// and only the name of the data file is kept.
+func NewDocument(config *types.TestConfig, results result.LambdaResults, finished time.Time) *Document {
+	doc := &Document{
+		Version:        DocumentVersion,
+		GoadVersion:    version.String(),
+		Config:         *config,
+		Started:        results.Started,
+		Finished:       finished,
+		SeriesInterval: results.SeriesInterval,
+		Runners:        results.Lambdas,
+		Regions:        results.RegionsData(),
+		Overall:        results.SumAllLambdas(),
+	}
+	doc.Config.AWS = types.AWSConfig{}
+	doc.Config.Body = ""
+	doc.Config.Headers = redactHeaders(config.Headers)
+	if config.DataFile != "" {
+		doc.Config.DataFile = filepath.Base(config.DataFile)
+	}
+	if config.Scenario != nil {
+		steps := make([]scenario.Step, len(config.Scenario.Steps))
+		for i, step := range config.Scenario.Steps {
+			step.Body = ""
+			step.Headers = redactHeaders(step.Headers)
+			steps[i] = step
+		}
+		doc.Config.Scenario = &scenario.Scenario{Steps: steps}
+	}
+	return doc
+}
+
+// redactHeaders keeps the names of the headers, their values may hold
+// credentials
+func redactHeaders(headers []string) []string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make([]string, len(headers))
+	for i, header := range headers {
+		name := strings.SplitN(header, ":", 2)[0]
+		redacted[i] = strings.TrimSpace(name) + ": " + redactedValue
+	}
+	return redacted
+}
+
+// ReadDocument reads a document written by WriteJSON. The summaries written
+// by goad before documents were versioned are read as well, they only hold
+// the data of the regions and overall.
+func ReadDocument(r io.Reader) (*Document, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var header struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(content, &header); err != nil {
+		return nil, fmt.Errorf("invalid result document: %s", err)
+	}
+	if header.Version == nil {
+		return readSummary(content)
+	}
+	if *header.Version > DocumentVersion {
+		return nil, fmt.Errorf("result document version %d was written by a newer goad, upgrade to read it", *header.Version)
+	}
+	doc := &Document{}
+	if err := json.Unmarshal(content, doc); err != nil {
+		return nil, fmt.Errorf("invalid result document: %s", err)
+	}
+	return doc, nil
+}
+
+// readSummary reads the map of region names to results with the overall
+// results written by earlier versions
+func readSummary(content []byte) (*Document, error) {
+	regions := make(map[string]result.AggData)
+	if err := json.Unmarshal(content, &regions); err != nil {
+		return nil, fmt.Errorf("invalid result document: %s", err)
+	}
+	doc := &Document{Overall: regions["overall"], Regions: regions}
+	delete(regions, "overall")
+	return doc, nil
+}
+
+// WriteJSON writes the document as indented JSON
+func WriteJSON(w io.Writer, doc *Document) error {
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// RegionNames returns the names of the regions in alphabetical order
+func (d *Document) RegionNames() []string {
+	names := make([]string, 0, len(d.Regions))
+	for name := range d.Regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Results returns the results of the runners, documents of earlier versions
+// hold none
+func (d *Document) Results() result.LambdaResults {
+	return result.LambdaResults{
+		Lambdas:        d.Runners,
+		Started:        d.Started,
+		SeriesInterval: d.SeriesInterval,
+	}
+}
+
+// Duration returns how long the test ran, zero if unknown
+func (d *Document) Duration() time.Duration {
+	if d.Started.IsZero() || d.Finished.IsZero() {
+		return 0
+	}
+	return d.Finished.Sub(d.Started)
+}
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goadapp/goad/goad/scenario"
+	"github.com/goadapp/goad/goad/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	config := testConfig()
+	config.AWS = types.AWSConfig{Profile: "load-testing", ExternalID: "secret"}
+	results := testResults()
+	doc := NewDocument(config, results, results.Started.Add(time.Minute))
+	var content bytes.Buffer
+	assert.NoError(WriteJSON(&content, doc))
+	assert.NotContains(content.String(), "secret", "the AWS settings are left out")
+
+	read, err := ReadDocument(&content)
+	assert.NoError(err)
+	assert.Equal(DocumentVersion, read.Version)
+	assert.Equal(config.URL, read.Config.URL)
+	assert.Equal(time.Minute, read.Duration())
+	assert.Equal([]string{"eu-west-1", "us-east-1"}, read.RegionNames())
+	assert.Equal(4, read.Overall.TotalReqs)
+	assert.Len(read.Runners, 2)
+	assert.Equal(doc.Overall.TimeForReqPercentiles, read.Overall.TimeForReqPercentiles)
+	assert.Len(read.Overall.Series, 2)
+}
+
+func TestDocumentLeavesOutCredentials(t *testing.T) {
+	assert := assert.New(t)
+	config := testConfig()
+	config.Headers = []string{"Authorization: Bearer secret-token", "Accept: application/json"}
+	config.Body = `{"password": "secret-password"}`
+	config.DataFile = "/home/tester/users.csv"
+	config.Scenario = &scenario.Scenario{Steps: []scenario.Step{
+		{Name: "login", Method: "POST", URL: "https://example.com/login", Headers: []string{"X-Api-Key: secret-key"}, Body: "user=secret-user"},
+	}}
+	results := testResults()
+	doc := NewDocument(config, results, results.Started.Add(time.Minute))
+	var content bytes.Buffer
+	assert.NoError(WriteJSON(&content, doc))
+
+	assert.NotContains(content.String(), "secret")
+	assert.NotContains(content.String(), "/home/tester")
+	assert.Equal([]string{"Authorization: [redacted]", "Accept: [redacted]"}, doc.Config.Headers)
+	assert.Equal("users.csv", doc.Config.DataFile)
+	assert.Equal([]string{"X-Api-Key: [redacted]"}, doc.Config.Scenario.Steps[0].Headers)
+	assert.Equal("https://example.com/login", doc.Config.Scenario.Steps[0].URL)
+	assert.Equal("Authorization: Bearer secret-token", config.Headers[0], "the config of the test is not changed")
+	assert.Equal("user=secret-user", config.Scenario.Steps[0].Body)
+}
+
+func TestReadSummaryOfEarlierVersions(t *testing.T) {
+	assert := assert.New(t)
+	summary := `{"eu-west-1": {"TotalReqs": 3}, "overall": {"TotalReqs": 3}}`
+	doc, err := ReadDocument(strings.NewReader(summary))
+	assert.NoError(err)
+	assert.Equal(0, doc.Version)
+	assert.Equal([]string{"eu-west-1"}, doc.RegionNames())
+	assert.Equal(3, doc.Overall.TotalReqs)
+}
+
+func TestReadDocumentOfNewerVersionFails(t *testing.T) {
+	_, err := ReadDocument(strings.NewReader(`{"version": 99}`))
+	assert.EqualError(t, err, "result document version 99 was written by a newer goad, upgrade to read it")
+}
@@ -0,0 +1,97 @@
+// Package report renders the results of a test for people who do not run
+// goad themselves
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/goadapp/goad/goad/types"
+	"github.com/goadapp/goad/result"
+)
+
+// htmlReport is the data of the HTML template
+type htmlReport struct {
+	*Document
+	Regions  []regionData
+	Statuses []statusCount
+	Charts   []template.HTML
+}
+
+type regionData struct {
+	Name string
+	Data result.AggData
+}
+
+type statusCount struct {
+	Status string
+	Count  int
+	Share  float64
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"seconds":  formatSeconds,
+	"bytes":    func(n int) string { return humanize.Bytes(uint64(n)) },
+	"percent":  func(share float64) string { return fmt.Sprintf("%.2f%%", share*100) },
+	"errors":   result.Errors,
+	"join":     strings.Join,
+	"stages":   formatStages,
+	"datetime": func(t time.Time) string { return t.Format(time.RFC1123) },
+	"duration": formatDuration,
+	"regionRow": func(name string, data result.AggData) regionData {
+		return regionData{name, data}
+	},
+}).Parse(htmlSource))
+
+// WriteHTML writes a single HTML file with the settings and results of the
+// test. Styles and charts are embedded, the file can be viewed offline.
+func WriteHTML(w io.Writer, doc *Document) error {
+	report := htmlReport{
+		Document: doc,
+		Statuses: statusCounts(doc.Overall),
+	}
+	for _, name := range doc.RegionNames() {
+		report.Regions = append(report.Regions, regionData{name, doc.Regions[name]})
+	}
+	report.Charts = append(report.Charts, latencyDistribution(doc.Overall.TimeForReqHistogram))
+	if len(doc.Overall.Series) > 0 {
+		report.Charts = append(report.Charts, throughputChart(doc.Overall.Series), latencyChart(doc.Overall.Series))
+	}
+	return htmlTemplate.Execute(w, report)
+}
+
+// statusCounts returns the share of every status code in ascending order
+func statusCounts(data result.AggData) []statusCount {
+	counts := make([]statusCount, 0, len(data.Statuses))
+	for status, count := range data.Statuses {
+		var share float64
+		if data.TotalReqs > 0 {
+			share = float64(count) / float64(data.TotalReqs)
+		}
+		counts = append(counts, statusCount{status, count, share})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Status < counts[j].Status })
+	return counts
+}
+
+func formatSeconds(nanos int64) string {
+	return fmt.Sprintf("%.3fs", float64(nanos)/float64(time.Second))
+}
+
+// formatDuration drops the fractions of a second
+func formatDuration(d time.Duration) string {
+	return (d / time.Second * time.Second).String()
+}
+
+func formatStages(stages []types.Stage) string {
+	descriptions := make([]string, len(stages))
+	for i, stage := range stages {
+		descriptions[i] = stage.String()
+	}
+	return strings.Join(descriptions, ", ")
+}
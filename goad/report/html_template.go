@@ -0,0 +1,68 @@
+package report
+
+const htmlSource = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Goad report {{.Config.URL}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #222; margin: 2em auto; max-width: 960px; padding: 0 1em; }
+h1 { font-size: 1.6em; margin-bottom: 0; }
+h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #ddd; padding-bottom: .3em; }
+.generated { color: #666; margin-top: .3em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { padding: .35em .8em; border-bottom: 1px solid #eee; }
+th { text-align: left; background: #f6f8fa; }
+td.number { text-align: right; font-variant-numeric: tabular-nums; }
+.error { color: #d62728; }
+svg.chart { width: 100%; max-width: 720px; display: block; margin: 1em 0; font-size: 11px; fill: #444; }
+svg.chart .title { font-size: 13px; font-weight: bold; }
+svg.chart .grid { stroke: #ddd; }
+</style>
+</head>
+<body>
+<h1>Goad report</h1>
+<p class="generated">{{.Config.Method}} {{.Config.URL}}{{if not .Finished.IsZero}}, finished {{datetime .Finished}}{{end}}{{if .Duration}} after {{duration .Duration}}{{end}}</p>
+
+{{with .Config}}{{if .URL}}
+<h2>Configuration</h2>
+<table>
+<tr><th>URL</th><td>{{.Method}} {{.URL}}</td></tr>
+{{if .ScenarioFile}}<tr><th>Scenario</th><td>{{.ScenarioFile}}</td></tr>{{end}}
+{{if .RequestsFile}}<tr><th>Requests file</th><td>{{.RequestsFile}}</td></tr>{{end}}
+<tr><th>Regions</th><td>{{join .Regions ", "}}</td></tr>
+<tr><th>Concurrency</th><td>{{.Concurrency}}</td></tr>
+{{if .Rate}}<tr><th>Rate</th><td>{{.Rate}}/s</td></tr>{{end}}
+{{if .Stages}}<tr><th>Stages</th><td>{{stages .Stages}}</td></tr>{{end}}
+<tr><th>Requests</th><td>{{if .Requests}}{{.Requests}}{{else}}unlimited{{end}}</td></tr>
+<tr><th>Time limit</th><td>{{.Timelimit}}s</td></tr>
+<tr><th>Request timeout</th><td>{{.Timeout}}s</td></tr>
+{{if .Lambdas}}<tr><th>Runners</th><td>{{.Lambdas}}</td></tr>{{end}}
+{{if .Assertions}}<tr><th>Assertions</th><td>{{join .Assertions ", "}}</td></tr>{{end}}
+{{if .Thresholds}}<tr><th>Thresholds</th><td>{{join .Thresholds ", "}}</td></tr>{{end}}
+</table>
+{{end}}{{end}}
+
+<h2>Results</h2>
+<table>
+<tr><th></th><th>Requests</th><th>Req/s</th><th>Avg</th><th>p50</th><th>p90</th><th>p95</th><th>p99</th><th>Slowest</th><th>Timeouts</th><th>Errors</th><th>Read</th></tr>
+{{range .Regions}}{{template "row" .}}{{end}}
+{{template "row" (regionRow "Overall" .Overall)}}
+</table>
+{{if .Overall.FatalError}}<p class="error">Aborted: {{.Overall.FatalError}}</p>{{end}}
+
+<h2>Status codes</h2>
+<table>
+<tr><th>Status</th><th>Requests</th><th>Share</th></tr>
+{{range .Statuses}}<tr><td>{{.Status}}</td><td class="number">{{.Count}}</td><td class="number">{{percent .Share}}</td></tr>
+{{else}}<tr><td colspan="3">No responses</td></tr>
+{{end}}
+</table>
+
+<h2>Charts</h2>
+{{range .Charts}}{{.}}
+{{end}}
+</body>
+</html>
+{{define "row"}}<tr><th>{{.Name}}</th>{{with .Data}}<td class="number">{{.TotalReqs}}</td><td class="number">{{printf "%.2f" .AveReqPerSec}}</td><td class="number">{{seconds .AveTimeForReq}}</td><td class="number">{{seconds .TimeForReqPercentiles.P50}}</td><td class="number">{{seconds .TimeForReqPercentiles.P90}}</td><td class="number">{{seconds .TimeForReqPercentiles.P95}}</td><td class="number">{{seconds .TimeForReqPercentiles.P99}}</td><td class="number">{{seconds .Slowest}}</td><td class="number">{{.TotalTimedOut}}</td><td class="number">{{errors .}}</td><td class="number">{{bytes .TotBytesRead}}</td>{{end}}</tr>
+{{end}}`
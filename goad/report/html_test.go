@@ -0,0 +1,62 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/goadapp/goad/api"
+	"github.com/goadapp/goad/goad/histogram"
+	"github.com/goadapp/goad/goad/types"
+	"github.com/goadapp/goad/result"
+	"github.com/stretchr/testify/assert"
+)
+
+func testResults() result.LambdaResults {
+	results := result.SetupRegionsAggData(2)
+	for runnerID, region := range []string{"eu-west-1", "us-east-1"} {
+		timeForReq := histogram.New()
+		timeForReq.Record(int64(40 * time.Millisecond))
+		timeForReq.Record(int64(3 * time.Second))
+		results.AddRunnerResult(&api.RunnerResult{
+			RunnerID:            runnerID,
+			Region:              region,
+			RequestCount:        2,
+			Statuses:            map[string]int{"200": 1, "503": 1},
+			TimeDelta:           time.Second,
+			Fastest:             int64(40 * time.Millisecond),
+			Slowest:             int64(3 * time.Second),
+			TimeForReqHistogram: timeForReq,
+		}, results.Started.Add(time.Duration(runnerID)*15*time.Second))
+	}
+	return *results
+}
+
+func testConfig() *types.TestConfig {
+	return &types.TestConfig{URL: "https://example.com/<path>", Method: "GET", Regions: []string{"eu-west-1", "us-east-1"}, Concurrency: 10, Requests: 4}
+}
+
+func TestHTMLReportIsSelfContained(t *testing.T) {
+	assert := assert.New(t)
+	var html bytes.Buffer
+	assert.NoError(WriteHTML(&html, NewDocument(testConfig(), testResults(), time.Now())))
+
+	report := html.String()
+	assert.Contains(report, "https://example.com/&lt;path&gt;", "the config is escaped")
+	assert.Contains(report, "<th>eu-west-1</th>")
+	assert.Contains(report, "<th>Overall</th>")
+	assert.Contains(report, "<td>503</td>")
+	assert.Contains(report, "Latency distribution")
+	assert.Contains(report, "Throughput")
+	assert.NotContains(report, "http://cdn", "no resources are loaded from the network")
+	assert.NotContains(report, "<script src")
+}
+
+func TestLatencyBins(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(0, latencyBin(int64(500*time.Microsecond)))
+	assert.Equal(1, latencyBin(int64(time.Millisecond)))
+	assert.Equal(6, latencyBin(int64(40*time.Millisecond)))
+	assert.Equal("32ms", binLabel(6))
+	assert.Equal("2.05s", binLabel(12))
+}
@@ -0,0 +1,79 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/goadapp/goad/result"
+)
+
+// WriteMarkdown writes the settings and results of the test as Markdown
+// tables, eg. for a pull request or a wiki page
+func WriteMarkdown(w io.Writer, doc *Document) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "# Goad report")
+	fmt.Fprintln(out)
+	if config := doc.Config; config.URL != "" {
+		fmt.Fprintf(out, "`%s %s`", config.Method, config.URL)
+		if duration := doc.Duration(); duration > 0 {
+			fmt.Fprintf(out, " for %s", formatDuration(duration))
+		}
+		fmt.Fprintln(out)
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "| Setting | Value |")
+		fmt.Fprintln(out, "|---|---|")
+		fmt.Fprintf(out, "| Regions | %s |\n", markdownCell(strings.Join(config.Regions, ", ")))
+		fmt.Fprintf(out, "| Concurrency | %d |\n", config.Concurrency)
+		if config.Rate > 0 {
+			fmt.Fprintf(out, "| Rate | %g/s |\n", config.Rate)
+		}
+		if len(config.Stages) > 0 {
+			fmt.Fprintf(out, "| Stages | %s |\n", formatStages(config.Stages))
+		}
+		fmt.Fprintf(out, "| Requests | %d |\n", config.Requests)
+		fmt.Fprintf(out, "| Time limit | %ds |\n", config.Timelimit)
+		fmt.Fprintf(out, "| Request timeout | %ds |\n", config.Timeout)
+		if len(config.Thresholds) > 0 {
+			fmt.Fprintf(out, "| Thresholds | %s |\n", markdownCell(strings.Join(config.Thresholds, ", ")))
+		}
+		fmt.Fprintln(out)
+	}
+
+	fmt.Fprintln(out, "## Results")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "| Region | Requests | Req/s | Avg | p50 | p90 | p95 | p99 | Slowest | Timeouts | Errors | Read |")
+	fmt.Fprintln(out, "|---|--:|--:|--:|--:|--:|--:|--:|--:|--:|--:|--:|")
+	for _, name := range doc.RegionNames() {
+		writeMarkdownRow(out, name, doc.Regions[name])
+	}
+	writeMarkdownRow(out, "**Overall**", doc.Overall)
+	fmt.Fprintln(out)
+	if doc.Overall.FatalError != "" {
+		fmt.Fprintf(out, "Aborted: %s\n\n", markdownCell(doc.Overall.FatalError))
+	}
+
+	fmt.Fprintln(out, "## Status codes")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "| Status | Requests | Share |")
+	fmt.Fprintln(out, "|---|--:|--:|")
+	for _, status := range statusCounts(doc.Overall) {
+		fmt.Fprintf(out, "| %s | %d | %.2f%% |\n", status.Status, status.Count, status.Share*100)
+	}
+	return out.Flush()
+}
+
+func writeMarkdownRow(w io.Writer, name string, data result.AggData) {
+	p := data.TimeForReqPercentiles
+	fmt.Fprintf(w, "| %s | %d | %.2f | %s | %s | %s | %s | %s | %s | %d | %d | %s |\n",
+		name, data.TotalReqs, data.AveReqPerSec, formatSeconds(data.AveTimeForReq),
+		formatSeconds(p.P50), formatSeconds(p.P90), formatSeconds(p.P95), formatSeconds(p.P99),
+		formatSeconds(data.Slowest), data.TotalTimedOut, result.Errors(data), humanize.Bytes(uint64(data.TotBytesRead)))
+}
+
+// markdownCell escapes the pipes of a table cell
+func markdownCell(text string) string {
+	return strings.Replace(text, "|", `\|`, -1)
+}
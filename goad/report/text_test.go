@@ -0,0 +1,37 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownReport(t *testing.T) {
+	assert := assert.New(t)
+	var markdown bytes.Buffer
+	assert.NoError(WriteMarkdown(&markdown, NewDocument(testConfig(), testResults(), time.Now())))
+
+	report := markdown.String()
+	assert.Contains(report, "| Concurrency | 10 |")
+	assert.Contains(report, "| eu-west-1 | 2 |")
+	assert.Contains(report, "| **Overall** | 4 |")
+	assert.Contains(report, "| 503 | 2 | 50.00% |")
+}
+
+func TestCSVReport(t *testing.T) {
+	assert := assert.New(t)
+	var content bytes.Buffer
+	assert.NoError(WriteCSV(&content, NewDocument(testConfig(), testResults(), time.Now())))
+
+	records, err := csv.NewReader(&content).ReadAll()
+	assert.NoError(err)
+	assert.Len(records, 4)
+	assert.Equal(csvHeader, records[0])
+	assert.Equal("eu-west-1", records[1][0])
+	assert.Equal("overall", records[3][0])
+	assert.Equal("4", records[3][1])
+	assert.Equal("2", records[3][13], "errors")
+}
@@ -0,0 +1,153 @@
+package requestfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/goadapp/goad/goad/assertion"
+)
+
+// Request is one entry of a requests file, the workers pick entries in
+// proportion to their weight. A missing weight counts as 1, entries with a
+// weight of 0 are never picked. Assert lists assertion specs checked in
+// addition to the assertions of the test.
+type Request struct {
+	Name    string   `json:"name"`
+	Method  string   `json:"method"`
+	URL     string   `json:"url"`
+	Headers []string `json:"headers"`
+	Body    string   `json:"body"`
+	Weight  *float64 `json:"weight"`
+	Assert  []string `json:"assert"`
+}
+
+// Load reads and validates a JSONL requests file
+func Load(path string) ([]Request, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(bytes.NewReader(data))
+}
+
+// Parse reads one JSON request per line, empty lines are skipped. Missing
+// names default to "METHOD url", missing weights to 1. At least one request
+// needs a weight above 0.
+func Parse(reader io.Reader) ([]Request, error) {
+	requests := make([]Request, 0)
+	var total float64
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		request := Request{}
+		if err := json.Unmarshal([]byte(text), &request); err != nil {
+			return nil, fmt.Errorf("Invalid request in line %d: %s", line, err)
+		}
+		if request.Method == "" {
+			request.Method = "GET"
+		}
+		if request.Name == "" {
+			request.Name = request.Method + " " + request.URL
+		}
+		if request.Weight == nil {
+			weight := 1.0
+			request.Weight = &weight
+		}
+		if err := request.validate(); err != nil {
+			return nil, fmt.Errorf("Invalid request in line %d: %s", line, err)
+		}
+		total += request.weight()
+		requests = append(requests, request)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, errors.New("Requests file contains no requests")
+	}
+	if total == 0 {
+		return nil, errors.New("All requests of the requests file have a weight of 0")
+	}
+	return requests, nil
+}
+
+func (r Request) validate() error {
+	if r.weight() < 0 {
+		return fmt.Errorf("negative weight %f", r.weight())
+	}
+	for _, header := range r.Headers {
+		if !strings.Contains(header, ":") {
+			return fmt.Errorf("header %s not valid. Make sure your header is of the form \"Header: value\"", header)
+		}
+	}
+	return assertion.Validate(r.Assert)
+}
+
+// weight returns the weight of the request, 1 if it has none
+func (r Request) weight() float64 {
+	if r.Weight == nil {
+		return 1
+	}
+	return *r.Weight
+}
+
+// ResolveURL returns the URL of the request, relative URLs are resolved
+// against the base URL of the test
+func (r Request) ResolveURL(base string) string {
+	if r.URL == "" {
+		return base
+	}
+	if strings.HasPrefix(r.URL, "/") {
+		return strings.TrimSuffix(base, "/") + r.URL
+	}
+	return r.URL
+}
+
+// Picker selects requests at random in proportion to their weight
+type Picker struct {
+	requests   []Request
+	cumulative []float64
+	total      float64
+}
+
+// NewPicker creates a Picker for the given requests
+func NewPicker(requests []Request) *Picker {
+	p := &Picker{requests: requests}
+	for _, request := range requests {
+		p.total += request.weight()
+		p.cumulative = append(p.cumulative, p.total)
+	}
+	return p
+}
+
+// Pick returns the next request, it is safe for concurrent use
+func (p *Picker) Pick() Request {
+	return p.requests[p.PickIndex()]
+}
+
+// PickIndex returns the index of the next request, it is safe for concurrent
+// use
+func (p *Picker) PickIndex() int {
+	target := rand.Float64() * p.total
+	index := sort.Search(len(p.cumulative), func(i int) bool {
+		return p.cumulative[i] > target
+	})
+	if index >= len(p.requests) {
+		index = len(p.requests) - 1
+	}
+	return index
+}
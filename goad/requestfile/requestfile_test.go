@@ -0,0 +1,65 @@
+package requestfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRequests = `{"name": "home", "url": "/", "weight": 3}
+
+{"method": "POST", "url": "/orders", "headers": ["Content-Type: application/json"], "body": "{}"}
+`
+
+func TestParseAppliesDefaults(t *testing.T) {
+	requests, err := Parse(strings.NewReader(testRequests))
+	assert.NoError(t, err)
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "GET", requests[0].Method)
+	assert.Equal(t, "POST /orders", requests[1].Name)
+	assert.Equal(t, 1.0, *requests[1].Weight)
+	assert.Equal(t, "http://example.com/orders", requests[1].ResolveURL("http://example.com/"))
+}
+
+func TestParseRejectsInvalidLines(t *testing.T) {
+	_, err := Parse(strings.NewReader("{\"url\": \"/\"}\nnot json\n"))
+	assert.Error(t, err)
+	_, err = Parse(strings.NewReader(`{"url": "/", "headers": ["no-colon"]}`))
+	assert.Error(t, err)
+	_, err = Parse(strings.NewReader("\n"))
+	assert.Error(t, err)
+}
+
+func TestParseKeepsZeroWeight(t *testing.T) {
+	requests, err := Parse(strings.NewReader(`{"name": "disabled", "url": "/", "weight": 0}
+{"name": "enabled", "url": "/"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, *requests[0].Weight)
+
+	picker := NewPicker(requests)
+	for i := 0; i < 1000; i++ {
+		assert.Equal(t, "enabled", picker.Pick().Name)
+	}
+}
+
+func TestParseRejectsOnlyZeroWeights(t *testing.T) {
+	_, err := Parse(strings.NewReader(`{"url": "/", "weight": 0}`))
+	assert.EqualError(t, err, "All requests of the requests file have a weight of 0")
+	_, err = Parse(strings.NewReader(`{"url": "/", "weight": -1}`))
+	assert.Error(t, err)
+}
+
+func weight(w float64) *float64 {
+	return &w
+}
+
+func TestPickerHonoursWeights(t *testing.T) {
+	picker := NewPicker([]Request{{Name: "a", Weight: weight(9)}, {Name: "b"}, {Name: "never", Weight: weight(0)}})
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[picker.Pick().Name]++
+	}
+	assert.Equal(t, 0, counts["never"])
+	assert.InDelta(t, 9000, counts["a"], 300)
+}
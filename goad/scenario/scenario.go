@@ -0,0 +1,228 @@
+package scenario
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// FromJSON extracts a value from a JSON response body by path
+	FromJSON = "json"
+	// FromRegex extracts the first capture group of a regular expression
+	// matched against the response body
+	FromRegex = "regex"
+	// FromHeader extracts the value of a response header
+	FromHeader = "header"
+)
+
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+
+var regexCache = struct {
+	sync.Mutex
+	compiled map[string]*regexp.Regexp
+}{compiled: make(map[string]*regexp.Regexp)}
+
+// Scenario is an ordered list of steps every worker executes as one
+// iteration of the load test.
+type Scenario struct {
+	Steps []Step `json:"steps"`
+}
+
+// Step is a single request of a scenario. URL, headers and body may refer to
+// values extracted by previous steps as ${name}. Assert lists assertion specs
+// checked in addition to the assertions of the test.
+type Step struct {
+	Name    string       `json:"name"`
+	Method  string       `json:"method"`
+	URL     string       `json:"url"`
+	Headers []string     `json:"headers"`
+	Body    string       `json:"body"`
+	Extract []Extraction `json:"extract"`
+	Assert  []string     `json:"assert"`
+}
+
+// Extraction stores a value from a response in a worker variable
+type Extraction struct {
+	Name       string `json:"name"`
+	From       string `json:"from"`
+	Expression string `json:"expr"`
+}
+
+// Load reads a scenario definition from a JSON file
+func Load(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse reads a scenario definition from JSON
+func Parse(data []byte) (*Scenario, error) {
+	s := &Scenario{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("Invalid scenario: %s", err)
+	}
+	return s, nil
+}
+
+// Validate checks the scenario for missing or invalid settings
+func (s *Scenario) Validate() error {
+	if len(s.Steps) == 0 {
+		return errors.New("Scenario has no steps")
+	}
+	names := make(map[string]bool)
+	for i, step := range s.Steps {
+		if step.Name == "" {
+			return fmt.Errorf("Scenario step %d has no name", i+1)
+		}
+		if names[step.Name] {
+			return fmt.Errorf("Scenario step name %s is used more than once", step.Name)
+		}
+		names[step.Name] = true
+		for _, header := range step.Headers {
+			if !strings.Contains(header, ":") {
+				return fmt.Errorf("Header %s of step %s not valid. Make sure your header is of the form \"Header: value\"", header, step.Name)
+			}
+		}
+		for _, extraction := range step.Extract {
+			if extraction.Name == "" || extraction.Expression == "" {
+				return fmt.Errorf("Extraction in step %s needs a name and an expr", step.Name)
+			}
+			switch extraction.From {
+			case FromJSON, FromHeader:
+			case FromRegex:
+				if _, err := regexp.Compile(extraction.Expression); err != nil {
+					return fmt.Errorf("Invalid regex in step %s: %s", step.Name, err)
+				}
+			default:
+				return fmt.Errorf("Unknown extraction source %q in step %s (use json, regex or header)", extraction.From, step.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// ResolveURL returns the URL of the step, relative URLs are resolved against
+// the base URL of the test
+func (step Step) ResolveURL(base string) string {
+	if step.URL == "" {
+		return base
+	}
+	if strings.HasPrefix(step.URL, "/") {
+		return strings.TrimSuffix(base, "/") + step.URL
+	}
+	return step.URL
+}
+
+// Substitute replaces all ${name} references in str with the values in vars,
+// unknown references are left untouched
+func Substitute(str string, vars map[string]string) string {
+	if len(vars) == 0 || !strings.Contains(str, "${") {
+		return str
+	}
+	return variablePattern.ReplaceAllStringFunc(str, func(ref string) string {
+		if value, ok := vars[ref[2:len(ref)-1]]; ok {
+			return value
+		}
+		return ref
+	})
+}
+
+// Extract evaluates the extraction against a response
+func (e Extraction) Extract(header http.Header, body []byte) (string, error) {
+	switch e.From {
+	case FromHeader:
+		value := header.Get(e.Expression)
+		if value == "" {
+			return "", fmt.Errorf("header %s not found", e.Expression)
+		}
+		return value, nil
+	case FromRegex:
+		re, err := compileRegex(e.Expression)
+		if err != nil {
+			return "", err
+		}
+		match := re.FindSubmatch(body)
+		if match == nil {
+			return "", fmt.Errorf("regex %s did not match", e.Expression)
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+	case FromJSON:
+		return JSONPath(body, e.Expression)
+	}
+	return "", fmt.Errorf("unknown extraction source %q", e.From)
+}
+
+// compileRegex compiles expressions once, they are evaluated for every
+// response of a step
+func compileRegex(expression string) (*regexp.Regexp, error) {
+	regexCache.Lock()
+	defer regexCache.Unlock()
+	if re, ok := regexCache.compiled[expression]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.compiled[expression] = re
+	return re, nil
+}
+
+// JSONPath looks up a value in a JSON document by a simple dotted path with
+// optional array indexes, eg. "$.data.items[0].token". Strings are returned
+// as is, other values in their JSON representation.
+func JSONPath(body []byte, path string) (string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", fmt.Errorf("response is not valid JSON: %s", err)
+	}
+	current := doc
+	for _, key := range splitPath(path) {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return "", fmt.Errorf("key %s not found in %s", key, path)
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", fmt.Errorf("invalid index %s in %s", key, path)
+			}
+			current = node[index]
+		default:
+			return "", fmt.Errorf("can not descend into %s of %s", key, path)
+		}
+	}
+	if str, ok := current.(string); ok {
+		return str, nil
+	}
+	value, err := json.Marshal(current)
+	return string(value), err
+}
+
+func splitPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.Replace(path, "[", ".", -1)
+	path = strings.Replace(path, "]", "", -1)
+	keys := make([]string, 0)
+	for _, key := range strings.Split(path, ".") {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
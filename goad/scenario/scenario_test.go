@@ -0,0 +1,66 @@
+package scenario
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testScenario = `{
+  "steps": [
+    {
+      "name": "login",
+      "method": "POST",
+      "url": "/login",
+      "body": "{\"user\": \"goad\"}",
+      "extract": [{"name": "token", "from": "json", "expr": "$.data.token"}]
+    },
+    {
+      "name": "fetch",
+      "url": "/api/items",
+      "headers": ["Authorization: Bearer ${token}"]
+    }
+  ]
+}`
+
+func TestParseAndValidate(t *testing.T) {
+	s, err := Parse([]byte(testScenario))
+	assert.NoError(t, err)
+	assert.NoError(t, s.Validate())
+	assert.Len(t, s.Steps, 2)
+	assert.Equal(t, "http://example.com/login", s.Steps[0].ResolveURL("http://example.com/"))
+}
+
+func TestValidateRejectsDuplicateNames(t *testing.T) {
+	s := &Scenario{Steps: []Step{{Name: "a"}, {Name: "a"}}}
+	assert.Error(t, s.Validate())
+	s = &Scenario{Steps: []Step{{Name: "a", Extract: []Extraction{{Name: "x", From: "xpath", Expression: "/"}}}}}
+	assert.Error(t, s.Validate())
+}
+
+func TestSubstitute(t *testing.T) {
+	vars := map[string]string{"token": "abc"}
+	assert.Equal(t, "Bearer abc ${missing}", Substitute("Bearer ${token} ${missing}", vars))
+}
+
+func TestExtract(t *testing.T) {
+	body := []byte(`{"data": {"token": "abc", "items": [{"id": 7}]}}`)
+	header := http.Header{}
+	header.Set("X-Session", "s1")
+
+	value, err := Extraction{From: FromJSON, Expression: "$.data.items[0].id"}.Extract(header, body)
+	assert.NoError(t, err)
+	assert.Equal(t, "7", value)
+
+	value, err = Extraction{From: FromRegex, Expression: `"token": "(\w+)"`}.Extract(header, body)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", value)
+
+	value, err = Extraction{From: FromHeader, Expression: "X-Session"}.Extract(header, body)
+	assert.NoError(t, err)
+	assert.Equal(t, "s1", value)
+
+	_, err = Extraction{From: FromJSON, Expression: "$.data.missing"}.Extract(header, body)
+	assert.Error(t, err)
+}
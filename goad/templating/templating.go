@@ -0,0 +1,225 @@
+package templating
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// Sequential feeds the rows of the data file in order, starting over at
+	// the end
+	Sequential = "sequential"
+	// Random feeds a random row for every request
+	Random = "random"
+	// Unique partitions the rows between the runners so no two runners use
+	// the same row, every runner feeds its rows in order and starts over once
+	// all of them were used
+	Unique = "unique"
+)
+
+// Feeders lists the supported feeder strategies
+var Feeders = []string{Sequential, Random, Unique}
+
+// IsTemplate reports whether str contains template actions
+func IsTemplate(str string) bool {
+	return strings.Contains(str, "{{")
+}
+
+// Validate parses all templates to report syntax errors before a test starts
+func Validate(templates ...string) error {
+	funcs := NewRenderer(nil).funcs
+	for _, str := range templates {
+		if !IsTemplate(str) {
+			continue
+		}
+		if _, err := template.New("").Funcs(funcs).Parse(str); err != nil {
+			return fmt.Errorf("Invalid template %q: %s", str, err)
+		}
+	}
+	return nil
+}
+
+// Feeder hands out the rows of a CSV data file, the first line of the file
+// holds the column names. A Feeder is safe for concurrent use.
+type Feeder struct {
+	columns  []string
+	rows     [][]string
+	strategy string
+	next     uint64
+}
+
+// LoadFeeder reads a CSV data file and prepares the rows for the given runner
+func LoadFeeder(path, strategy string, runnerID, runnerCount int) (*Feeder, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return NewFeeder(file, strategy, runnerID, runnerCount)
+}
+
+// NewFeeder reads CSV data from reader and prepares the rows for the given
+// runner
+func NewFeeder(reader io.Reader, strategy string, runnerID, runnerCount int) (*Feeder, error) {
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid data file: %s", err)
+	}
+	if len(records) < 2 {
+		return nil, errors.New("Data file needs a header line and at least one row")
+	}
+	if strategy == "" {
+		strategy = Sequential
+	}
+	f := &Feeder{columns: records[0], rows: records[1:], strategy: strategy}
+	switch strategy {
+	case Sequential, Random:
+	case Unique:
+		if runnerCount < 1 {
+			runnerCount = 1
+		}
+		if len(f.rows) < runnerCount {
+			return nil, fmt.Errorf("Data file has %d rows, unique feeding needs at least one row for each of the %d runners", len(f.rows), runnerCount)
+		}
+		start := runnerID * len(f.rows) / runnerCount
+		end := (runnerID + 1) * len(f.rows) / runnerCount
+		f.rows = f.rows[start:end]
+	default:
+		return nil, fmt.Errorf("Unknown feeder %q (use %s)", strategy, strings.Join(Feeders, ", "))
+	}
+	return f, nil
+}
+
+// Columns returns the column names of the data file
+func (f *Feeder) Columns() []string {
+	return f.columns
+}
+
+// Position returns the number of rows handed out in order, forks of a runner
+// continue at this position
+func (f *Feeder) Position() int {
+	return int(atomic.LoadUint64(&f.next))
+}
+
+// Seek continues with the row at position, counted from the first row of the
+// runner
+func (f *Feeder) Seek(position int) {
+	if position < 0 {
+		position = 0
+	}
+	atomic.StoreUint64(&f.next, uint64(position))
+}
+
+// Next returns the next row keyed by column name
+func (f *Feeder) Next() map[string]string {
+	var record []string
+	if f.strategy == Random {
+		record = f.rows[rand.Intn(len(f.rows))]
+	} else {
+		index := atomic.AddUint64(&f.next, 1) - 1
+		record = f.rows[index%uint64(len(f.rows))]
+	}
+	row := make(map[string]string, len(f.columns))
+	for i, column := range f.columns {
+		if i < len(record) {
+			row[column] = record[i]
+		}
+	}
+	return row
+}
+
+// Renderer executes request templates. Every worker needs its own Renderer,
+// it keeps the data row of the current request and is not safe for concurrent
+// use.
+type Renderer struct {
+	feeder *Feeder
+	row    map[string]string
+	vars   map[string]string
+	cache  map[string]*template.Template
+	funcs  template.FuncMap
+}
+
+// NewRenderer creates a Renderer, feeder may be nil if there is no data file
+func NewRenderer(feeder *Feeder) *Renderer {
+	r := &Renderer{
+		feeder: feeder,
+		cache:  make(map[string]*template.Template),
+	}
+	r.funcs = template.FuncMap{
+		"uuid": func() string {
+			return uuid.NewV4().String()
+		},
+		"randInt": func(min, max int) int {
+			if max <= min {
+				return min
+			}
+			return min + rand.Intn(max-min)
+		},
+		"now": time.Now,
+		"csv": r.column,
+		"var": r.variable,
+	}
+	return r
+}
+
+// Next advances to the data row for the next request, vars holds the values
+// extracted by previous scenario steps
+func (r *Renderer) Next(vars map[string]string) {
+	r.vars = vars
+	r.row = nil
+	if r.feeder != nil {
+		r.row = r.feeder.Next()
+	}
+}
+
+// Render executes str as template, strings without template actions are
+// returned unchanged
+func (r *Renderer) Render(str string) (string, error) {
+	if !IsTemplate(str) {
+		return str, nil
+	}
+	tmpl, ok := r.cache[str]
+	if !ok {
+		var err error
+		tmpl, err = template.New("").Funcs(r.funcs).Option("missingkey=error").Parse(str)
+		if err != nil {
+			return str, err
+		}
+		r.cache[str] = tmpl
+	}
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, nil); err != nil {
+		return str, err
+	}
+	return buffer.String(), nil
+}
+
+func (r *Renderer) column(name string) (string, error) {
+	if r.feeder == nil {
+		return "", errors.New("csv used without a data file")
+	}
+	value, ok := r.row[name]
+	if !ok {
+		return "", fmt.Errorf("unknown column %s", name)
+	}
+	return value, nil
+}
+
+func (r *Renderer) variable(name string) (string, error) {
+	value, ok := r.vars[name]
+	if !ok {
+		return "", fmt.Errorf("unknown variable %s", name)
+	}
+	return value, nil
+}
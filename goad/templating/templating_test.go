@@ -0,0 +1,78 @@
+package templating
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testData = "user,password\nalice,a1\nbob,b2\ncarol,c3\ndave,d4\n"
+
+func TestRenderHelpers(t *testing.T) {
+	renderer := NewRenderer(nil)
+	renderer.Next(map[string]string{"token": "abc"})
+
+	id, err := renderer.Render("{{uuid}}")
+	assert.NoError(t, err)
+	assert.Len(t, id, 36)
+
+	value, err := renderer.Render(`{{randInt 5 6}}-{{var "token"}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "5-abc", value)
+
+	plain, err := renderer.Render("no template")
+	assert.NoError(t, err)
+	assert.Equal(t, "no template", plain)
+
+	_, err = renderer.Render(`{{csv "user"}}`)
+	assert.Error(t, err, "csv without data file should fail")
+}
+
+func TestSequentialFeeder(t *testing.T) {
+	feeder, err := NewFeeder(strings.NewReader(testData), Sequential, 0, 1)
+	assert.NoError(t, err)
+	renderer := NewRenderer(feeder)
+	users := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		renderer.Next(nil)
+		user, err := renderer.Render(`{{csv "user"}}:{{csv "password"}}`)
+		assert.NoError(t, err)
+		users = append(users, user)
+	}
+	assert.Equal(t, []string{"alice:a1", "bob:b2", "carol:c3", "dave:d4", "alice:a1"}, users)
+}
+
+func TestUniqueFeederPartitionsRows(t *testing.T) {
+	seen := make(map[string]int)
+	for runner := 0; runner < 2; runner++ {
+		feeder, err := NewFeeder(strings.NewReader(testData), Unique, runner, 2)
+		assert.NoError(t, err)
+		for i := 0; i < 2; i++ {
+			seen[feeder.Next()["user"]] = runner
+		}
+	}
+	assert.Equal(t, map[string]int{"alice": 0, "bob": 0, "carol": 1, "dave": 1}, seen)
+
+	_, err := NewFeeder(strings.NewReader(testData), Unique, 0, 5)
+	assert.Error(t, err, "unique feeding needs a row per runner")
+}
+
+func TestFeederContinuesAtPosition(t *testing.T) {
+	feeder, err := NewFeeder(strings.NewReader(testData), Unique, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "carol", feeder.Next()["user"])
+	assert.Equal(t, 1, feeder.Position())
+
+	fork, err := NewFeeder(strings.NewReader(testData), Unique, 1, 2)
+	assert.NoError(t, err)
+	fork.Seek(feeder.Position())
+	assert.Equal(t, "dave", fork.Next()["user"])
+	assert.Equal(t, "carol", fork.Next()["user"], "rows repeat once all were used")
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate("http://example.com/{{uuid}}", `{"n": {{randInt 1 10}}}`))
+	assert.Error(t, Validate("{{unknownFunc}}"))
+	assert.Error(t, Validate("{{uuid"))
+}
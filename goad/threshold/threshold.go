@@ -0,0 +1,248 @@
+package threshold
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goadapp/goad/result"
+)
+
+// MinimumSamples is the number of requests a region or the whole test needs
+// before aborting thresholds are checked, so a few slow first requests do not
+// stop the test
+const MinimumSamples = 100
+
+const (
+	kindDuration = iota
+	kindPercent
+	kindNumber
+)
+
+// metrics maps the supported metric names to the kind of their values
+var metrics = map[string]int{
+	"p50":        kindDuration,
+	"p90":        kindDuration,
+	"p95":        kindDuration,
+	"p99":        kindDuration,
+	"p99.9":      kindDuration,
+	"avg":        kindDuration,
+	"max":        kindDuration,
+	"ttfb_p95":   kindDuration,
+	"error_rate": kindPercent,
+	"errors":     kindNumber,
+	"rps":        kindNumber,
+	"requests":   kindNumber,
+}
+
+// operators are ordered so two character operators are matched first
+var operators = []string{"<=", ">=", "<", ">"}
+
+// Threshold is a pass/fail criterion for a test, eg. "p95<300ms",
+// "error_rate<1%" or "eu-west-1:rps>200". A threshold ending in "!" aborts
+// the test as soon as it is violated.
+type Threshold struct {
+	Spec     string
+	Region   string
+	Metric   string
+	Operator string
+	Limit    float64
+	Abort    bool
+}
+
+// Outcome is the result of evaluating a threshold
+type Outcome struct {
+	Threshold *Threshold
+	Actual    string
+	Passed    bool
+}
+
+// Parse reads a threshold spec of the form [region:]metric<operator>value[!]
+func Parse(spec string) (*Threshold, error) {
+	t := &Threshold{Spec: spec}
+	expression := strings.TrimSpace(spec)
+	if strings.HasSuffix(expression, "!") {
+		t.Abort = true
+		expression = strings.TrimSuffix(expression, "!")
+	}
+	if i := strings.Index(expression, ":"); i >= 0 {
+		t.Region = strings.TrimSpace(expression[:i])
+		expression = expression[i+1:]
+	}
+	var value string
+	for _, operator := range operators {
+		if i := strings.Index(expression, operator); i > 0 {
+			t.Metric = strings.TrimSpace(expression[:i])
+			t.Operator = operator
+			value = strings.TrimSpace(expression[i+len(operator):])
+			break
+		}
+	}
+	if t.Operator == "" {
+		return nil, fmt.Errorf("Threshold %q not valid. Make sure your threshold is of the form \"metric<value\", eg. p95<300ms", spec)
+	}
+	kind, ok := metrics[t.Metric]
+	if !ok {
+		return nil, fmt.Errorf("Unknown metric %q in threshold %q (use %s)", t.Metric, spec, strings.Join(Metrics(), ", "))
+	}
+	var err error
+	switch kind {
+	case kindDuration:
+		var limit time.Duration
+		limit, err = time.ParseDuration(value)
+		t.Limit = float64(limit.Nanoseconds())
+	case kindPercent:
+		t.Limit, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	default:
+		t.Limit, err = strconv.ParseFloat(value, 64)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid value %q in threshold %q", value, spec)
+	}
+	return t, nil
+}
+
+// ParseAll parses a list of specs
+func ParseAll(specs []string) ([]*Threshold, error) {
+	thresholds := make([]*Threshold, 0, len(specs))
+	for _, spec := range specs {
+		t, err := Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, nil
+}
+
+// Metrics returns the supported metric names
+func Metrics() []string {
+	return []string{"p50", "p90", "p95", "p99", "p99.9", "avg", "max", "ttfb_p95", "error_rate", "errors", "rps", "requests"}
+}
+
+// Evaluate checks all thresholds against the results of the test
+func Evaluate(thresholds []*Threshold, results result.LambdaResults) []Outcome {
+	outcomes := make([]Outcome, 0, len(thresholds))
+	for _, t := range thresholds {
+		data, ok := scope(t, results)
+		if !ok {
+			outcomes = append(outcomes, Outcome{Threshold: t, Actual: "no results"})
+			continue
+		}
+		actual := t.value(data)
+		outcomes = append(outcomes, Outcome{
+			Threshold: t,
+			Actual:    t.format(actual),
+			Passed:    t.compare(actual),
+		})
+	}
+	return outcomes
+}
+
+// Passed reports whether all outcomes passed
+func Passed(outcomes []Outcome) bool {
+	for _, outcome := range outcomes {
+		if !outcome.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// Violated returns the first aborting threshold that is violated by the
+// results received so far. Thresholds are only checked once their scope has
+// at least MinimumSamples requests.
+func Violated(thresholds []*Threshold, results result.LambdaResults) (*Threshold, bool) {
+	for _, t := range thresholds {
+		if !t.Abort {
+			continue
+		}
+		data, ok := scope(t, results)
+		if !ok || data.TotalReqs < MinimumSamples {
+			continue
+		}
+		if !t.compare(t.value(data)) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func scope(t *Threshold, results result.LambdaResults) (result.AggData, bool) {
+	if t.Region == "" {
+		if len(results.Regions()) == 0 {
+			return result.AggData{}, false
+		}
+		return results.SumAllLambdas(), true
+	}
+	data, ok := results.RegionsData()[t.Region]
+	return data, ok
+}
+
+func (t *Threshold) value(data result.AggData) float64 {
+	return Value(t.Metric, data)
+}
+
+// Value returns the value of a metric for the results, metric is one of
+// Metrics
+func Value(metric string, data result.AggData) float64 {
+	switch metric {
+	case "p50":
+		return float64(data.TimeForReqPercentiles.P50)
+	case "p90":
+		return float64(data.TimeForReqPercentiles.P90)
+	case "p95":
+		return float64(data.TimeForReqPercentiles.P95)
+	case "p99":
+		return float64(data.TimeForReqPercentiles.P99)
+	case "p99.9":
+		return float64(data.TimeForReqPercentiles.P999)
+	case "avg":
+		return float64(data.AveTimeForReq)
+	case "max":
+		return float64(data.Slowest)
+	case "ttfb_p95":
+		return float64(data.TimeToFirstPercentiles.P95)
+	case "error_rate":
+		if data.TotalReqs == 0 {
+			return 0
+		}
+		return 100 * float64(result.Errors(data)) / float64(data.TotalReqs)
+	case "errors":
+		return float64(result.Errors(data))
+	case "rps":
+		return data.AveReqPerSec
+	case "requests":
+		return float64(data.TotalReqs)
+	}
+	return 0
+}
+
+func (t *Threshold) compare(actual float64) bool {
+	switch t.Operator {
+	case "<":
+		return actual < t.Limit
+	case "<=":
+		return actual <= t.Limit
+	case ">":
+		return actual > t.Limit
+	case ">=":
+		return actual >= t.Limit
+	}
+	return false
+}
+
+func (t *Threshold) format(actual float64) string {
+	switch metrics[t.Metric] {
+	case kindDuration:
+		return time.Duration(actual).String()
+	case kindPercent:
+		return strconv.FormatFloat(actual, 'f', 2, 64) + "%"
+	}
+	if actual == math.Trunc(actual) {
+		return strconv.FormatFloat(actual, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(actual, 'f', 2, 64)
+}
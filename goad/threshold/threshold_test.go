@@ -0,0 +1,87 @@
+package threshold
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goadapp/goad/goad/histogram"
+	"github.com/goadapp/goad/result"
+	"github.com/stretchr/testify/assert"
+)
+
+func testResults() result.LambdaResults {
+	fast := histogram.New()
+	slow := histogram.New()
+	for i := 0; i < 200; i++ {
+		fast.Record((100 * time.Millisecond).Nanoseconds())
+		slow.Record((400 * time.Millisecond).Nanoseconds())
+	}
+	return result.LambdaResults{Lambdas: []result.AggData{
+		{
+			Region:               "us-east-1",
+			TotalReqs:            200,
+			Statuses:             map[string]int{"200": 198, "500": 2},
+			AveReqPerSec:         150,
+			TimeForReqHistogram:  fast,
+			TimeToFirstHistogram: histogram.New(),
+		},
+		{
+			Region:               "eu-west-1",
+			TotalReqs:            200,
+			Statuses:             map[string]int{"200": 200},
+			AveReqPerSec:         100,
+			TimeForReqHistogram:  slow,
+			TimeToFirstHistogram: histogram.New(),
+		},
+	}}
+}
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+	threshold, err := Parse("eu-west-1:p95<=300ms!")
+	assert.NoError(err)
+	assert.Equal(&Threshold{Spec: "eu-west-1:p95<=300ms!", Region: "eu-west-1", Metric: "p95", Operator: "<=", Limit: 300e6, Abort: true}, threshold)
+
+	threshold, err = Parse("error_rate < 1%")
+	assert.NoError(err)
+	assert.Equal("error_rate", threshold.Metric)
+	assert.Equal(1.0, threshold.Limit)
+	assert.False(threshold.Abort)
+
+	for _, spec := range []string{"p95", "p95<fast", "latency<1s", "rps>many", "<1s"} {
+		_, err := Parse(spec)
+		assert.Error(err, spec)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	assert := assert.New(t)
+	thresholds, err := ParseAll([]string{"p95<300ms", "us-east-1:p95<300ms", "error_rate<1%", "rps>200", "us-east-1:errors<=1", "ap-northeast-1:rps>0"})
+	assert.NoError(err)
+	outcomes := Evaluate(thresholds, testResults())
+	passed := make([]bool, 0)
+	for _, outcome := range outcomes {
+		passed = append(passed, outcome.Passed)
+	}
+	assert.Equal([]bool{false, true, true, true, false, false}, passed)
+	assert.Equal("0.50%", outcomes[2].Actual)
+	assert.Equal("250", outcomes[3].Actual)
+	assert.Equal("no results", outcomes[5].Actual)
+	assert.False(Passed(outcomes))
+}
+
+func TestViolated(t *testing.T) {
+	assert := assert.New(t)
+	thresholds, _ := ParseAll([]string{"p95<300ms", "us-east-1:p95<300ms!"})
+	_, violated := Violated(thresholds, testResults())
+	assert.False(violated, "thresholds without ! never abort")
+
+	thresholds, _ = ParseAll([]string{"eu-west-1:p95<300ms!"})
+	threshold, violated := Violated(thresholds, testResults())
+	assert.True(violated)
+	assert.Equal("eu-west-1:p95<300ms!", threshold.Spec)
+
+	thresholds, _ = ParseAll([]string{"requests>1000!"})
+	_, violated = Violated(thresholds, result.LambdaResults{Lambdas: []result.AggData{{Region: "us-east-1", TotalReqs: 10}}})
+	assert.False(violated, "too few samples to abort")
+}
@@ -4,7 +4,17 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/goadapp/goad/goad/assertion"
+	"github.com/goadapp/goad/goad/scenario"
+	"github.com/goadapp/goad/goad/templating"
+	"github.com/goadapp/goad/goad/threshold"
 )
 
 const (
@@ -12,7 +22,8 @@ const (
 	nano              = 1000000000
 )
 
-var supportedRegions = []string{
+// SupportedRegions are the AWS regions goad can run in
+var SupportedRegions = []string{
 	"us-east-1",      // N. Virginia
 	"us-east-2",      // Ohio
 	"us-west-1",      // N.California
@@ -28,23 +39,46 @@ var supportedRegions = []string{
 
 // TestConfig type
 type TestConfig struct {
-	URL         string
-	Concurrency int
-	Requests    int
-	Timelimit   int
-	Timeout     int
-	Regions     []string
-	Method      string
-	Body        string
-	Headers     []string
-	Output      string
-	Settings    string
-	RunDocker   bool
-	Lambdas     int
-	RunnerPath  string
+	URL          string
+	Concurrency  int
+	Rate         float64
+	Stages       []Stage
+	Requests     int
+	Timelimit    int
+	Timeout      int
+	Regions      []string
+	Method       string
+	Body         string
+	Headers      []string
+	Assertions   []string
+	Thresholds   []string
+	AbortPolicy  AbortPolicy
+	AbortAll     bool
+	Scenario     *scenario.Scenario
+	ScenarioFile string
+	RequestsFile string
+	DataFile     string
+	Feeder       string
+	Output       string
+	HTMLReport   string
+	Settings     string
+	RunDocker    bool
+	RunLocal     bool
+	Lambdas      int
+	RunnerPath   string
+	Function     FunctionConfig
+	AWS          AWSConfig
+	Export       ExportConfig
+
+	// SeriesInterval is the length of the intervals of the time series of
+	// the results in seconds
+	SeriesInterval int
 }
 
 func (c *TestConfig) Check() error {
+	if c.URL == "" {
+		return errors.New("No URL provided")
+	}
 	concurrencyLimit := 25000 * len(c.Regions)
 	if c.Concurrency < 1 || c.Concurrency > concurrencyLimit {
 		return fmt.Errorf("Invalid concurrency (use 1 - %d)", concurrencyLimit)
@@ -55,18 +89,26 @@ func (c *TestConfig) Check() error {
 	if c.Timelimit > 3600 {
 		return errors.New("Invalid maximum execution time in seconds (use 0 - 3600)")
 	}
+	if c.Rate < 0 {
+		return errors.New("Invalid rate (use a positive number of requests per second)")
+	}
+	if len(c.Stages) > 0 && c.Rate > 0 {
+		return errors.New("Stages and rate can not be combined")
+	}
+	for _, stage := range c.Stages {
+		if stage.Duration <= 0 || stage.Target < 0 {
+			return fmt.Errorf("Invalid stage %s (use a positive duration and concurrency)", stage)
+		}
+	}
 	if c.Timeout < 1 || c.Timeout > 100 {
 		return errors.New("Invalid timeout (1s - 100s)")
 	}
+	if c.SeriesInterval < 0 {
+		return errors.New("Invalid series interval (use a positive number of seconds)")
+	}
 	for _, region := range c.Regions {
-		supportedRegionFound := false
-		for _, supported := range supportedRegions {
-			if region == supported {
-				supportedRegionFound = true
-			}
-		}
-		if !supportedRegionFound {
-			return fmt.Errorf("Unsupported region: %s. Supported regions are: %s.", region, strings.Join(supportedRegions, ", "))
+		if !contains(SupportedRegions, region) {
+			return fmt.Errorf("Unsupported region: %s. Supported regions are: %s.", region, strings.Join(SupportedRegions, ", "))
 		}
 	}
 	for _, v := range c.Headers {
@@ -75,5 +117,451 @@ func (c *TestConfig) Check() error {
 			return fmt.Errorf("Header %s not valid. Make sure your header is of the form \"Header: value\"", v)
 		}
 	}
+	if err := templating.Validate(append([]string{c.URL, c.Body}, c.Headers...)...); err != nil {
+		return err
+	}
+	if err := assertion.Validate(c.Assertions); err != nil {
+		return err
+	}
+	if _, err := threshold.ParseAll(c.Thresholds); err != nil {
+		return err
+	}
+	if err := c.AbortPolicy.Check(); err != nil {
+		return err
+	}
+	if err := c.Function.Check(); err != nil {
+		return err
+	}
+	if !c.RunLocal && !c.RunDocker {
+		if err := c.Function.CheckNetworks(c.Regions); err != nil {
+			return err
+		}
+	}
+	if err := c.AWS.Check(); err != nil {
+		return err
+	}
+	if err := c.Export.Check(); err != nil {
+		return err
+	}
+	if c.Feeder != "" && !contains(templating.Feeders, c.Feeder) {
+		return fmt.Errorf("Unknown feeder %s (use %s)", c.Feeder, strings.Join(templating.Feeders, ", "))
+	}
+	if c.Scenario != nil && c.RequestsFile != "" {
+		return errors.New("Scenario and requests file can not be combined")
+	}
+	if c.Scenario != nil {
+		if err := c.Scenario.Validate(); err != nil {
+			return err
+		}
+		for _, step := range c.Scenario.Steps {
+			if err := assertion.Validate(step.Assert); err != nil {
+				return fmt.Errorf("Step %s: %s", step.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRate parses a request rate like "100/s", "600/m" or "50" (requests per
+// second) into requests per second.
+func ParseRate(rate string) (float64, error) {
+	rate = strings.TrimSpace(rate)
+	if rate == "" {
+		return 0, nil
+	}
+	unit := time.Second
+	parts := strings.SplitN(rate, "/", 2)
+	if len(parts) == 2 {
+		switch strings.TrimSpace(parts[1]) {
+		case "s", "sec":
+			unit = time.Second
+		case "m", "min":
+			unit = time.Minute
+		case "h":
+			unit = time.Hour
+		default:
+			return 0, fmt.Errorf("Invalid rate unit in %q (use /s, /m or /h)", rate)
+		}
+	}
+	count, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("Invalid rate %q, use e.g. 100/s", rate)
+	}
+	return count / unit.Seconds(), nil
+}
+
+// Stage is one step of a load profile. The concurrency is ramped linearly
+// from the target of the previous stage (or 0 for the first stage) to Target
+// over Duration.
+type Stage struct {
+	Duration time.Duration
+	Target   int
+}
+
+// String returns the stage in the "duration:target" notation used on the
+// command-line
+func (s Stage) String() string {
+	return fmt.Sprintf("%s:%d", s.Duration, s.Target)
+}
+
+// ParseStages parses stages in the "duration:target" notation, eg. "2m:500".
+// Each entry may hold a comma separated list of stages.
+func ParseStages(definitions []string) ([]Stage, error) {
+	stages := make([]Stage, 0)
+	for _, definition := range definitions {
+		for _, str := range strings.Split(definition, ",") {
+			str = strings.TrimSpace(str)
+			if str == "" {
+				continue
+			}
+			parts := strings.Split(str, ":")
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("Stage %s not valid. Make sure your stage is of the form \"duration:concurrency\", eg. 2m:500", str)
+			}
+			duration, err := time.ParseDuration(strings.TrimSpace(parts[0]))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid duration in stage %s: %s", str, err)
+			}
+			target, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid concurrency in stage %s: %s", str, err)
+			}
+			stages = append(stages, Stage{Duration: duration, Target: target})
+		}
+	}
+	return stages, nil
+}
+
+// StagesDuration returns the total duration of all stages
+func StagesDuration(stages []Stage) time.Duration {
+	var total time.Duration
+	for _, stage := range stages {
+		total += stage.Duration
+	}
+	return total
+}
+
+// StageAt returns the index of the stage active after elapsed time and the
+// interpolated concurrency at that point. done is true once all stages are
+// over.
+func StageAt(stages []Stage, elapsed time.Duration) (index int, concurrency float64, done bool) {
+	previous := 0
+	for i, stage := range stages {
+		if elapsed < stage.Duration {
+			progress := float64(elapsed) / float64(stage.Duration)
+			return i, float64(previous) + float64(stage.Target-previous)*progress, false
+		}
+		elapsed -= stage.Duration
+		previous = stage.Target
+	}
+	return len(stages) - 1, float64(previous), true
+}
+
+// ApplyStages derives the concurrency and timelimit from the configured
+// stages, the peak concurrency and the total duration of the profile. The
+// number of requests is not limited, the profile runs for all stages.
+func (c *TestConfig) ApplyStages() {
+	if len(c.Stages) == 0 {
+		return
+	}
+	c.Requests = 0
+	c.Concurrency = 0
+	for _, stage := range c.Stages {
+		if stage.Target > c.Concurrency {
+			c.Concurrency = stage.Target
+		}
+	}
+	c.Timelimit = int(math.Ceil(StagesDuration(c.Stages).Seconds()))
+}
+
+// AbortPolicy decides when a runner gives up on the target and stops its
+// workers. Zero values disable the single criteria.
+type AbortPolicy struct {
+	// ErrorRatio is the share of timed out and failed connections (0 - 1)
+	// above which the runner aborts
+	ErrorRatio float64
+	// MinSamples is the number of requests a runner needs to complete
+	// before the error ratio is checked
+	MinSamples int
+	// ConsecutiveTimeouts aborts after this many timeouts in a row
+	ConsecutiveTimeouts int
+	// Statuses aborts as soon as one of these status codes is received, eg.
+	// 429 or 503
+	Statuses []int
+}
+
+// DefaultAbortPolicy aborts once more than half of at least 100 requests
+// failed
+var DefaultAbortPolicy = AbortPolicy{ErrorRatio: 0.5, MinSamples: 100}
+
+// Check validates the policy
+func (p AbortPolicy) Check() error {
+	if p.ErrorRatio < 0 || p.ErrorRatio > 1 {
+		return errors.New("Invalid abort error ratio (use 0 - 1, 0 disables the check)")
+	}
+	if p.MinSamples < 0 || p.ConsecutiveTimeouts < 0 {
+		return errors.New("Invalid abort policy, sample size and consecutive timeouts must not be negative")
+	}
+	for _, status := range p.Statuses {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("Invalid abort status %d", status)
+		}
+	}
+	return nil
+}
+
+// ParseStatuses parses status codes, each entry may hold a comma separated
+// list
+func ParseStatuses(definitions []string) ([]int, error) {
+	statuses := make([]int, 0)
+	for _, definition := range definitions {
+		for _, str := range strings.Split(definition, ",") {
+			str = strings.TrimSpace(str)
+			if str == "" {
+				continue
+			}
+			status, err := strconv.Atoi(str)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid status code %q", str)
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
+// Architectures a lambda function can run on
+const (
+	ArchitectureX86   = "x86_64"
+	ArchitectureArm64 = "arm64"
+)
+
+// Architectures lists the supported lambda architectures
+var Architectures = []string{ArchitectureX86, ArchitectureArm64}
+
+// NamespaceTag is added to the tags of all AWS resources goad creates
+const NamespaceTag = "goad-namespace"
+
+var (
+	namespacePattern    = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,30}$`)
+	functionNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+	roleNamePattern     = regexp.MustCompile(`^[a-zA-Z0-9+=,.@_-]{1,64}$`)
+)
+
+// FunctionConfig describes the lambda function running the load test. All
+// AWS resources goad creates are named after the namespace, so several teams
+// can share an account without replacing each other's deployments.
+type FunctionConfig struct {
+	Namespace string
+	// Name of the function, the namespace is used when empty
+	Name string
+	// Role is the name of the IAM role of the function, derived from the
+	// namespace when empty
+	Role string
+	// MemorySize in MB
+	MemorySize int
+	// Timeout of a single invocation in seconds, runners fork a new
+	// invocation shortly before it is reached
+	Timeout      int
+	Architecture string
+	Tags         map[string]string
+	// Networks places the functions in a VPC, keyed by region
+	Networks map[string]Network
+}
+
+// Network holds the subnets and security groups of the function in one
+// region, eg. to load test endpoints only reachable inside a VPC
+type Network struct {
+	Subnets        []string
+	SecurityGroups []string
+}
+
+// DefaultFunctionConfig keeps the names and settings of previous releases
+var DefaultFunctionConfig = FunctionConfig{
+	Namespace:    "goad",
+	MemorySize:   1536,
+	Timeout:      300,
+	Architecture: ArchitectureX86,
+}
+
+// FunctionName returns the name of the lambda function
+func (f FunctionConfig) FunctionName() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return f.Namespace
+}
+
+// RoleName returns the name of the IAM role of the lambda function
+func (f FunctionConfig) RoleName() string {
+	if f.Role != "" {
+		return f.Role
+	}
+	return f.Namespace + "-lambda-role"
+}
+
+// QueuePrefix returns the prefix of the names of the SQS queues of a test
+func (f FunctionConfig) QueuePrefix() string {
+	return f.Namespace + "-"
+}
+
+// ResourceTags returns the configured tags together with the namespace tag
+func (f FunctionConfig) ResourceTags() map[string]string {
+	tags := map[string]string{NamespaceTag: f.Namespace}
+	for key, value := range f.Tags {
+		tags[key] = value
+	}
+	return tags
+}
+
+// Check validates the settings against the limits of AWS
+func (f FunctionConfig) Check() error {
+	if !namespacePattern.MatchString(f.Namespace) {
+		return fmt.Errorf("Invalid namespace %q (use up to 30 letters, digits, - and _)", f.Namespace)
+	}
+	if !functionNamePattern.MatchString(f.FunctionName()) {
+		return fmt.Errorf("Invalid function name %q (use up to 64 letters, digits, - and _)", f.FunctionName())
+	}
+	if !roleNamePattern.MatchString(f.RoleName()) {
+		return fmt.Errorf("Invalid role name %q", f.RoleName())
+	}
+	if f.MemorySize < 128 || f.MemorySize > 10240 {
+		return errors.New("Invalid lambda memory size (use 128 - 10240 MB)")
+	}
+	if f.Timeout < 10 || f.Timeout > 900 {
+		return errors.New("Invalid lambda timeout (use 10s - 900s)")
+	}
+	if !contains(Architectures, f.Architecture) {
+		return fmt.Errorf("Unknown architecture %s (use %s)", f.Architecture, strings.Join(Architectures, " or "))
+	}
+	for key := range f.Tags {
+		if key == "" {
+			return errors.New("Tags must have a key")
+		}
+	}
+	return nil
+}
+
+// CheckNetworks validates that every region has network settings once the
+// functions are placed in a VPC, a region without them would test other
+// endpoints than the rest
+func (f FunctionConfig) CheckNetworks(regions []string) error {
+	if len(f.Networks) == 0 {
+		return nil
+	}
+	for _, region := range regions {
+		network, ok := f.Networks[region]
+		if !ok {
+			return fmt.Errorf("No VPC settings for region %s, add a [vpc.%s] section with subnets and security groups", region, region)
+		}
+		if len(network.Subnets) == 0 || len(network.SecurityGroups) == 0 {
+			return fmt.Errorf("Invalid VPC settings for region %s, both subnets and security groups are required", region)
+		}
+	}
+	return nil
+}
+
+// ParseTags parses tags in the "key=value" notation
+func ParseTags(definitions []string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, definition := range definitions {
+		parts := strings.SplitN(definition, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" {
+			return nil, fmt.Errorf("Tag %s not valid. Make sure your tag is of the form \"key=value\"", definition)
+		}
+		tags[key] = strings.TrimSpace(parts[1])
+	}
+	return tags, nil
+}
+
+// AWSConfig selects the credentials and the endpoint of the AWS clients
+type AWSConfig struct {
+	// Profile of the shared credentials and config files, the default
+	// credential chain is used when empty
+	Profile string
+	// AssumeRoleARN is assumed with the credentials of the profile, eg. to
+	// run the test in another account
+	AssumeRoleARN string
+	ExternalID    string
+	// MFASerial identifies the MFA device the role requires, its token is
+	// read from the terminal
+	MFASerial string
+	// Endpoint replaces the endpoints of all AWS services, eg. with the one
+	// of a local emulator
+	Endpoint string
+}
+
+// Check validates the settings
+func (a AWSConfig) Check() error {
+	if a.AssumeRoleARN == "" && (a.ExternalID != "" || a.MFASerial != "") {
+		return errors.New("An external id or MFA device requires a role to assume")
+	}
+	if a.AssumeRoleARN != "" && !strings.HasPrefix(a.AssumeRoleARN, "arn:") {
+		return fmt.Errorf("Invalid role ARN %q", a.AssumeRoleARN)
+	}
+	if a.Endpoint != "" {
+		u, err := url.Parse(a.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Invalid AWS endpoint %q (use eg. http://localhost:4566)", a.Endpoint)
+		}
+	}
+	return nil
+}
+
+// DefaultExportInterval is the number of seconds between the exports of the
+// results to metrics systems
+const DefaultExportInterval = 10
+
+// ExportConfig selects the metrics systems the results are streamed to while
+// the test runs
+type ExportConfig struct {
+	// TestID tags the exported metrics, it identifies the test on dashboards
+	TestID string
+	// Interval is the number of seconds between exports
+	Interval int
+	// InfluxDBURL is the write endpoint of an InfluxDB, eg.
+	// http://localhost:8086/write?db=goad
+	InfluxDBURL   string
+	InfluxDBToken string `json:"-"`
+	// PushgatewayURL is the base URL of a Prometheus Pushgateway
+	PushgatewayURL string
+	// StatsDAddress is the host:port of a StatsD daemon
+	StatsDAddress string
+}
+
+// Enabled reports whether the results are exported at all
+func (e ExportConfig) Enabled() bool {
+	return e.InfluxDBURL != "" || e.PushgatewayURL != "" || e.StatsDAddress != ""
+}
+
+// Check validates the settings
+func (e ExportConfig) Check() error {
+	if e.Enabled() && e.Interval < 1 {
+		return errors.New("Invalid export interval (use a positive number of seconds)")
+	}
+	for _, endpoint := range []string{e.InfluxDBURL, e.PushgatewayURL} {
+		if endpoint == "" {
+			continue
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Invalid metrics endpoint %q (use eg. http://localhost:9091)", endpoint)
+		}
+	}
+	if e.StatsDAddress != "" {
+		if _, _, err := net.SplitHostPort(e.StatsDAddress); err != nil {
+			return fmt.Errorf("Invalid StatsD address %q (use eg. localhost:8125)", e.StatsDAddress)
+		}
+	}
 	return nil
 }
@@ -8,20 +8,25 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/goadapp/goad/api"
 	"github.com/goadapp/goad/goad/types"
 	"github.com/goadapp/goad/infrastructure/aws/sqsadapter"
 
 	"github.com/Songmu/prompter"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/goadapp/goad/infrastructure"
 	"github.com/goadapp/goad/result"
+	"github.com/goadapp/goad/transport"
 	"github.com/goadapp/goad/version"
 	uuid "github.com/satori/go.uuid"
 )
@@ -30,19 +35,36 @@ var (
 	roleDate = time.Date(2017, 07, 13, 18, 40, 0, 0, time.UTC)
 )
 
+const (
+	// lambdaRuntime runs the bootstrap executable of the function archive
+	lambdaRuntime = "provided.al2"
+	lambdaHandler = "bootstrap"
+	// updateRetries limits the attempts to update the code of the function
+	updateRetries = 30
+)
+
 // AwsInfrastructure manages the resource creation and updates necessary to use
 // Goad.
 type AwsInfrastructure struct {
-	config    *types.TestConfig
-	awsConfig *aws.Config
-	queueURL  string
+	failures     infrastructure.Failures
+	config       *types.TestConfig
+	awsConfig    *aws.Config
+	session      *session.Session
+	sessionErr   error
+	queueURL     string
+	controlURL   string
+	teardownOnce sync.Once
+	// stopped is closed by the teardown to stop receiving results
+	stopped chan struct{}
 }
 
 // New creates the required infrastructure to run the load tests in Lambda
 // functions.
 func New(config *types.TestConfig) infrastructure.Infrastructure {
 	awsConfig := aws.NewConfig().WithRegion(config.Regions[0])
-	infra := &AwsInfrastructure{config: config, awsConfig: awsConfig}
+	infra := &AwsInfrastructure{config: config, awsConfig: awsConfig, stopped: make(chan struct{})}
+	// an invalid profile or role is reported by Setup
+	infra.session, infra.sessionErr = NewSession(config.AWS)
 	return infra
 }
 
@@ -50,49 +72,52 @@ func (infra *AwsInfrastructure) GetSettings() *types.TestConfig {
 	return infra.config
 }
 
-// GetQueueURL returns the URL of the SQS queue to use for the load test session
+// GetQueueURL returns the transport URL of the SQS queue to use for the load
+// test session
 func (infra *AwsInfrastructure) GetQueueURL() string {
-	return infra.queueURL
+	if infra.config.AWS.Endpoint != "" {
+		return transport.SQSEndpointURL(infra.queueURL, infra.config.Regions[0], infra.config.AWS.Endpoint)
+	}
+	return transport.SQSURL(infra.queueURL)
 }
 
-func (infra *AwsInfrastructure) Receive(results chan *result.LambdaResults) {
-	defer close(results)
-	data := result.SetupRegionsAggData(infra.config.Lambdas)
-	adaptor := sqsadapter.New(infra.awsConfig, infra.GetQueueURL())
-
-	timeoutStart := time.Now()
-	for {
-		lambdaResults := adaptor.Receive()
-		if lambdaResults != nil {
-			for _, lambdaResult := range lambdaResults {
-				lambdaAggregate := &data.Lambdas[lambdaResult.RunnerID]
-				result.AddResult(lambdaAggregate, lambdaResult)
-				results <- data
-			}
-			if data.AllLambdasFinished() {
-				break
-			}
-			timeoutStart = time.Now()
-		} else {
-			waited := time.Since(timeoutStart)
-			if waited.Seconds() > 20 {
-				break
-			}
-		}
-	}
+// GetControlURL returns the URL of the SQS queue used to stop the runners
+func (infra *AwsInfrastructure) GetControlURL() string {
+	return infra.controlURL
 }
 
-func (infra *AwsInfrastructure) Run(args infrastructure.InvokeArgs) {
-	infra.invokeLambda(args)
+func (infra *AwsInfrastructure) Receive(results chan *result.LambdaResults) {
+	receiver, err := transport.NewReceiver(infra.GetQueueURL(), transport.WithAWSSession(infra.session))
+	handleErr(err)
+	defer receiver.Close()
+	infrastructure.Collect(receiver, infra.config, &infra.failures, infra.stopRequested, results)
 }
 
-func (infra *AwsInfrastructure) invokeLambda(args interface{}) {
-	svc := lambda.New(session.New(), infra.awsConfig)
+// stopRequested reports whether the test was torn down
+func (infra *AwsInfrastructure) stopRequested() bool {
+	select {
+	case <-infra.stopped:
+		return true
+	default:
+		return false
+	}
+}
 
-	svc.Invoke(&lambda.InvokeInput{
-		FunctionName: aws.String("goad"),
-		Payload:      toByteArray(args),
-	})
+// Run invokes the runner in its region and reports a failed invocation or a
+// crashed runner. Runners that fork invoke the fork asynchronously and return
+// before their function timeout, failures of forks are detected by the
+// heartbeat.
+func (infra *AwsInfrastructure) Run(config api.RunnerConfig) {
+	svc := lambda.New(infra.session, aws.NewConfig().WithRegion(config.Region))
+	output, err := invokeLambda(svc, infra.config.Function.FunctionName(), toByteArray(config))
+	if err != nil {
+		infra.failures.Add(config.RunnerID, config.Region, fmt.Sprintf("invocation failed: %s", err), "")
+		return
+	}
+	if output.FunctionError != nil {
+		reason := fmt.Sprintf("runner failed: %s", functionErrorMessage(output.Payload))
+		infra.failures.Add(config.RunnerID, config.Region, reason, decodeLogTail(output.LogResult))
+	}
 }
 
 func toByteArray(args interface{}) []byte {
@@ -107,14 +132,25 @@ func handleErr(err error) {
 	}
 }
 
-// teardown removes any AWS resources that cannot be reused for a subsequent
-// test
+// teardown stops the runners and removes any AWS resources that cannot be
+// reused for a subsequent test, it is safe to call more than once
 func (infra *AwsInfrastructure) teardown() {
-	infra.removeSQSQueue()
+	infra.teardownOnce.Do(func() {
+		close(infra.stopped)
+		if infra.controlURL != "" {
+			infra.stopRunners()
+		}
+		if infra.queueURL != "" {
+			infra.removeSQSQueue()
+		}
+	})
 }
 
 func (infra *AwsInfrastructure) Setup() (func(), error) {
-	roleArn, err := infra.createIAMLambdaRole("goad-lambda-role")
+	if infra.sessionErr != nil {
+		return nil, infra.sessionErr
+	}
+	roleArn, err := infra.createIAMLambdaRole(infra.config.Function.RoleName())
 	if err != nil {
 		return nil, err
 	}
@@ -127,15 +163,19 @@ func (infra *AwsInfrastructure) Setup() (func(), error) {
 			return nil, err
 		}
 	} else {
-		assetBytes, assetErr := infrastructure.Asset(infrastructure.DefaultRunnerAsset)
+		assetBytes, assetErr := infrastructure.Asset(infrastructure.RunnerAsset(infra.config.Function.Architecture))
 		if assetErr != nil {
 			return nil, assetErr
 		}
 		zipBuffer = *bytes.NewBuffer(assetBytes)
 	}
+	payload, err := infrastructure.AddRunnerFiles(zipBuffer.Bytes(), infra.config)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, region := range infra.config.Regions {
-		err = infra.createOrUpdateLambdaFunction(region, roleArn, zipBuffer.Bytes())
+		err = infra.createOrUpdateLambdaFunction(region, roleArn, payload)
 		if err != nil {
 			return nil, err
 		}
@@ -145,9 +185,23 @@ func (infra *AwsInfrastructure) Setup() (func(), error) {
 		return nil, err
 	}
 	infra.queueURL = queueURL
-	return func() {
+	controlURL, err := infra.createControlQueue()
+	if err != nil {
+		infra.teardown()
+		return nil, err
+	}
+	infra.controlURL = controlURL
+	return infra.teardown, nil
+}
 
-	}, nil
+// stopRunners signals all runners of the test, including forked ones, to
+// stop and removes the control queue, runners stop on a missing queue as well
+func (infra *AwsInfrastructure) stopRunners() {
+	control := sqsadapter.NewControl(infra.session, infra.awsConfig, infra.controlURL)
+	if err := control.Stop(); err != nil {
+		fmt.Println(err.Error())
+	}
+	infra.removeControlQueue()
 }
 
 func calcShasum(payload []byte) string {
@@ -157,59 +211,124 @@ func calcShasum(payload []byte) string {
 
 func (infra *AwsInfrastructure) createOrUpdateLambdaFunction(region, roleArn string, payload []byte) error {
 	config := aws.NewConfig().WithRegion(region)
-	svc := lambda.New(session.New(), config)
+	svc := lambda.New(infra.session, config)
+	function := infra.config.Function
+	network := function.Networks[region]
 
-	exists, err := lambdaExists(svc)
+	exists, err := lambdaExists(svc, function.FunctionName())
 	if err != nil {
 		return err
 	}
 	if !exists {
-		return infra.createLambdaFunction(svc, roleArn, payload)
+		return infra.createLambdaFunction(svc, roleArn, network, payload)
 	}
-	upToDate, err := lambdaUpToDate(svc, calcShasum(payload))
+	upToDate, err := lambdaUpToDate(svc, function, network, roleArn, calcShasum(payload))
 	if err != nil {
 		return err
 	}
 	if !upToDate {
-		return infra.updateLambdaFunction(svc, roleArn, payload)
+		return infra.updateLambdaFunction(svc, roleArn, network, payload)
 	}
 	return nil
 }
 
-func (infra *AwsInfrastructure) createLambdaFunction(svc *lambda.Lambda, roleArn string, payload []byte) error {
-	function, err := svc.CreateFunction(&lambda.CreateFunctionInput{
+// vpcConfig returns the VPC settings of the function, without subnets the
+// function is detached from its VPC
+func vpcConfig(network types.Network) *lambda.VpcConfig {
+	return &lambda.VpcConfig{
+		SubnetIds:        aws.StringSlice(network.Subnets),
+		SecurityGroupIds: aws.StringSlice(network.SecurityGroups),
+	}
+}
+
+// architectures sets the architecture of the function, the field is
+// unknown to the vendored SDK
+func architectures(function types.FunctionConfig) request.Option {
+	return withJSONFields(map[string]interface{}{
+		"Architectures": []string{function.Architecture},
+	})
+}
+
+func awsTags(tags map[string]string) map[string]*string {
+	awsTags := make(map[string]*string)
+	for key, value := range tags {
+		awsTags[key] = aws.String(value)
+	}
+	return awsTags
+}
+
+func (infra *AwsInfrastructure) createLambdaFunction(svc *lambda.Lambda, roleArn string, network types.Network, payload []byte) error {
+	settings := infra.config.Function
+	function, err := svc.CreateFunctionWithContext(aws.BackgroundContext(), &lambda.CreateFunctionInput{
 		Code: &lambda.FunctionCode{
 			ZipFile: payload,
 		},
-		FunctionName: aws.String("goad"),
-		Handler:      aws.String("index.handler"),
+		FunctionName: aws.String(settings.FunctionName()),
+		Handler:      aws.String(lambdaHandler),
 		Role:         aws.String(roleArn),
-		Runtime:      aws.String("nodejs8.10"),
-		MemorySize:   aws.Int64(1536),
+		Runtime:      aws.String(lambdaRuntime),
+		MemorySize:   aws.Int64(int64(settings.MemorySize)),
 		Publish:      aws.Bool(true),
-		Timeout:      aws.Int64(300),
-	})
+		Timeout:      aws.Int64(int64(settings.Timeout)),
+		Tags:         awsTags(settings.ResourceTags()),
+		VpcConfig:    vpcConfig(network),
+	}, architectures(settings))
 	if err != nil {
 		return err
 	}
-	return createOrUpdateLambdaAlias(svc, function.Version)
+	return createOrUpdateLambdaAlias(svc, settings.FunctionName(), function.Version)
 }
 
-func (infra *AwsInfrastructure) updateLambdaFunction(svc *lambda.Lambda, roleArn string, payload []byte) error {
-	function, err := svc.UpdateFunctionCode(&lambda.UpdateFunctionCodeInput{
-		ZipFile:      payload,
-		FunctionName: aws.String("goad"),
-		Publish:      aws.Bool(true),
+func (infra *AwsInfrastructure) updateLambdaFunction(svc *lambda.Lambda, roleArn string, network types.Network, payload []byte) error {
+	settings := infra.config.Function
+	// functions deployed by previous versions still use the Node.js runtime
+	current, err := svc.UpdateFunctionConfiguration(&lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(settings.FunctionName()),
+		Handler:      aws.String(lambdaHandler),
+		Runtime:      aws.String(lambdaRuntime),
+		Role:         aws.String(roleArn),
+		MemorySize:   aws.Int64(int64(settings.MemorySize)),
+		Timeout:      aws.Int64(int64(settings.Timeout)),
+		VpcConfig:    vpcConfig(network),
 	})
 	if err != nil {
 		return err
 	}
-	return createOrUpdateLambdaAlias(svc, function.Version)
+	_, err = svc.TagResource(&lambda.TagResourceInput{
+		Resource: current.FunctionArn,
+		Tags:     awsTags(settings.ResourceTags()),
+	})
+	if err != nil {
+		return err
+	}
+	var function *lambda.FunctionConfiguration
+	// the code cannot be updated until the configuration update completed,
+	// the architecture is changed together with the code
+	for attempt := 0; ; attempt++ {
+		function, err = svc.UpdateFunctionCodeWithContext(aws.BackgroundContext(), &lambda.UpdateFunctionCodeInput{
+			ZipFile:      payload,
+			FunctionName: aws.String(settings.FunctionName()),
+			Publish:      aws.Bool(true),
+		}, architectures(settings))
+		if !isResourceConflict(err) || attempt == updateRetries {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		return err
+	}
+	return createOrUpdateLambdaAlias(svc, settings.FunctionName(), function.Version)
+}
+
+func isResourceConflict(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == lambda.ErrCodeResourceConflictException
 }
 
-func lambdaExists(svc *lambda.Lambda) (bool, error) {
+func lambdaExists(svc *lambda.Lambda, name string) (bool, error) {
 	_, err := svc.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
-		FunctionName: aws.String("goad"),
+		FunctionName: aws.String(name),
 	})
 	notFound, err := checkResourseNotFound(err)
 	if err != nil {
@@ -221,14 +340,46 @@ func lambdaExists(svc *lambda.Lambda) (bool, error) {
 	return true, nil
 }
 
-func lambdaUpToDate(svc *lambda.Lambda, shasum string) (bool, error) {
+// lambdaUpToDate compares the deployed function with the settings, a change
+// of the architecture changes the runner archive and thereby its checksum
+func lambdaUpToDate(svc *lambda.Lambda, function types.FunctionConfig, network types.Network, roleArn, shasum string) (bool, error) {
 	config, err := svc.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
-		FunctionName: aws.String("goad"),
+		FunctionName: aws.String(function.FunctionName()),
 	})
 	if err != nil {
 		return false, err
 	}
-	return *config.CodeSha256 == shasum, nil
+	return *config.CodeSha256 == shasum &&
+		aws.StringValue(config.Runtime) == lambdaRuntime &&
+		aws.StringValue(config.Role) == roleArn &&
+		aws.Int64Value(config.MemorySize) == int64(function.MemorySize) &&
+		aws.Int64Value(config.Timeout) == int64(function.Timeout) &&
+		vpcUpToDate(config.VpcConfig, network), nil
+}
+
+func vpcUpToDate(config *lambda.VpcConfigResponse, network types.Network) bool {
+	if config == nil {
+		return len(network.Subnets) == 0
+	}
+	return sameStrings(aws.StringValueSlice(config.SubnetIds), network.Subnets) &&
+		sameStrings(aws.StringValueSlice(config.SecurityGroupIds), network.SecurityGroups)
+}
+
+// sameStrings compares two lists ignoring their order
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
 }
 
 func checkResourseNotFound(err error) (bool, error) {
@@ -243,38 +394,38 @@ func checkResourseNotFound(err error) (bool, error) {
 	return false, err
 }
 
-func createOrUpdateLambdaAlias(svc *lambda.Lambda, functionVersion *string) error {
+func createOrUpdateLambdaAlias(svc *lambda.Lambda, name string, functionVersion *string) error {
 	_, err := svc.GetAlias(&lambda.GetAliasInput{
-		FunctionName: aws.String("goad"),
+		FunctionName: aws.String(name),
 		Name:         aws.String(version.LambdaVersion()),
 	})
 	if err != nil {
-		return createLambdaAlias(svc, functionVersion)
+		return createLambdaAlias(svc, name, functionVersion)
 	}
-	return updateLambdaAlias(svc, functionVersion)
+	return updateLambdaAlias(svc, name, functionVersion)
 }
 
-func createLambdaAlias(svc *lambda.Lambda, functionVersion *string) error {
+func createLambdaAlias(svc *lambda.Lambda, name string, functionVersion *string) error {
 	_, err := svc.CreateAlias(&lambda.CreateAliasInput{
-		FunctionName:    aws.String("goad"),
+		FunctionName:    aws.String(name),
 		FunctionVersion: aws.String("$LATEST"),
 		Name:            aws.String(version.LambdaVersion()),
 	})
 	return err
 }
 
-func updateLambdaAlias(svc *lambda.Lambda, functionVersion *string) error {
+func updateLambdaAlias(svc *lambda.Lambda, name string, functionVersion *string) error {
 	_, err := svc.UpdateAlias(&lambda.UpdateAliasInput{
-		FunctionName:    aws.String("goad"),
+		FunctionName:    aws.String(name),
 		FunctionVersion: aws.String("$LATEST"),
 		Name:            aws.String(version.LambdaVersion()),
 	})
 	return err
 }
 
-func lambdaAliasExists(svc *lambda.Lambda) (bool, error) {
+func lambdaAliasExists(svc *lambda.Lambda, name string) (bool, error) {
 	_, err := svc.GetAlias(&lambda.GetAliasInput{
-		FunctionName: aws.String("goad"),
+		FunctionName: aws.String(name),
 		Name:         aws.String(version.LambdaVersion()),
 	})
 
@@ -291,7 +442,7 @@ func lambdaAliasExists(svc *lambda.Lambda) (bool, error) {
 }
 
 func (infra *AwsInfrastructure) createIAMLambdaRole(roleName string) (arn string, err error) {
-	svc := iam.New(session.New(), infra.awsConfig)
+	svc := iam.New(infra.session, infra.awsConfig)
 
 	resp, err := svc.GetRole(&iam.GetRoleInput{
 		RoleName: aws.String(roleName),
@@ -299,7 +450,7 @@ func (infra *AwsInfrastructure) createIAMLambdaRole(roleName string) (arn string
 	if err != nil {
 		if awsErr, ok := err.(awserr.Error); ok {
 			if awsErr.Code() == "NoSuchEntity" {
-				res, err := svc.CreateRole(&iam.CreateRoleInput{
+				res, err := svc.CreateRoleWithContext(aws.BackgroundContext(), &iam.CreateRoleInput{
 					AssumeRolePolicyDocument: aws.String(`{
         	          "Version": "2012-10-17",
         	          "Statement": {
@@ -310,7 +461,7 @@ func (infra *AwsInfrastructure) createIAMLambdaRole(roleName string) (arn string
             	    }`),
 					RoleName: aws.String(roleName),
 					Path:     aws.String("/"),
-				})
+				}, withQueryParams(tagParams("Tags.member", infra.config.Function.ResourceTags())))
 				if err != nil {
 					return "", err
 				}
@@ -324,6 +475,11 @@ func (infra *AwsInfrastructure) createIAMLambdaRole(roleName string) (arn string
 	}
 
 	CheckRoleDate(resp.Role)
+	// keep the policy of existing roles up to date with the permissions the
+	// current runner needs
+	if err := infra.createIAMLambdaRolePolicy(roleName); err != nil {
+		return "", err
+	}
 	return *resp.Role.Arn, nil
 }
 
@@ -336,18 +492,20 @@ func CheckRoleDate(role *iam.Role) {
 }
 
 func (infra *AwsInfrastructure) createIAMLambdaRolePolicy(roleName string) error {
-	svc := iam.New(session.New(), infra.awsConfig)
+	svc := iam.New(infra.session, infra.awsConfig)
+	function := infra.config.Function
 
 	_, err := svc.PutRolePolicy(&iam.PutRolePolicyInput{
-		PolicyDocument: aws.String(`{
+		PolicyDocument: aws.String(fmt.Sprintf(`{
           "Version": "2012-10-17",
           "Statement": [
 					{
 				 "Action": [
-						 "sqs:SendMessage"
+						 "sqs:SendMessage",
+						 "sqs:GetQueueAttributes"
 				 ],
 				 "Effect": "Allow",
-				 "Resource": "arn:aws:sqs:*:*:goad-*"
+				 "Resource": "arn:aws:sqs:*:*:%s*"
 		 },
 		 {
 				 "Effect": "Allow",
@@ -355,7 +513,7 @@ func (infra *AwsInfrastructure) createIAMLambdaRolePolicy(roleName string) error
 						 "lambda:Invoke*"
 				 ],
 				 "Resource": [
-						 "arn:aws:lambda:*:*:function:goad"
+						 "arn:aws:lambda:*:*:function:%s"
 				 ]
 		 },
 			{
@@ -366,24 +524,44 @@ func (infra *AwsInfrastructure) createIAMLambdaRolePolicy(roleName string) error
               ],
               "Effect": "Allow",
               "Resource": "arn:aws:logs:*:*:*"
-	        }
+	        }%s
           ]
-        }`),
+        }`, function.QueuePrefix(), function.FunctionName(), infra.networkPolicyStatement())),
 		PolicyName: aws.String("goad-lambda-role-policy"),
 		RoleName:   aws.String(roleName),
 	})
 	return err
 }
 
+// networkPolicyStatement allows functions in a VPC to manage their network
+// interfaces, it is empty for functions without VPC settings
+func (infra *AwsInfrastructure) networkPolicyStatement() string {
+	if len(infra.config.Function.Networks) == 0 {
+		return ""
+	}
+	return `,
+			{
+              "Action": [
+                "ec2:CreateNetworkInterface",
+                "ec2:DescribeNetworkInterfaces",
+                "ec2:DeleteNetworkInterface",
+                "ec2:AssignPrivateIpAddresses",
+                "ec2:UnassignPrivateIpAddresses"
+              ],
+              "Effect": "Allow",
+              "Resource": "*"
+	        }`
+}
+
 func (infra *AwsInfrastructure) createSQSQueue() (url string, err error) {
-	svc := sqs.New(session.New(), infra.awsConfig)
+	svc := sqs.New(infra.session, infra.awsConfig)
 
-	resp, err := svc.CreateQueue(&sqs.CreateQueueInput{
-		QueueName: aws.String("goad-" + uuid.NewV4().String() + ".fifo"),
+	resp, err := svc.CreateQueueWithContext(aws.BackgroundContext(), &sqs.CreateQueueInput{
+		QueueName: aws.String(infra.config.Function.QueuePrefix() + uuid.NewV4().String() + ".fifo"),
 		Attributes: map[string]*string{
 			"FifoQueue": aws.String("true"),
 		},
-	})
+	}, infra.queueTags())
 
 	if err != nil {
 		return "", err
@@ -391,8 +569,37 @@ func (infra *AwsInfrastructure) createSQSQueue() (url string, err error) {
 	return *resp.QueueUrl, nil
 }
 
+// createControlQueue creates the queue used to stop the runners, it is a
+// standard queue as runners only look at the number of messages
+func (infra *AwsInfrastructure) createControlQueue() (url string, err error) {
+	svc := sqs.New(infra.session, infra.awsConfig)
+
+	resp, err := svc.CreateQueueWithContext(aws.BackgroundContext(), &sqs.CreateQueueInput{
+		QueueName: aws.String(infra.config.Function.QueuePrefix() + "control-" + uuid.NewV4().String()),
+	}, infra.queueTags())
+
+	if err != nil {
+		return "", err
+	}
+	return *resp.QueueUrl, nil
+}
+
+// queueTags tags the queues on creation, the vendored SDK does not know the
+// parameter
+func (infra *AwsInfrastructure) queueTags() request.Option {
+	return withQueryParams(tagParams("Tag", infra.config.Function.ResourceTags()))
+}
+
+func (infra *AwsInfrastructure) removeControlQueue() {
+	svc := sqs.New(infra.session, infra.awsConfig)
+
+	svc.DeleteQueue(&sqs.DeleteQueueInput{
+		QueueUrl: aws.String(infra.controlURL),
+	})
+}
+
 func (infra *AwsInfrastructure) removeSQSQueue() {
-	svc := sqs.New(session.New(), infra.awsConfig)
+	svc := sqs.New(infra.session, infra.awsConfig)
 
 	svc.DeleteQueue(&sqs.DeleteQueueInput{
 		QueueUrl: aws.String(infra.queueURL),
@@ -0,0 +1,189 @@
+package awsinfra
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/goadapp/goad/goad/types"
+)
+
+const (
+	iamNoSuchEntity = "NoSuchEntity"
+	iamConfigRegion = "us-east-1"
+)
+
+// queueNameSuffix matches the names of the queues of a test after the
+// namespace prefix, so other namespaces sharing the prefix are left alone
+var queueNameSuffix = regexp.MustCompile(`^(control-)?[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}(\.fifo)?$`)
+
+// resource is an AWS resource created by goad
+type resource struct {
+	kind   string
+	name   string
+	region string
+	remove func() error
+}
+
+func (r resource) String() string {
+	if r.region == "" {
+		return fmt.Sprintf("%s %s", r.kind, r.name)
+	}
+	return fmt.Sprintf("%s %s in %s", r.kind, r.name, r.region)
+}
+
+// Cleanup deletes the lambda functions and aliases, the SQS queues and the
+// IAM role goad created for the namespace of function in the given regions,
+// the clients are created from sess. With dryRun the resources are only
+// listed. Failures are reported and the remaining resources deleted.
+func Cleanup(sess *session.Session, function types.FunctionConfig, regions []string, dryRun bool, out io.Writer) error {
+	resources, err := listResources(sess, function, regions)
+	if err != nil {
+		return err
+	}
+	if len(resources) == 0 {
+		fmt.Fprintln(out, "No resources created by goad found")
+		return nil
+	}
+	failed := 0
+	for _, r := range resources {
+		if dryRun {
+			fmt.Fprintf(out, "Would delete %s\n", r)
+			continue
+		}
+		fmt.Fprintf(out, "Deleting %s\n", r)
+		if err := r.remove(); err != nil {
+			fmt.Fprintf(out, "  failed: %s\n", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d resources could not be deleted", failed, len(resources))
+	}
+	return nil
+}
+
+// listResources returns the resources in the order they can be deleted in
+func listResources(sess *session.Session, function types.FunctionConfig, regions []string) ([]resource, error) {
+	resources := make([]resource, 0)
+	for _, region := range regions {
+		config := aws.NewConfig().WithRegion(region)
+		functions, err := listLambdaResources(lambda.New(sess, config), function.FunctionName(), region)
+		if err != nil {
+			return nil, err
+		}
+		queues, err := listQueueResources(sqs.New(sess, config), function.QueuePrefix(), region)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, functions...)
+		resources = append(resources, queues...)
+	}
+	roles, err := listRoleResources(iam.New(sess, aws.NewConfig().WithRegion(iamConfigRegion)), function.RoleName())
+	if err != nil {
+		return nil, err
+	}
+	return append(resources, roles...), nil
+}
+
+func listLambdaResources(svc *lambda.Lambda, functionName, region string) ([]resource, error) {
+	exists, err := lambdaExists(svc, functionName)
+	if err != nil || !exists {
+		return nil, err
+	}
+	resources := make([]resource, 0)
+	input := &lambda.ListAliasesInput{FunctionName: aws.String(functionName)}
+	for {
+		page, err := svc.ListAliases(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, alias := range page.Aliases {
+			name := aws.StringValue(alias.Name)
+			resources = append(resources, resource{"lambda alias", functionName + ":" + name, region, func() error {
+				_, err := svc.DeleteAlias(&lambda.DeleteAliasInput{
+					FunctionName: aws.String(functionName),
+					Name:         aws.String(name),
+				})
+				return err
+			}})
+		}
+		if page.NextMarker == nil {
+			break
+		}
+		input.Marker = page.NextMarker
+	}
+	return append(resources, resource{"lambda function", functionName, region, func() error {
+		_, err := svc.DeleteFunction(&lambda.DeleteFunctionInput{
+			FunctionName: aws.String(functionName),
+		})
+		return err
+	}}), nil
+}
+
+func listQueueResources(svc *sqs.SQS, prefix, region string) ([]resource, error) {
+	resp, err := svc.ListQueues(&sqs.ListQueuesInput{
+		QueueNamePrefix: aws.String(prefix),
+	})
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]resource, 0)
+	for _, queueURL := range resp.QueueUrls {
+		url := aws.StringValue(queueURL)
+		name := path.Base(url)
+		if !queueNameSuffix.MatchString(strings.TrimPrefix(name, prefix)) {
+			continue
+		}
+		resources = append(resources, resource{"SQS queue", name, region, func() error {
+			_, err := svc.DeleteQueue(&sqs.DeleteQueueInput{
+				QueueUrl: aws.String(url),
+			})
+			return err
+		}})
+	}
+	return resources, nil
+}
+
+func listRoleResources(svc *iam.IAM, roleName string) ([]resource, error) {
+	_, err := svc.GetRole(&iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == iamNoSuchEntity {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	policies, err := svc.ListRolePolicies(&iam.ListRolePoliciesInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]resource, 0)
+	for _, policyName := range policies.PolicyNames {
+		name := aws.StringValue(policyName)
+		resources = append(resources, resource{"IAM role policy", roleName + "/" + name, "", func() error {
+			_, err := svc.DeleteRolePolicy(&iam.DeleteRolePolicyInput{
+				RoleName:   aws.String(roleName),
+				PolicyName: aws.String(name),
+			})
+			return err
+		}})
+	}
+	return append(resources, resource{"IAM role", roleName, "", func() error {
+		_, err := svc.DeleteRole(&iam.DeleteRoleInput{
+			RoleName: aws.String(roleName),
+		})
+		return err
+	}}), nil
+}
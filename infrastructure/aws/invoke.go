@@ -0,0 +1,91 @@
+package awsinfra
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+)
+
+const (
+	// invokeRetries limits the attempts to invoke a throttled runner
+	invokeRetries = 6
+	// invokeBackoff is the pause before the first retry, it doubles with
+	// every attempt up to maxInvokeBackoff
+	invokeBackoff    = time.Second
+	maxInvokeBackoff = 16 * time.Second
+)
+
+// ec2ThrottledException is returned when the ENIs of a function in a VPC
+// cannot be created fast enough, the vendored SDK does not define it
+const ec2ThrottledException = "EC2ThrottledException"
+
+// sleep is replaced by tests
+var sleep = time.Sleep
+
+// invokeLambda invokes the function and waits for the runner to return,
+// throttled invocations are retried with an exponential backoff
+func invokeLambda(svc lambdaiface.LambdaAPI, functionName string, payload []byte) (*lambda.InvokeOutput, error) {
+	backoff := invokeBackoff
+	for attempt := 1; ; attempt++ {
+		output, err := svc.Invoke(&lambda.InvokeInput{
+			FunctionName: aws.String(functionName),
+			Payload:      payload,
+			LogType:      aws.String(lambda.LogTypeTail),
+		})
+		if err == nil || !isRetryableInvokeError(err) || attempt == invokeRetries {
+			return output, err
+		}
+		sleep(backoff)
+		if backoff *= 2; backoff > maxInvokeBackoff {
+			backoff = maxInvokeBackoff
+		}
+	}
+}
+
+// isRetryableInvokeError reports whether the invocation was throttled or the
+// function is still being updated. Other errors, eg. a ServiceException, are
+// not retried, the runner may have been started already and a retry would
+// run it twice.
+func isRetryableInvokeError(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch awsErr.Code() {
+	case lambda.ErrCodeTooManyRequestsException, ec2ThrottledException, lambda.ErrCodeResourceConflictException:
+		return true
+	}
+	return false
+}
+
+// functionErrorMessage returns the message of the error payload of a failed
+// runner
+func functionErrorMessage(payload []byte) string {
+	var functionError struct {
+		ErrorMessage string `json:"errorMessage"`
+		ErrorType    string `json:"errorType"`
+	}
+	if err := json.Unmarshal(payload, &functionError); err != nil || functionError.ErrorMessage == "" {
+		return strings.TrimSpace(string(payload))
+	}
+	if functionError.ErrorType != "" {
+		return functionError.ErrorType + ": " + functionError.ErrorMessage
+	}
+	return functionError.ErrorMessage
+}
+
+// decodeLogTail returns the end of the log of an invocation, lambda returns
+// the last 4 KB base64 encoded
+func decodeLogTail(logResult *string) string {
+	log, err := base64.StdEncoding.DecodeString(aws.StringValue(logResult))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(log))
+}
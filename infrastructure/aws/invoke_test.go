@@ -0,0 +1,95 @@
+package awsinfra
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// invokeClient fails the first invocations with the given errors
+type invokeClient struct {
+	lambdaiface.LambdaAPI
+	errs  []error
+	calls int
+}
+
+func (c *invokeClient) Invoke(input *lambda.InvokeInput) (*lambda.InvokeOutput, error) {
+	c.calls++
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		return nil, err
+	}
+	return &lambda.InvokeOutput{StatusCode: aws.Int64(200)}, nil
+}
+
+func withoutSleep() *[]time.Duration {
+	var pauses []time.Duration
+	sleep = func(d time.Duration) { pauses = append(pauses, d) }
+	return &pauses
+}
+
+func TestThrottledInvocationIsRetried(t *testing.T) {
+	assert := assert.New(t)
+	pauses := withoutSleep()
+	defer func() { sleep = time.Sleep }()
+	throttled := awserr.New(lambda.ErrCodeTooManyRequestsException, "Rate exceeded", nil)
+	client := &invokeClient{errs: []error{throttled, throttled}}
+
+	_, err := invokeLambda(client, "goad", nil)
+	assert.NoError(err)
+	assert.Equal(3, client.calls)
+	assert.Equal([]time.Duration{time.Second, 2 * time.Second}, *pauses)
+}
+
+func TestInvocationGivesUpAfterRetries(t *testing.T) {
+	assert := assert.New(t)
+	withoutSleep()
+	defer func() { sleep = time.Sleep }()
+	throttled := awserr.New(lambda.ErrCodeTooManyRequestsException, "Rate exceeded", nil)
+	client := &invokeClient{errs: []error{throttled, throttled, throttled, throttled, throttled, throttled, throttled}}
+
+	_, err := invokeLambda(client, "goad", nil)
+	assert.Equal(throttled, err)
+	assert.Equal(invokeRetries, client.calls)
+}
+
+func TestInvocationErrorIsNotRetried(t *testing.T) {
+	assert := assert.New(t)
+	withoutSleep()
+	defer func() { sleep = time.Sleep }()
+	client := &invokeClient{errs: []error{errors.New("connection refused")}}
+
+	_, err := invokeLambda(client, "goad", nil)
+	assert.Error(err)
+	assert.Equal(1, client.calls)
+}
+
+func TestServiceExceptionIsNotRetried(t *testing.T) {
+	assert := assert.New(t)
+	withoutSleep()
+	defer func() { sleep = time.Sleep }()
+	failed := awserr.New(lambda.ErrCodeServiceException, "internal error", nil)
+	client := &invokeClient{errs: []error{failed}}
+
+	_, err := invokeLambda(client, "goad", nil)
+	assert.Equal(failed, err, "the runner may have started, a retry would start it twice")
+	assert.Equal(1, client.calls)
+}
+
+func TestFunctionErrorIsDecoded(t *testing.T) {
+	assert := assert.New(t)
+	payload := []byte(`{"errorMessage":"out of memory","errorType":"Runtime.ExitError"}`)
+	assert.Equal("Runtime.ExitError: out of memory", functionErrorMessage(payload))
+	assert.Equal("Unhandled", functionErrorMessage([]byte("Unhandled")))
+
+	logResult := base64.StdEncoding.EncodeToString([]byte("START RequestId: 1\nEND RequestId: 1\n"))
+	assert.Equal("START RequestId: 1\nEND RequestId: 1", decodeLogTail(aws.String(logResult)))
+}
@@ -0,0 +1,85 @@
+package awsinfra
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// The vendored SDK predates the architectures of lambda functions and the
+// tags of SQS queues and IAM roles. The options below add such parameters to
+// the serialized request, the body is signed afterwards.
+
+// withJSONFields adds fields to the JSON body of a request to a REST-JSON
+// API, eg. the architecture of a lambda function
+func withJSONFields(fields map[string]interface{}) request.Option {
+	return withBody(func(body []byte) ([]byte, error) {
+		document := make(map[string]interface{})
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &document); err != nil {
+				return nil, err
+			}
+		}
+		for key, value := range fields {
+			document[key] = value
+		}
+		return json.Marshal(document)
+	})
+}
+
+// withQueryParams adds parameters to the form encoded body of a request to a
+// query API like SQS or IAM
+func withQueryParams(params url.Values) request.Option {
+	return withBody(func(body []byte) ([]byte, error) {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range params {
+			form[key] = values
+		}
+		return []byte(form.Encode()), nil
+	})
+}
+
+// withBody replaces the body of a request once the SDK built it
+func withBody(rewrite func(body []byte) ([]byte, error)) request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Build.PushBack(func(r *request.Request) {
+			if r.Error != nil || r.Body == nil {
+				return
+			}
+			r.Body.Seek(0, 0)
+			body, err := ioutil.ReadAll(r.Body)
+			if err == nil {
+				body, err = rewrite(body)
+			}
+			if err != nil {
+				r.Error = err
+				return
+			}
+			r.SetBufferBody(body)
+		})
+	}
+}
+
+// tagParams returns the query parameters of tags, prefix is "Tag" for SQS
+// and "Tags.member" for IAM
+func tagParams(prefix string, tags map[string]string) url.Values {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	params := url.Values{}
+	for i, key := range keys {
+		n := prefix + "." + strconv.Itoa(i+1)
+		params.Set(n+".Key", key)
+		params.Set(n+".Value", tags[key])
+	}
+	return params
+}
@@ -0,0 +1,56 @@
+package awsinfra
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/goadapp/goad/goad/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSession() *session.Session {
+	return session.New(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")))
+}
+
+func TestQueueTagsAreAddedToRequest(t *testing.T) {
+	assert := assert.New(t)
+	svc := sqs.New(testSession())
+	req, _ := svc.CreateQueueRequest(&sqs.CreateQueueInput{QueueName: aws.String("team-a-queue")})
+	req.ApplyOptions(withQueryParams(tagParams("Tag", map[string]string{"team": "perf", "goad-namespace": "team-a"})))
+	assert.NoError(req.Build())
+
+	body, _ := ioutil.ReadAll(req.Body)
+	form, err := url.ParseQuery(string(body))
+	assert.NoError(err)
+	assert.Equal("team-a-queue", form.Get("QueueName"))
+	assert.Equal("goad-namespace", form.Get("Tag.1.Key"))
+	assert.Equal("team-a", form.Get("Tag.1.Value"))
+	assert.Equal("team", form.Get("Tag.2.Key"))
+	assert.Equal("perf", form.Get("Tag.2.Value"))
+}
+
+func TestArchitectureIsAddedToRequest(t *testing.T) {
+	assert := assert.New(t)
+	svc := lambda.New(testSession())
+	req, _ := svc.UpdateFunctionCodeRequest(&lambda.UpdateFunctionCodeInput{
+		FunctionName: aws.String("team-a"),
+		Publish:      aws.Bool(true),
+	})
+	req.ApplyOptions(architectures(types.FunctionConfig{Architecture: types.ArchitectureArm64}))
+	assert.NoError(req.Build())
+
+	body, _ := ioutil.ReadAll(req.Body)
+	document := make(map[string]interface{})
+	assert.NoError(json.Unmarshal(body, &document))
+	assert.Equal(true, document["Publish"])
+	assert.Equal([]interface{}{"arm64"}, document["Architectures"])
+}
@@ -0,0 +1,59 @@
+package awsinfra
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/goadapp/goad/goad/types"
+)
+
+// assumeRoleDuration is requested for assumed roles so a token of an MFA
+// device is not asked for again during a test
+const assumeRoleDuration = time.Hour
+
+// stsRegion is used to assume roles if the profile sets no region
+const stsRegion = "us-east-1"
+
+// NewSession returns the session all AWS clients of goad share. It uses the
+// credentials of the profile, or of the role assumed with them, and replaces
+// the endpoints of AWS if configured. Clients set the region themselves.
+func NewSession(config types.AWSConfig) (*session.Session, error) {
+	awsConfig := aws.NewConfig()
+	if config.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(config.Endpoint)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:                  *awsConfig,
+		Profile:                 config.Profile,
+		SharedConfigState:       session.SharedConfigEnable,
+		AssumeRoleTokenProvider: stscreds.StdinTokenProvider,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if config.AssumeRoleARN == "" {
+		return sess, nil
+	}
+	stsSession := sess
+	if aws.StringValue(sess.Config.Region) == "" {
+		stsSession = sess.Copy(aws.NewConfig().WithRegion(stsRegion))
+	}
+	credentials := stscreds.NewCredentials(stsSession, config.AssumeRoleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.Duration = assumeRoleDuration
+		if config.ExternalID != "" {
+			p.ExternalID = aws.String(config.ExternalID)
+		}
+		if config.MFASerial != "" {
+			p.SerialNumber = aws.String(config.MFASerial)
+			p.TokenProvider = stscreds.StdinTokenProvider
+		}
+	})
+	// retrieve the credentials now, before the token can no longer be read
+	// from the terminal
+	if _, err := credentials.Get(); err != nil {
+		return nil, err
+	}
+	return sess.Copy(aws.NewConfig().WithCredentials(credentials)), nil
+}
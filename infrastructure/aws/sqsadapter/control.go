@@ -0,0 +1,55 @@
+package sqsadapter
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// stopMessage is sent to the control queue to stop all runners
+const stopMessage = "stop"
+
+// Control is used to stop the runners of a test through a dedicated queue.
+// Runners never receive from the queue but only look at the number of
+// messages, so a single message reaches every runner. A deleted queue stops
+// the runners as well.
+type Control struct {
+	Client   *sqs.SQS
+	QueueURL string
+}
+
+// NewControl returns a new control object for the given queue
+func NewControl(sess client.ConfigProvider, awsConfig *aws.Config, queueURL string) *Control {
+	return &Control{sqs.New(sess, awsConfig), queueURL}
+}
+
+// Stop signals all runners to stop
+func (control *Control) Stop() error {
+	_, err := control.Client.SendMessage(&sqs.SendMessageInput{
+		MessageBody: aws.String(stopMessage),
+		QueueUrl:    aws.String(control.QueueURL),
+	})
+	return err
+}
+
+// StopRequested reports whether the runners should stop, other errors than a
+// missing queue are ignored so a throttled request does not end the test
+func (control *Control) StopRequested() bool {
+	resp, err := control.Client.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(control.QueueURL),
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameApproximateNumberOfMessages)},
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == sqs.ErrCodeQueueDoesNotExist {
+			return true
+		}
+		fmt.Println(err.Error())
+		return false
+	}
+	count, _ := strconv.Atoi(aws.StringValue(resp.Attributes[sqs.QueueAttributeNameApproximateNumberOfMessages]))
+	return count > 0
+}
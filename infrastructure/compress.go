@@ -92,3 +92,56 @@ func Unzip(archive []byte, target string) error {
 
 	return nil
 }
+
+// AddFileToZip returns a copy of the zip archive with an additional file,
+// an existing file of the same name is replaced.
+func AddFileToZip(archive []byte, name string, content []byte) ([]byte, error) {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for _, file := range reader.File {
+		if file.Name == name {
+			continue
+		}
+		if err := copyZipEntry(writer, file); err != nil {
+			return nil, err
+		}
+	}
+	header := &zip.FileHeader{
+		Name:   name,
+		Method: zip.Deflate,
+	}
+	header.SetMode(0644)
+	entry, err := writer.CreateHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := entry.Write(content); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func copyZipEntry(writer *zip.Writer, file *zip.File) error {
+	header := file.FileHeader
+	entry, err := writer.CreateHeader(&header)
+	if err != nil {
+		return err
+	}
+	if file.FileInfo().IsDir() {
+		return nil
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(entry, reader)
+	return err
+}
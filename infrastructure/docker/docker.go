@@ -19,14 +19,17 @@ import (
 	goadtypes "github.com/goadapp/goad/goad/types"
 	"github.com/goadapp/goad/infrastructure"
 	"github.com/goadapp/goad/result"
+	"github.com/goadapp/goad/transport"
 	"github.com/spf13/afero"
-	"github.com/streadway/amqp"
 	try "gopkg.in/matryer/try.v1"
 )
 
 const (
 	rabbitPort    = "5672"
 	rabbitRetries = 30
+	// lambdaImage emulates the custom Lambda runtime the runner is deployed
+	// with on AWS
+	lambdaImage = "lambci/lambda:provided.al2"
 )
 
 type dockerInfrastructure struct {
@@ -55,8 +58,8 @@ func New(config *goadtypes.TestConfig) infrastructure.Infrastructure {
 	return infra
 }
 
-func (i *dockerInfrastructure) Run(args infrastructure.InvokeArgs) {
-	i.runAsDockerContainer(args)
+func (i *dockerInfrastructure) Run(config api.RunnerConfig) {
+	i.runAsDockerContainer(config)
 }
 
 func (i *dockerInfrastructure) GetSettings() *goadtypes.TestConfig {
@@ -138,7 +141,7 @@ func (i *dockerInfrastructure) Setup() (func(), error) {
 }
 
 func DockerPullLambdaImage() {
-	DockerPullImage("lambci/lambda")
+	DockerPullImage(lambdaImage)
 }
 
 func DockerPullRabbitMQImage() {
@@ -176,11 +179,10 @@ func (i *dockerInfrastructure) getRabbitContainerIP() string {
 	return ip
 }
 
-func (i *dockerInfrastructure) runAsDockerContainer(args interface{}) {
+func (i *dockerInfrastructure) runAsDockerContainer(config api.RunnerConfig) {
 	ctx := context.Background()
 	cli, err := client.NewEnvClient()
 	handleErr(err)
-	rabbitmqURL := fmt.Sprintf("RABBITMQ=%s", i.GetQueueURL())
 
 	var runnerPath string
 	if i.config.RunnerPath == "" {
@@ -188,19 +190,20 @@ func (i *dockerInfrastructure) runAsDockerContainer(args interface{}) {
 	} else {
 		runnerPath = os.ExpandEnv(fmt.Sprintf("${PWD}/%s", i.config.RunnerPath))
 	}
+	fileBinds, err := infrastructure.RunnerFileBinds(i.config, "/var/task")
+	handleErr(err)
 	// Create container to execute lambda
 	resp, err := cli.ContainerCreate(ctx, &container.Config{
-		Image: "lambci/lambda",
-		Cmd:   append([]string{"index.handler"}, toJSONString(args)),
+		Image: lambdaImage,
+		Cmd:   []string{"bootstrap", toJSONString(config)},
 		Volumes: map[string]struct{}{
 			"/var/task": struct{}{},
 		},
-		Env: []string{rabbitmqURL},
 	}, &container.HostConfig{
 		AutoRemove: true,
-		Binds: []string{
+		Binds: append([]string{
 			fmt.Sprintf("%s:/var/task:ro", runnerPath),
-		},
+		}, fileBinds...),
 	}, &network.NetworkingConfig{
 		EndpointsConfig: map[string]*network.EndpointSettings{
 			"goad-bridge": &network.EndpointSettings{},
@@ -223,54 +226,18 @@ func (i *dockerInfrastructure) GetQueueURL() string {
 	return fmt.Sprintf("amqp://guest:guest@%s:%s/", i.RabbitMQContainerIP, rabbitPort)
 }
 
+// GetControlURL returns no control queue, the teardown stops all runner
+// containers directly
+func (i *dockerInfrastructure) GetControlURL() string {
+	return ""
+}
+
 func (i *dockerInfrastructure) Receive(results chan *result.LambdaResults) {
-	defer close(results)
 	fmt.Println("RECEIVING DOCKER")
-	data := result.SetupRegionsAggData(i.config.Lambdas)
-
-	// log.Printf("trying to connecto to: %s", queueURL)
-	conn, err := amqp.Dial(i.GetQueueURL())
+	receiver, err := transport.NewReceiver(i.GetQueueURL())
 	failOnError(err, "Failed to connect to RabbitMQ")
-	defer conn.Close()
-
-	ch, err := conn.Channel()
-	failOnError(err, "Failed to open a channel")
-	defer ch.Close()
-
-	q, err := ch.QueueDeclare(
-		"goad", // name
-		false,  // durable
-		false,  // delete when unused
-		false,  // exclusive
-		false,  // no-wait
-		nil,    // arguments
-	)
-	failOnError(err, "Failed to declare a queue")
-
-	msgs, err := ch.Consume(
-		q.Name, // queue
-		"cli",  // consumer
-		true,   // auto-ack
-		false,  // exclusive
-		false,  // no-local
-		false,  // no-wait
-		nil,    // args
-	)
-	failOnError(err, "Failed to register a consumer")
-	// timeoutStart := time.Now()
-	for {
-		select {
-		case msg := <-msgs:
-			lambdaResult := &api.RunnerResult{}
-			json.Unmarshal(msg.Body, lambdaResult)
-			lambdaAggregate := &data.Lambdas[lambdaResult.RunnerID]
-			result.AddResult(lambdaAggregate, lambdaResult)
-			results <- data
-		}
-		if data.AllLambdasFinished() {
-			break
-		}
-	}
+	defer receiver.Close()
+	infrastructure.Collect(receiver, i.config, nil, nil, results)
 }
 
 func (i *dockerInfrastructure) Teardown() {
@@ -0,0 +1,59 @@
+package infrastructure
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/goadapp/goad/goad/types"
+)
+
+const (
+	// RequestsFileName is the name of the requests file in the working
+	// directory of the runner
+	RequestsFileName = "requests.jsonl"
+	// DataFileName is the name of the CSV data file for request templates in
+	// the working directory of the runner
+	DataFileName = "data.csv"
+)
+
+// RunnerFiles returns the local files that are shipped alongside the runner,
+// keyed by their name in the working directory of the runner.
+func RunnerFiles(config *types.TestConfig) map[string]string {
+	files := make(map[string]string)
+	if config.RequestsFile != "" {
+		files[RequestsFileName] = config.RequestsFile
+	}
+	if config.DataFile != "" {
+		files[DataFileName] = config.DataFile
+	}
+	return files
+}
+
+// AddRunnerFiles adds the files returned by RunnerFiles to the runner archive
+func AddRunnerFiles(archive []byte, config *types.TestConfig) ([]byte, error) {
+	for name, path := range RunnerFiles(config) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		archive, err = AddFileToZip(archive, name, content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return archive, nil
+}
+
+// RunnerFileBinds returns docker volume binds that mount the files returned
+// by RunnerFiles into the task directory
+func RunnerFileBinds(config *types.TestConfig, taskDir string) ([]string, error) {
+	binds := make([]string, 0)
+	for name, path := range RunnerFiles(config) {
+		absolutePath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		binds = append(binds, absolutePath+":"+filepath.Join(taskDir, name)+":ro")
+	}
+	return binds, nil
+}
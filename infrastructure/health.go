@@ -0,0 +1,94 @@
+package infrastructure
+
+import (
+	"sync"
+	"time"
+
+	"github.com/goadapp/goad/goad/types"
+	"github.com/goadapp/goad/result"
+	"github.com/goadapp/goad/transport"
+)
+
+// HeartbeatTimeout is how long a runner may not report before it is
+// considered missing. Runners report on every reporting interval, the first
+// report may be delayed by the start of the function.
+const HeartbeatTimeout = 30 * time.Second
+
+// Failures collects the runners that failed outside of the load test, eg.
+// because they could not be invoked or crashed. It is safe for concurrent
+// use, a nil Failures holds no failures.
+type Failures struct {
+	mu      sync.Mutex
+	pending []failure
+}
+
+type failure struct {
+	runnerID int
+	region   string
+	reason   string
+	log      string
+}
+
+// Add records the failure of a runner, log holds the end of its log if
+// available
+func (f *Failures) Add(runnerID int, region, reason, log string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pending = append(f.pending, failure{runnerID, region, reason, log})
+}
+
+// apply marks the runners that failed since the last call in data and
+// reports whether there were any
+func (f *Failures) apply(data *result.LambdaResults) bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	pending := f.pending
+	f.pending = nil
+	f.mu.Unlock()
+	for _, failure := range pending {
+		if failure.runnerID < 0 || failure.runnerID >= len(data.Lambdas) {
+			continue
+		}
+		result.AddFailure(&data.Lambdas[failure.runnerID], failure.region, failure.reason, failure.log)
+	}
+	return len(pending) > 0
+}
+
+// Collect passes the results of the receiver on until every runner finished,
+// failed or went missing, or stop returns true. The results are passed on
+// after every batch and at least once per receive timeout, so the health of
+// the runners stays up to date while none report. Every pass is a snapshot,
+// receivers may keep it while later results are added.
+func Collect(receiver transport.Receiver, config *types.TestConfig, failures *Failures, stop func() bool, results chan *result.LambdaResults) {
+	data := result.SetupRegionsAggData(config.Lambdas)
+	data.SeriesInterval = seriesInterval(config)
+	for stop == nil || !stop() {
+		for _, lambdaResult := range receiver.Receive() {
+			if lambdaResult.RunnerID < 0 || lambdaResult.RunnerID >= config.Lambdas {
+				continue
+			}
+			data.AddRunnerResult(lambdaResult, time.Now())
+		}
+		failures.apply(data)
+		results <- data.Snapshot()
+		if data.Health(time.Now(), HeartbeatTimeout).Done() {
+			return
+		}
+	}
+}
+
+// seriesInterval returns the length of the intervals of the time series. It
+// is at least the reporting frequency, shorter intervals would alternate
+// between empty ones and ones with a report of every runner.
+func seriesInterval(config *types.TestConfig) time.Duration {
+	interval := time.Duration(config.SeriesInterval) * time.Second
+	if interval <= 0 {
+		interval = result.DefaultSeriesInterval
+	}
+	if frequency := reportingFrequency(config.Lambdas); interval < frequency {
+		interval = frequency
+	}
+	return interval
+}
@@ -0,0 +1,114 @@
+package infrastructure
+
+import (
+	"testing"
+
+	"github.com/goadapp/goad/api"
+	"github.com/goadapp/goad/goad/types"
+	"github.com/goadapp/goad/result"
+	"github.com/stretchr/testify/assert"
+)
+
+// queueReceiver returns one batch of results per receive
+type queueReceiver struct {
+	batches [][]*api.RunnerResult
+}
+
+func (r *queueReceiver) Receive() []*api.RunnerResult {
+	if len(r.batches) == 0 {
+		return nil
+	}
+	batch := r.batches[0]
+	r.batches = r.batches[1:]
+	return batch
+}
+
+func (r *queueReceiver) Close() error {
+	return nil
+}
+
+func TestCollectStopsOnceRunnersFinishedOrFailed(t *testing.T) {
+	assert := assert.New(t)
+	receiver := &queueReceiver{batches: [][]*api.RunnerResult{
+		{{RunnerID: 0, Region: "us-east-1", RequestCount: 10}},
+		{{RunnerID: 0, Region: "us-east-1", RequestCount: 5, Finished: true}},
+	}}
+	failures := &Failures{}
+	failures.Add(1, "eu-west-1", "invocation failed: throttled", "START\nEND")
+	results := make(chan *result.LambdaResults, 10)
+
+	Collect(receiver, &types.TestConfig{Lambdas: 2}, failures, nil, results)
+	close(results)
+
+	var last *result.LambdaResults
+	for data := range results {
+		last = data
+	}
+	assert.Equal(15, last.Lambdas[0].TotalReqs)
+	assert.True(last.Lambdas[1].Failed)
+	assert.Equal("invocation failed: throttled", last.Lambdas[1].FatalError)
+	assert.Equal("START\nEND", last.Lambdas[1].FailureLog)
+	health := last.Health(last.Started, HeartbeatTimeout)
+	assert.Equal(result.RunnerHealth{Expected: 2, Finished: 1, Failed: 1}, health)
+}
+
+func TestRunnerWithoutReportIsMissing(t *testing.T) {
+	assert := assert.New(t)
+	data := result.SetupRegionsAggData(1)
+	assert.False(data.RunnerMissing(data.Lambdas[0], data.Started, HeartbeatTimeout))
+	assert.True(data.RunnerMissing(data.Lambdas[0], data.Started.Add(HeartbeatTimeout+1), HeartbeatTimeout))
+	assert.Equal(1, data.Health(data.Started.Add(HeartbeatTimeout+1), HeartbeatTimeout).Missing)
+}
+
+func TestFailureOfForkedRunnerIsIgnored(t *testing.T) {
+	assert := assert.New(t)
+	receiver := &queueReceiver{batches: [][]*api.RunnerResult{
+		{{RunnerID: 0, Region: "us-east-1", RequestCount: 10, Forked: true}},
+		{{RunnerID: 0, Region: "us-east-1", RequestCount: 5, Finished: true}},
+	}}
+	failures := &Failures{}
+	failures.Add(0, "us-east-1", "runner failed: Task timed out", "")
+	results := make(chan *result.LambdaResults, 10)
+
+	Collect(receiver, &types.TestConfig{Lambdas: 1}, failures, nil, results)
+	close(results)
+
+	var last *result.LambdaResults
+	for data := range results {
+		last = data
+	}
+	assert.Equal(15, last.Lambdas[0].TotalReqs)
+	assert.False(last.Lambdas[0].Failed)
+	assert.Empty(last.Lambdas[0].FatalError)
+	assert.Equal(result.RunnerHealth{Expected: 1, Finished: 1}, last.Health(last.Started, HeartbeatTimeout))
+}
+
+func TestLaterResultClearsFailure(t *testing.T) {
+	assert := assert.New(t)
+	data := result.SetupRegionsAggData(1)
+	result.AddFailure(&data.Lambdas[0], "us-east-1", "runner failed: Task timed out", "END")
+	data.AddRunnerResult(&api.RunnerResult{RunnerID: 0, Region: "us-east-1", RequestCount: 5}, data.Started)
+	assert.False(data.Lambdas[0].Failed)
+	assert.False(data.Lambdas[0].Finished)
+	assert.Empty(data.Lambdas[0].FatalError)
+	assert.Empty(data.Lambdas[0].FailureLog)
+}
+
+func TestCollectPassesSnapshots(t *testing.T) {
+	assert := assert.New(t)
+	receiver := &queueReceiver{batches: [][]*api.RunnerResult{
+		{{RunnerID: 0, Region: "us-east-1", RequestCount: 10, Statuses: map[string]int{"200": 10}}},
+		{{RunnerID: 0, Region: "us-east-1", RequestCount: 5, Statuses: map[string]int{"200": 5}, Finished: true}},
+	}}
+	results := make(chan *result.LambdaResults, 10)
+
+	Collect(receiver, &types.TestConfig{Lambdas: 1}, nil, nil, results)
+	close(results)
+
+	first := <-results
+	assert.Equal(10, first.Lambdas[0].TotalReqs)
+	assert.Equal(map[string]int{"200": 10}, first.Lambdas[0].Statuses)
+	assert.Equal(int64(0), first.Lambdas[0].TimeForReqHistogram.Count())
+	last := <-results
+	assert.Equal(15, last.Lambdas[0].TotalReqs)
+}
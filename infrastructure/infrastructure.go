@@ -3,79 +3,134 @@ package infrastructure
 import (
 	"fmt"
 	"math"
-	"strconv"
 	"time"
 
+	"github.com/goadapp/goad/api"
 	"github.com/goadapp/goad/goad/types"
 	"github.com/goadapp/goad/result"
 )
 
 const DefaultRunnerAsset = "data/lambda.zip"
 
+// Arm64RunnerAsset holds the runner built for functions on arm64
+const Arm64RunnerAsset = "data/lambda-arm64.zip"
+
+// RunnerAsset returns the name of the runner archive for the architecture
+func RunnerAsset(architecture string) string {
+	if architecture == types.ArchitectureArm64 {
+		return Arm64RunnerAsset
+	}
+	return DefaultRunnerAsset
+}
+
 type Infrastructure interface {
 	Setup() (teardown func(), err error)
-	Run(args InvokeArgs)
+	Run(config api.RunnerConfig)
+	// GetQueueURL returns the URL of the transport for the results
 	GetQueueURL() string
+	GetControlURL() string
+	// Receive sends the results until all runners finished, the channel is
+	// closed by the caller
 	Receive(chan *result.LambdaResults)
 	GetSettings() *types.TestConfig
 }
 
-type InvokeArgs struct {
-	File string   `json:"file"`
-	Args []string `json:"args"`
-}
-
+// InvokeLambdas starts all runners of the test, they share the runner
+// configuration except for their part of the load
 func InvokeLambdas(inf Infrastructure) {
 	t := inf.GetSettings()
-	currentID := 0
+	base := runnerConfig(t, inf)
 	for i := 0; i < t.Lambdas; i++ {
-		region := t.Regions[i%len(t.Regions)]
 		requests, requestsRemainder := divide(t.Requests, t.Lambdas)
 		concurrency, _ := divide(t.Concurrency, t.Lambdas)
-		execTimeout := t.Timelimit
-
 		if requestsRemainder > 0 && i == t.Lambdas-1 {
 			requests += requestsRemainder
 		}
 
-		args := []string{
-			fmt.Sprintf("--concurrency=%s", strconv.Itoa(int(concurrency))),
-			fmt.Sprintf("--requests=%s", strconv.Itoa(int(requests))),
-			fmt.Sprintf("--execution-time=%s", strconv.Itoa(int(execTimeout))),
-			fmt.Sprintf("--sqsurl=%s", inf.GetQueueURL()),
-			fmt.Sprintf("--queue-region=%s", t.Regions[0]),
-			fmt.Sprintf("--client-timeout=%s", time.Duration(t.Timeout)*time.Second),
-			fmt.Sprintf("--frequency=%s", reportingFrequency(t.Lambdas).String()),
-			fmt.Sprintf("--aws-region=%s", region),
-			fmt.Sprintf("--method=%s", t.Method),
-			fmt.Sprintf("--runner-id=%d", currentID),
-			fmt.Sprintf("--body=%s", t.Body),
+		config := base
+		config.RunnerID = i
+		config.Region = t.Regions[i%len(t.Regions)]
+		config.Requests = requests
+		config.Concurrency = concurrency
+		if t.Rate > 0 {
+			config.Rate = t.Rate / float64(t.Lambdas)
 		}
-		currentID++
-		for _, v := range t.Headers {
-			args = append(args, fmt.Sprintf("--header=%s", v))
+		config.Stages = nil
+		for _, stage := range t.Stages {
+			stage.Target = divideStageTarget(stage.Target, t.Lambdas, i)
+			config.Stages = append(config.Stages, stage.String())
 		}
-		args = append(args, fmt.Sprintf("%s", t.URL))
 
-		invokeargs := InvokeArgs{
-			File: "./goad-lambda",
-			Args: args,
-		}
+		go inf.Run(config)
+	}
+}
 
-		go inf.Run(invokeargs)
+// runnerConfig returns the configuration shared by all runners of the test
+func runnerConfig(t *types.TestConfig, inf Infrastructure) api.RunnerConfig {
+	config := api.NewRunnerConfig()
+	config.URL = t.URL
+	config.Method = t.Method
+	config.Body = t.Body
+	config.Headers = t.Headers
+	config.Assertions = t.Assertions
+	config.RunnerCount = t.Lambdas
+	config.QueueRegion = t.Regions[0]
+	config.ResultsURL = inf.GetQueueURL()
+	config.ControlURL = inf.GetControlURL()
+	config.FunctionName = t.Function.FunctionName()
+	config.FunctionTimeout = t.Function.Timeout
+	config.AWSEndpoint = t.AWS.Endpoint
+	config.ExecutionTime = int(t.Timelimit)
+	config.ClientTimeout = time.Duration(t.Timeout) * time.Second
+	config.ReportingFrequency = reportingFrequency(t.Lambdas)
+	config.Scenario = t.Scenario
+	if t.RequestsFile != "" {
+		config.RequestsFile = RequestsFileName
+	}
+	if t.DataFile != "" {
+		config.DataFile = DataFileName
+		config.Feeder = t.Feeder
 	}
+	config.AbortErrorRatio = t.AbortPolicy.ErrorRatio
+	config.AbortMinSamples = t.AbortPolicy.MinSamples
+	config.AbortConsecutiveTimeouts = t.AbortPolicy.ConsecutiveTimeouts
+	config.AbortStatuses = t.AbortPolicy.Statuses
+	return config
 }
 
-func Aggregate(i Infrastructure) chan *result.LambdaResults {
+// Aggregate collects the results of all runners. A failure while receiving
+// ends the test instead of the process so the infrastructure is torn down,
+// it is sent on the error channel once the results are closed.
+func Aggregate(i Infrastructure) (<-chan *result.LambdaResults, <-chan error) {
 	results := make(chan *result.LambdaResults)
-	go i.Receive(results)
-	return results
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(results)
+		defer func() {
+			if r := recover(); r != nil {
+				errs <- fmt.Errorf("receiving results failed: %v", r)
+			}
+		}()
+		i.Receive(results)
+	}()
+	return results, errs
 }
 
 func divide(dividend int, divisor int) (quotient, remainder int) {
 	return dividend / divisor, dividend % divisor
 }
 
+// divideStageTarget splits the target concurrency of a stage between all
+// lambdas, the remainder is spread over the first lambdas
+func divideStageTarget(target int, lambdas int, index int) int {
+	quotient, remainder := divide(target, lambdas)
+	if index < remainder {
+		quotient++
+	}
+	return quotient
+}
+
 func reportingFrequency(numberOfLambdas int) time.Duration {
 	return time.Duration((math.Log2(float64(numberOfLambdas)) + 1)) * time.Second
 }
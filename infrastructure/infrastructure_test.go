@@ -0,0 +1,39 @@
+package infrastructure
+
+import (
+	"testing"
+
+	"github.com/goadapp/goad/api"
+	"github.com/goadapp/goad/goad/types"
+	"github.com/goadapp/goad/result"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingInfrastructure sends one result and fails to receive more
+type failingInfrastructure struct{}
+
+func (i *failingInfrastructure) Setup() (func(), error)      { return func() {}, nil }
+func (i *failingInfrastructure) Run(config api.RunnerConfig) {}
+func (i *failingInfrastructure) GetQueueURL() string         { return "" }
+func (i *failingInfrastructure) GetControlURL() string       { return "" }
+func (i *failingInfrastructure) GetSettings() *types.TestConfig {
+	return &types.TestConfig{}
+}
+
+func (i *failingInfrastructure) Receive(results chan *result.LambdaResults) {
+	results <- result.SetupRegionsAggData(1)
+	panic("connection refused")
+}
+
+func TestAggregateClosesResultsAndReportsFailure(t *testing.T) {
+	assert := assert.New(t)
+	results, errs := Aggregate(&failingInfrastructure{})
+
+	var received int
+	for range results {
+		received++
+	}
+	assert.Equal(1, received)
+	assert.EqualError(<-errs, "receiving results failed: connection refused")
+	assert.NoError(<-errs, "the error is sent once")
+}
@@ -0,0 +1,93 @@
+package localinfra
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/goadapp/goad/api"
+	"github.com/goadapp/goad/goad/types"
+	"github.com/goadapp/goad/infrastructure"
+	"github.com/goadapp/goad/result"
+	"github.com/goadapp/goad/runner"
+	"github.com/goadapp/goad/transport"
+	uuid "github.com/satori/go.uuid"
+)
+
+// localInfrastructure runs the runners as goroutines of the current process,
+// results are passed through an in-memory queue
+type localInfrastructure struct {
+	config   *types.TestConfig
+	queueURL string
+	receiver transport.Receiver
+	failures infrastructure.Failures
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns the local infrastructure for the given test
+func New(config *types.TestConfig) infrastructure.Infrastructure {
+	return &localInfrastructure{
+		config:   config,
+		queueURL: fmt.Sprintf("mem://goad-%s", uuid.NewV4()),
+		stop:     make(chan struct{}),
+	}
+}
+
+func (i *localInfrastructure) Setup() (func(), error) {
+	receiver, err := transport.NewReceiver(i.queueURL)
+	if err != nil {
+		return nil, err
+	}
+	i.receiver = receiver
+	return i.teardown, nil
+}
+
+func (i *localInfrastructure) teardown() {
+	i.stopOnce.Do(func() {
+		close(i.stop)
+		i.receiver.Close()
+	})
+}
+
+func (i *localInfrastructure) Run(config api.RunnerConfig) {
+	// use the files of the test instead of the copies shipped alongside the
+	// lambda
+	if config.RequestsFile != "" {
+		config.RequestsFile = i.config.RequestsFile
+	}
+	if config.DataFile != "" {
+		config.DataFile = i.config.DataFile
+	}
+	err := runner.RunLocal(config, i)
+	if err != nil {
+		i.failures.Add(config.RunnerID, config.Region, err.Error(), "")
+	}
+}
+
+// StopRequested reports whether the test was torn down
+func (i *localInfrastructure) StopRequested() bool {
+	select {
+	case <-i.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (i *localInfrastructure) GetQueueURL() string {
+	return i.queueURL
+}
+
+// GetControlURL returns no control queue, local runners are stopped by the
+// teardown directly
+func (i *localInfrastructure) GetControlURL() string {
+	return ""
+}
+
+func (i *localInfrastructure) GetSettings() *types.TestConfig {
+	return i.config
+}
+
+func (i *localInfrastructure) Receive(results chan *result.LambdaResults) {
+	infrastructure.Collect(i.receiver, i.config, &i.failures, i.StopRequested, results)
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/goadapp/goad/runner"
+)
+
+// main serves the invocations of the Lambda function, or runs a single load
+// test given by a JSON runner configuration outside of Lambda
+func main() {
+	if runtimeAPI := os.Getenv(runner.RuntimeAPIEnv); runtimeAPI != "" {
+		runner.Serve(runtimeAPI)
+		return
+	}
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: goad-lambda <runner configuration as JSON>")
+		os.Exit(2)
+	}
+	runner.Run([]byte(os.Args[1]))
+}
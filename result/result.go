@@ -3,9 +3,12 @@ package result
 import (
 	"math"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/goadapp/goad/api"
+	"github.com/goadapp/goad/goad/histogram"
 	"github.com/goadapp/goad/goad/util"
 )
 
@@ -14,6 +17,8 @@ type AggData struct {
 	TotalReqs            int
 	TotalTimedOut        int
 	TotalConnectionError int
+	TotalDropped         int
+	TotalLate            int
 	AveTimeToFirst       int64
 	TotBytesRead         int
 	Statuses             map[string]int
@@ -26,11 +31,90 @@ type AggData struct {
 	Region               string
 	FatalError           string
 	Finished             bool
+
+	TimeToFirstHistogram   *histogram.Histogram
+	TimeForReqHistogram    *histogram.Histogram
+	TimeToFirstPercentiles histogram.Percentiles
+	TimeForReqPercentiles  histogram.Percentiles
+
+	Breakdown map[string]*api.RequestStats `json:",omitempty"`
+	Phases    api.Phases
+
+	FailedAssertions             map[string]int `json:",omitempty"`
+	TotalFailedAssertionRequests int
+
+	// LastSeen is when the last result of the runner arrived, runners report
+	// on every reporting interval
+	LastSeen time.Time
+	// Failed runners stopped without finishing the test, eg. because they
+	// could not be invoked or crashed. FatalError holds the reason and
+	// FailureLog the end of their log if available.
+	Failed     bool
+	FailureLog string `json:",omitempty"`
+	// Forked runners continued the test in a fork before their function
+	// timeout, the fork reports with the same runner id
+	Forked bool `json:",omitempty"`
+
+	// Series holds the results per interval of the test
+	Series []Interval `json:",omitempty"`
 }
 
 // LambdaResults type
 type LambdaResults struct {
 	Lambdas []AggData
+	// Started is when the results of the runners were first awaited
+	Started time.Time
+	// SeriesInterval is the length of the intervals of the time series
+	SeriesInterval time.Duration
+}
+
+// RunnerHealth counts the runners of a test by their state
+type RunnerHealth struct {
+	Expected int
+	// Running runners reported within the heartbeat timeout, or were just
+	// started
+	Running  int
+	Finished int
+	Failed   int
+	// Missing runners did not report within the heartbeat timeout
+	Missing int
+}
+
+// Done reports whether no runner is expected to report anymore
+func (h RunnerHealth) Done() bool {
+	return h.Running == 0
+}
+
+// RunnerMissing reports whether the runner did not report within the
+// timeout, the start of the test counts as report of runners that never
+// reported
+func (r *LambdaResults) RunnerMissing(runner AggData, now time.Time, timeout time.Duration) bool {
+	if runner.Finished || runner.Failed {
+		return false
+	}
+	lastSeen := runner.LastSeen
+	if lastSeen.IsZero() {
+		lastSeen = r.Started
+	}
+	return now.Sub(lastSeen) > timeout
+}
+
+// Health returns the state of the runners at now
+func (r *LambdaResults) Health(now time.Time, timeout time.Duration) RunnerHealth {
+	health := RunnerHealth{Expected: len(r.Lambdas)}
+	for _, lambda := range r.Lambdas {
+		switch {
+		case lambda.Failed:
+			health.Failed++
+		case lambda.Finished:
+			health.Finished++
+		case r.RunnerMissing(lambda, now, timeout):
+			health.Missing++
+		default:
+			health.Running++
+		}
+	}
+	return health
 }
 
 // Regions the LambdaResults were collected from
@@ -73,29 +157,72 @@ func (r *LambdaResults) ResultsForRegion(region string) []AggData {
 
 func SetupRegionsAggData(lambdaCount int) *LambdaResults {
 	lambdaResults := &LambdaResults{
-		Lambdas: make([]AggData, lambdaCount),
+		Lambdas:        make([]AggData, lambdaCount),
+		Started:        time.Now(),
+		SeriesInterval: DefaultSeriesInterval,
 	}
 	for i := 0; i < lambdaCount; i++ {
 		lambdaResults.Lambdas[i].Statuses = make(map[string]int)
+		lambdaResults.Lambdas[i].TimeToFirstHistogram = histogram.New()
+		lambdaResults.Lambdas[i].TimeForReqHistogram = histogram.New()
 	}
 	return lambdaResults
 }
 
+// Snapshot returns a deep copy of the results, it stays unchanged while more
+// results are added
+func (r *LambdaResults) Snapshot() *LambdaResults {
+	snapshot := *r
+	snapshot.Lambdas = make([]AggData, len(r.Lambdas))
+	for i, lambda := range r.Lambdas {
+		snapshot.Lambdas[i] = copyAggData(lambda)
+	}
+	return &snapshot
+}
+
+func copyAggData(data AggData) AggData {
+	data.Statuses = addCounts(make(map[string]int), data.Statuses)
+	data.FailedAssertions = addCounts(nil, data.FailedAssertions)
+	data.TimeToFirstHistogram = copyHistogram(data.TimeToFirstHistogram)
+	data.TimeForReqHistogram = copyHistogram(data.TimeForReqHistogram)
+	if data.Breakdown != nil {
+		breakdown := make(map[string]*api.RequestStats)
+		api.MergeBreakdown(breakdown, data.Breakdown)
+		data.Breakdown = breakdown
+	}
+	data.Series = mergeSeries(nil, data.Series)
+	return data
+}
+
+func copyHistogram(h *histogram.Histogram) *histogram.Histogram {
+	copied := histogram.New()
+	copied.Merge(h)
+	return copied
+}
+
 func sumAggData(dataArray []AggData) AggData {
 	sum := AggData{
-		Fastest:  math.MaxInt64,
-		Statuses: make(map[string]int),
-		Finished: true,
+		Fastest:              math.MaxInt64,
+		Statuses:             make(map[string]int),
+		Finished:             true,
+		TimeToFirstHistogram: histogram.New(),
+		TimeForReqHistogram:  histogram.New(),
 	}
+	var countOk int64
 	for _, lambda := range dataArray {
+		lambdaCountOk := int64(lambda.TotalReqs - lambda.TotalTimedOut - lambda.TotalConnectionError)
+		if countOk+lambdaCountOk > 0 {
+			sum.AveTimeForReq = addToTotalAverage(sum.AveTimeForReq, countOk, lambda.AveTimeForReq, lambdaCountOk)
+			sum.AveTimeToFirst = addToTotalAverage(sum.AveTimeToFirst, countOk, lambda.AveTimeToFirst, lambdaCountOk)
+		}
+		countOk += lambdaCountOk
 		sum.AveKBytesPerSec += lambda.AveKBytesPerSec
 		sum.AveReqPerSec += lambda.AveReqPerSec
-		sum.AveTimeForReq += lambda.AveTimeForReq
-		sum.AveTimeToFirst += lambda.AveTimeToFirst
 		if lambda.Fastest < sum.Fastest {
 			sum.Fastest = lambda.Fastest
 		}
-		sum.FatalError += lambda.FatalError
+		sum.FatalError = addFatalError(sum.FatalError, lambda.FatalError)
+		sum.Failed = sum.Failed || lambda.Failed
 		if !lambda.Finished {
 			sum.Finished = false
 		}
@@ -110,13 +237,31 @@ func sumAggData(dataArray []AggData) AggData {
 		sum.TotalConnectionError += lambda.TotalConnectionError
 		sum.TotalReqs += lambda.TotalReqs
 		sum.TotalTimedOut += lambda.TotalTimedOut
+		sum.TotalDropped += lambda.TotalDropped
+		sum.TotalLate += lambda.TotalLate
 		sum.TotBytesRead += lambda.TotBytesRead
+		sum.TimeToFirstHistogram.Merge(lambda.TimeToFirstHistogram)
+		sum.TimeForReqHistogram.Merge(lambda.TimeForReqHistogram)
+		sum.Phases.Merge(lambda.Phases)
+		sum.Series = mergeSeries(sum.Series, lambda.Series)
+		sum.TotalFailedAssertionRequests += lambda.TotalFailedAssertionRequests
+		sum.FailedAssertions = addCounts(sum.FailedAssertions, lambda.FailedAssertions)
+		if len(lambda.Breakdown) > 0 {
+			if sum.Breakdown == nil {
+				sum.Breakdown = make(map[string]*api.RequestStats)
+			}
+			api.MergeBreakdown(sum.Breakdown, lambda.Breakdown)
+		}
 	}
-	sum.AveTimeForReq = sum.AveTimeForReq / int64(len(dataArray))
-	sum.AveTimeToFirst = sum.AveTimeToFirst / int64(len(dataArray))
+	updatePercentiles(&sum)
 	return sum
 }
 
+func updatePercentiles(data *AggData) {
+	data.TimeToFirstPercentiles = data.TimeToFirstHistogram.Percentiles()
+	data.TimeForReqPercentiles = data.TimeForReqHistogram.Percentiles()
+}
+
 func (r *LambdaResults) AllLambdasFinished() bool {
 	for _, lambda := range r.Lambdas {
 		if !lambda.Finished {
@@ -134,6 +279,8 @@ func AddResult(data *AggData, result *api.RunnerResult) {
 	data.TotalReqs += result.RequestCount
 	data.TotalTimedOut += result.TimedOut
 	data.TotalConnectionError += result.ConnectionErrors
+	data.TotalDropped += result.Dropped
+	data.TotalLate += result.Late
 	data.TotBytesRead += result.BytesRead
 	data.TimeDelta += result.TimeDelta
 
@@ -151,17 +298,107 @@ func AddResult(data *AggData, result *api.RunnerResult) {
 	if result.Slowest > data.Slowest {
 		data.Slowest = result.Slowest
 	}
-	if result.Fastest > 0 && (data.Fastest == 0 || result.Fastest < data.Fastest) {
+	// results without requests, eg. heartbeats, keep the initial maximum
+	if result.Fastest > 0 && result.Fastest != math.MaxInt64 && (data.Fastest == 0 || result.Fastest < data.Fastest) {
 		data.Fastest = result.Fastest
 	}
+	if data.TimeToFirstHistogram == nil {
+		data.TimeToFirstHistogram = histogram.New()
+	}
+	if data.TimeForReqHistogram == nil {
+		data.TimeForReqHistogram = histogram.New()
+	}
+	data.TimeToFirstHistogram.Merge(result.TimeToFirstHistogram)
+	data.TimeForReqHistogram.Merge(result.TimeForReqHistogram)
+	updatePercentiles(data)
+	data.Phases.Merge(result.Phases)
+	data.TotalFailedAssertionRequests += result.FailedAssertionRequests
+	data.FailedAssertions = addCounts(data.FailedAssertions, result.FailedAssertions)
+	if len(result.Breakdown) > 0 {
+		if data.Breakdown == nil {
+			data.Breakdown = make(map[string]*api.RequestStats)
+		}
+		api.MergeBreakdown(data.Breakdown, result.Breakdown)
+	}
+
+	if data.Failed {
+		// the runner, or its fork, is still alive, runners report their own
+		// fatal error with every result
+		data.Failed = false
+		data.FailureLog = ""
+		data.FatalError = ""
+	}
+	if result.FatalError != "" {
+		data.FatalError = result.FatalError
+	}
+	if result.Forked {
+		data.Forked = true
+	}
 	data.Finished = result.Finished
 	data.Region = result.Region
+	data.LastSeen = time.Now()
 }
 
-func addToTotalAverage(currentAvg, currentCount, addAvg, addCount int64) int64 {
-	return ((currentAvg * currentCount) + (addAvg * addCount)) / (currentCount + addCount)
+// AddFailure marks a runner as failed, it is not waited for anymore. Runners
+// that forked are not marked, the failure is of the invocation they forked
+// from and the fork continues the test.
+func AddFailure(data *AggData, region, reason, log string) {
+	if data.Forked {
+		return
+	}
+	data.Failed = true
+	data.Finished = true
+	data.FatalError = addFatalError(data.FatalError, reason)
+	data.FailureLog = log
+	if data.Region == "" {
+		data.Region = region
+	}
 }
 
-func addToTotalAverageFloat(currentAvg, currentCount, addAvg, addCount float64) float64 {
+// addFatalError joins the distinct errors of several runners
+func addFatalError(joined, err string) string {
+	if err == "" || strings.Contains(joined, err) {
+		return joined
+	}
+	if joined == "" {
+		return err
+	}
+	return joined + "; " + err
+}
+
+// Errors returns the number of requests that did not succeed: timeouts,
+// connection errors, status codes of 400 and above and requests failing an
+// assertion
+func Errors(data AggData) int {
+	return errorCount(data.TotalReqs, data.Statuses, data.TotalFailedAssertionRequests)
+}
+
+func errorCount(requests int, statuses map[string]int, failedAssertionRequests int) int {
+	var okReqs int
+	for statusStr, value := range statuses {
+		status, _ := strconv.Atoi(statusStr)
+		if status < 400 {
+			okReqs += value
+		}
+	}
+	return requests - okReqs + failedAssertionRequests
+}
+
+// addCounts adds the counters of other to counts, counts is created on demand
+// so results without counters keep a nil map
+func addCounts(counts map[string]int, other map[string]int) map[string]int {
+	if len(other) == 0 {
+		return counts
+	}
+	if counts == nil {
+		counts = make(map[string]int)
+	}
+	for key, value := range other {
+		counts[key] += value
+	}
+	return counts
+}
+
+func addToTotalAverage(currentAvg, currentCount, addAvg, addCount int64) int64 {
 	return ((currentAvg * currentCount) + (addAvg * addCount)) / (currentCount + addCount)
 }
@@ -0,0 +1,95 @@
+package result
+
+import (
+	"time"
+
+	"github.com/goadapp/goad/api"
+	"github.com/goadapp/goad/goad/histogram"
+)
+
+// DefaultSeriesInterval is the length of the intervals of the time series if
+// the test sets none
+const DefaultSeriesInterval = 10 * time.Second
+
+// Interval holds the results that arrived within one interval of the test,
+// the time series of a runner has one per interval since the start of the
+// test
+type Interval struct {
+	// Offset is the start of the interval since the start of the test
+	Offset                time.Duration
+	Length                time.Duration
+	Requests              int
+	Errors                int
+	Statuses              map[string]int
+	TimeForReqHistogram   *histogram.Histogram
+	TimeForReqPercentiles histogram.Percentiles
+}
+
+// ReqPerSec returns the rate of requests within the interval
+func (i Interval) ReqPerSec() float64 {
+	if i.Length <= 0 {
+		return 0
+	}
+	return float64(i.Requests) / i.Length.Seconds()
+}
+
+func newInterval(index int, length time.Duration) Interval {
+	return Interval{
+		Offset:              time.Duration(index) * length,
+		Length:              length,
+		Statuses:            make(map[string]int),
+		TimeForReqHistogram: histogram.New(),
+	}
+}
+
+// AddRunnerResult adds a result that arrived at now to the totals of its
+// runner and to the interval of the time series it arrived in
+func (r *LambdaResults) AddRunnerResult(runnerResult *api.RunnerResult, now time.Time) {
+	data := &r.Lambdas[runnerResult.RunnerID]
+	AddResult(data, runnerResult)
+	length := r.SeriesInterval
+	if length <= 0 {
+		length = DefaultSeriesInterval
+	}
+	data.Series = addToSeries(data.Series, runnerResult, now.Sub(r.Started), length)
+}
+
+// addToSeries adds the result to the interval at offset. Intervals without
+// results are kept so the series of all runners line up.
+func addToSeries(series []Interval, runnerResult *api.RunnerResult, offset, length time.Duration) []Interval {
+	index := int(offset / length)
+	if index < 0 {
+		index = 0
+	}
+	for len(series) <= index {
+		series = append(series, newInterval(len(series), length))
+	}
+	interval := &series[index]
+	interval.Requests += runnerResult.RequestCount
+	interval.Errors += errorCount(runnerResult.RequestCount, runnerResult.Statuses, runnerResult.FailedAssertionRequests)
+	for key, value := range runnerResult.Statuses {
+		interval.Statuses[key] += value
+	}
+	interval.TimeForReqHistogram.Merge(runnerResult.TimeForReqHistogram)
+	interval.TimeForReqPercentiles = interval.TimeForReqHistogram.Percentiles()
+	return series
+}
+
+// mergeSeries adds the intervals of other to the ones of series with the
+// same offset
+func mergeSeries(series []Interval, other []Interval) []Interval {
+	for index, interval := range other {
+		for len(series) <= index {
+			series = append(series, newInterval(len(series), interval.Length))
+		}
+		sum := &series[index]
+		sum.Requests += interval.Requests
+		sum.Errors += interval.Errors
+		for key, value := range interval.Statuses {
+			sum.Statuses[key] += value
+		}
+		sum.TimeForReqHistogram.Merge(interval.TimeForReqHistogram)
+		sum.TimeForReqPercentiles = sum.TimeForReqHistogram.Percentiles()
+	}
+	return series
+}
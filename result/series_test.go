@@ -0,0 +1,45 @@
+package result
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goadapp/goad/api"
+	"github.com/goadapp/goad/goad/histogram"
+	"github.com/stretchr/testify/assert"
+)
+
+func runnerResult(runnerID, requests int, statuses map[string]int) *api.RunnerResult {
+	return &api.RunnerResult{
+		RunnerID:             runnerID,
+		Region:               "us-east-1",
+		RequestCount:         requests,
+		Statuses:             statuses,
+		TimeToFirstHistogram: histogram.New(),
+		TimeForReqHistogram:  histogram.New(),
+	}
+}
+
+func TestResultsAreAddedToIntervalTheyArrivedIn(t *testing.T) {
+	assert := assert.New(t)
+	data := SetupRegionsAggData(2)
+	start := data.Started
+
+	data.AddRunnerResult(runnerResult(0, 10, map[string]int{"200": 10}), start.Add(time.Second))
+	data.AddRunnerResult(runnerResult(1, 20, map[string]int{"200": 15, "503": 5}), start.Add(2*time.Second))
+	data.AddRunnerResult(runnerResult(1, 30, map[string]int{"200": 30}), start.Add(25*time.Second))
+
+	series := data.Lambdas[1].Series
+	assert.Len(series, 3)
+	assert.Equal(5, series[0].Errors)
+	assert.Equal(0, series[1].Requests, "intervals without results are kept")
+	assert.Equal(20*time.Second, series[2].Offset)
+
+	overall := data.SumAllLambdas().Series
+	assert.Len(overall, 3)
+	assert.Equal(30, overall[0].Requests)
+	assert.Equal(map[string]int{"200": 25, "503": 5}, overall[0].Statuses)
+	assert.Equal(3.0, overall[0].ReqPerSec())
+	assert.Equal(30, overall[2].Requests)
+	assert.Equal(60, data.SumAllLambdas().TotalReqs, "totals are kept alongside the series")
+}
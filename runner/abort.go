@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/goadapp/goad/goad/types"
+)
+
+// abortMonitor evaluates the abort policy against the results of the runner
+type abortMonitor struct {
+	policy              types.AbortPolicy
+	requests            int
+	failures            int
+	consecutiveTimeouts int
+}
+
+func newAbortMonitor(policy types.AbortPolicy) *abortMonitor {
+	return &abortMonitor{policy: policy}
+}
+
+// check records the result and returns the reason to abort the test, or an
+// empty string to continue
+func (m *abortMonitor) check(r *requestResult) string {
+	m.requests++
+	if r.Timeout {
+		m.consecutiveTimeouts++
+	} else {
+		m.consecutiveTimeouts = 0
+	}
+	if r.Timeout || r.ConnectionError {
+		m.failures++
+	}
+	p := m.policy
+	if p.ConsecutiveTimeouts > 0 && m.consecutiveTimeouts >= p.ConsecutiveTimeouts {
+		return fmt.Sprintf("%d consecutive requests timed out, aborting", m.consecutiveTimeouts)
+	}
+	if !r.Timeout && !r.ConnectionError {
+		for _, status := range p.Statuses {
+			if r.Status == status {
+				return fmt.Sprintf("Received status %d, aborting", status)
+			}
+		}
+	}
+	if p.ErrorRatio > 0 && m.requests >= p.MinSamples && float64(m.failures) > p.ErrorRatio*float64(m.requests) {
+		return fmt.Sprintf("%d of %d requests failed (over %g%%), aborting", m.failures, m.requests, p.ErrorRatio*100)
+	}
+	return ""
+}
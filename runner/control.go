@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/goadapp/goad/infrastructure/aws/sqsadapter"
+)
+
+// controlPollInterval is the pause between two checks whether the test was
+// stopped
+const controlPollInterval = time.Second
+
+// StopSignal tells the runner that the test was stopped, eg. by the user or a
+// violated threshold
+type StopSignal interface {
+	StopRequested() bool
+}
+
+// setupControl watches the control queue of the test, runners in docker are
+// stopped by the cli directly and get no control queue
+func (l *goadLambda) setupControl(config *aws.Config) {
+	if l.Settings.ControlURL == "" {
+		return
+	}
+	l.control = sqsadapter.NewControl(l.awsSession(), config, l.Settings.ControlURL)
+}
+
+// watchControl stops all workers once a stop is requested
+func (l *goadLambda) watchControl(control StopSignal) {
+	go func() {
+		ticker := time.NewTicker(controlPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				if control.StopRequested() {
+					fmt.Fprintln(l.out, "\nTest stopped, finishing requests in flight")
+					l.abort()
+					return
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,187 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/goadapp/goad/goad/assertion"
+	"github.com/goadapp/goad/goad/requestfile"
+	"github.com/goadapp/goad/goad/scenario"
+	"github.com/goadapp/goad/goad/templating"
+)
+
+// worker holds the state a worker keeps between its jobs
+type worker struct {
+	// vars holds the values extracted by the scenario steps
+	vars     map[string]string
+	renderer *templating.Renderer
+	// renderFailed reports a template that failed to render
+	renderFailed func(template string, err error)
+}
+
+func (l *goadLambda) newWorker() *worker {
+	return &worker{
+		vars:         make(map[string]string),
+		renderer:     templating.NewRenderer(l.feeder),
+		renderFailed: l.reportRenderFailure,
+	}
+}
+
+// reportRenderFailure prints the first template that failed to render, the
+// same template usually fails for every request
+func (l *goadLambda) reportRenderFailure(template string, err error) {
+	l.renderFailureOnce.Do(func() {
+		fmt.Fprintf(l.out, "Rendering template %q failed, failing parts are sent unchanged: %s\n", template, err)
+	})
+}
+
+// execute runs one job, a single request, a request picked from the request
+// mix or one iteration of the scenario, and sends the results to the main
+// loop. All requests of a job share the same row of the data file, the last
+// result completes the job.
+func (l *goadLambda) execute(w *worker) {
+	w.renderer.Next(w.vars)
+	if l.requestPicker != nil {
+		index := l.requestPicker.PickIndex()
+		params := mixRequestParameters(l.Settings.Requests[index], l.Settings.RequestParameters, l.Settings.mixAssertions(index))
+		result := fetch(l.HTTPClient, w.render(params), l.StartTime)
+		result.completesJob = true
+		l.results <- result
+		return
+	}
+	if l.Settings.Scenario == nil {
+		result := fetch(l.HTTPClient, w.render(l.Settings.RequestParameters), l.StartTime)
+		result.completesJob = true
+		l.results <- result
+		return
+	}
+	steps := l.Settings.Scenario.Steps
+	for i, step := range steps {
+		params := w.stepRequestParameters(step, l.Settings.RequestParameters, l.Settings.stepAssertions(i))
+		result, response := fetchResponse(l.HTTPClient, params, l.StartTime, len(step.Extract) > 0)
+		result.completesJob = i == len(steps)-1
+		l.results <- result
+		if response == nil {
+			continue
+		}
+		for _, extraction := range step.Extract {
+			value, err := extraction.Extract(response.Header, response.Body)
+			if err != nil {
+				fmt.Fprintf(l.out, "Extracting %s in step %s failed: %s\n", extraction.Name, step.Name, err)
+				continue
+			}
+			w.vars[extraction.Name] = value
+		}
+	}
+}
+
+// render executes the templates in the URL, headers and body of the request,
+// parts that fail to render are sent unchanged
+func (w *worker) render(p requestParameters) requestParameters {
+	p.URL = w.renderString(p.URL)
+	p.RequestBody = w.renderString(p.RequestBody)
+	if len(p.RequestHeaders) > 0 {
+		headers := make([]string, len(p.RequestHeaders))
+		for i, header := range p.RequestHeaders {
+			headers[i] = w.renderString(header)
+		}
+		p.RequestHeaders = headers
+	}
+	return p
+}
+
+func (w *worker) renderString(str string) string {
+	rendered, err := w.renderer.Render(str)
+	if err != nil && w.renderFailed != nil {
+		w.renderFailed(str, err)
+	}
+	return rendered
+}
+
+// stepRequestParameters builds the rendered request of a scenario step, the
+// headers and assertions of the test apply to every step. The values
+// extracted by previous steps are substituted after the templates were
+// rendered, responses are never executed as templates.
+func (w *worker) stepRequestParameters(step scenario.Step, base requestParameters, assertions []*assertion.Assertion) requestParameters {
+	method := step.Method
+	if method == "" {
+		method = "GET"
+	}
+	headers := make([]string, 0, len(base.RequestHeaders)+len(step.Headers))
+	for _, header := range base.RequestHeaders {
+		headers = append(headers, w.renderString(header))
+	}
+	for _, header := range step.Headers {
+		headers = append(headers, w.renderStep(header))
+	}
+	return requestParameters{
+		Name:           step.Name,
+		URL:            w.renderStep(step.ResolveURL(base.URL)),
+		RequestMethod:  method,
+		RequestBody:    w.renderStep(step.Body),
+		RequestHeaders: headers,
+		Assertions:     assertions,
+	}
+}
+
+// renderStep renders a part of a step and substitutes the extracted values
+func (w *worker) renderStep(str string) string {
+	return scenario.Substitute(w.renderString(str), w.vars)
+}
+
+// mixRequestParameters builds the request for an entry of the request mix,
+// the headers and assertions of the test apply to every request
+func mixRequestParameters(request requestfile.Request, base requestParameters, assertions []*assertion.Assertion) requestParameters {
+	headers := make([]string, 0, len(base.RequestHeaders)+len(request.Headers))
+	headers = append(headers, base.RequestHeaders...)
+	headers = append(headers, request.Headers...)
+	return requestParameters{
+		Name:           request.Name,
+		URL:            request.ResolveURL(base.URL),
+		RequestMethod:  request.Method,
+		RequestBody:    request.Body,
+		RequestHeaders: headers,
+		Assertions:     assertions,
+	}
+}
+
+// combineAssertions checks the assertions of the test for every request in
+// addition to those of the step or entry
+func combineAssertions(base []*assertion.Assertion, specific []string) ([]*assertion.Assertion, error) {
+	if len(specific) == 0 {
+		return base, nil
+	}
+	parsed, err := assertion.ParseAll(specific)
+	if err != nil {
+		return nil, err
+	}
+	assertions := make([]*assertion.Assertion, 0, len(base)+len(parsed))
+	assertions = append(assertions, base...)
+	return append(assertions, parsed...), nil
+}
+
+// stepAssertions returns the assertions checked for the step, settings
+// without parsed step assertions check those of the test
+func (s *LambdaSettings) stepAssertions(index int) []*assertion.Assertion {
+	if index < len(s.StepAssertions) {
+		return s.StepAssertions[index]
+	}
+	return s.RequestParameters.Assertions
+}
+
+// mixAssertions returns the assertions checked for the entry of the request
+// mix, settings without parsed entry assertions check those of the test
+func (s *LambdaSettings) mixAssertions(index int) []*assertion.Assertion {
+	if index < len(s.MixAssertions) {
+		return s.MixAssertions[index]
+	}
+	return s.RequestParameters.Assertions
+}
+
+// assertionSpecs returns the specs the assertions were parsed from
+func assertionSpecs(assertions []*assertion.Assertion) []string {
+	specs := make([]string, len(assertions))
+	for i, a := range assertions {
+		specs[i] = a.Spec
+	}
+	return specs
+}
@@ -0,0 +1,1120 @@
+package runner
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/goadapp/goad/api"
+	"github.com/goadapp/goad/goad/assertion"
+	"github.com/goadapp/goad/goad/histogram"
+	"github.com/goadapp/goad/goad/requestfile"
+	"github.com/goadapp/goad/goad/scenario"
+	"github.com/goadapp/goad/goad/templating"
+	"github.com/goadapp/goad/goad/types"
+	"github.com/goadapp/goad/transport"
+)
+
+const AWS_MAX_TIMEOUT = 295
+
+const (
+	// defaultFunctionName is invoked to fork runners of configurations
+	// without a function name
+	defaultFunctionName = "goad"
+	// forkMargin is the time in seconds left before the function timeout to
+	// send the results and fork a new runner
+	forkMargin = 5
+)
+
+// resultSender passes the results of a runner to the cli
+type resultSender interface {
+	SendResult(api.RunnerResult) error
+}
+
+// Run executes the load test described by the JSON runner configuration, the
+// results are sent to the transport given in the configuration
+func Run(payload []byte) {
+	rand.Seed(time.Now().UnixNano())
+	config, err := api.DecodeRunnerConfig(payload)
+	failOnError(err, "Failed to read the runner configuration")
+	failOnError(run(config), "Failed to run the load test")
+}
+
+func run(config api.RunnerConfig) error {
+	lambdaSettings, err := settingsFromConfig(config)
+	if err != nil {
+		return err
+	}
+	Lambda, err := newLambda(lambdaSettings)
+	if err != nil {
+		return err
+	}
+	sender, err := transport.NewSender(lambdaSettings.ResultsURL, transport.WithAWSSession(Lambda.awsSession()))
+	if err != nil {
+		return err
+	}
+	defer sender.Close()
+	Lambda.resultSender = sender
+	Lambda.setupControl(Lambda.setupAwsConfig())
+	return Lambda.runLoadTest()
+}
+
+// RunLocal executes the load test described by the runner configuration in
+// the current process, the test stops once stop requests it. Local runners
+// never fork and do not print their progress, failures are returned instead
+// of ending the process. No requests are sent once RunLocal returned.
+func RunLocal(config api.RunnerConfig, stop StopSignal) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	lambdaSettings, err := settingsFromConfig(config)
+	if err != nil {
+		return err
+	}
+	lambdaSettings.Local = true
+	Lambda, err := newLambda(lambdaSettings)
+	if err != nil {
+		return err
+	}
+	sender, err := transport.NewSender(lambdaSettings.ResultsURL)
+	if err != nil {
+		return err
+	}
+	defer sender.Close()
+	Lambda.resultSender = sender
+	Lambda.control = stop
+	Lambda.out = ioutil.Discard
+	return Lambda.runLoadTest()
+}
+
+// settingsFromConfig prepares the settings of the runner from the validated
+// configuration
+func settingsFromConfig(config api.RunnerConfig) (LambdaSettings, error) {
+	loadStages, err := types.ParseStages(config.Stages)
+	if err != nil {
+		return LambdaSettings{}, fmt.Errorf("invalid stages: %s", err)
+	}
+
+	var requestMix []requestfile.Request
+	if config.RequestsFile != "" {
+		requestMix, err = requestfile.Load(config.RequestsFile)
+		if err != nil {
+			return LambdaSettings{}, fmt.Errorf("failed to load requests file: %s", err)
+		}
+	}
+
+	assertions, err := assertion.ParseAll(config.Assertions)
+	if err != nil {
+		return LambdaSettings{}, fmt.Errorf("invalid assertion: %s", err)
+	}
+	var stepAssertions [][]*assertion.Assertion
+	if config.Scenario != nil {
+		for _, step := range config.Scenario.Steps {
+			combined, err := combineAssertions(assertions, step.Assert)
+			if err != nil {
+				return LambdaSettings{}, fmt.Errorf("invalid assertion in step %s: %s", step.Name, err)
+			}
+			stepAssertions = append(stepAssertions, combined)
+		}
+	}
+	var mixAssertions [][]*assertion.Assertion
+	for _, request := range requestMix {
+		combined, err := combineAssertions(assertions, request.Assert)
+		if err != nil {
+			return LambdaSettings{}, fmt.Errorf("invalid assertion in request %s: %s", request.Name, err)
+		}
+		mixAssertions = append(mixAssertions, combined)
+	}
+
+	method := config.Method
+	if method == "" {
+		method = "GET"
+	}
+	feeder := config.Feeder
+	if feeder == "" {
+		feeder = templating.Sequential
+	}
+	runnerCount := config.RunnerCount
+	if runnerCount < 1 {
+		runnerCount = 1
+	}
+
+	requestParameters := requestParameters{
+		URL:            config.URL,
+		RequestHeaders: config.Headers,
+		RequestMethod:  method,
+		RequestBody:    config.Body,
+		Assertions:     assertions,
+	}
+
+	lambdaSettings := LambdaSettings{
+		ClientTimeout:      config.ClientTimeout,
+		ResultsURL:         config.ResultsURL,
+		ControlURL:         config.ControlURL,
+		FunctionName:       config.FunctionName,
+		FunctionTimeout:    config.FunctionTimeout,
+		AWSEndpoint:        config.AWSEndpoint,
+		MaxRequestCount:    config.Requests,
+		CompletedJobCount:  config.CompletedRequests,
+		ConcurrencyCount:   config.Concurrency,
+		Rate:               config.Rate,
+		Stages:             loadStages,
+		StageOffset:        config.StageOffset,
+		Scenario:           config.Scenario,
+		RequestsFile:       config.RequestsFile,
+		Requests:           requestMix,
+		StepAssertions:     stepAssertions,
+		MixAssertions:      mixAssertions,
+		QueueRegion:        config.QueueRegion,
+		LambdaRegion:       config.Region,
+		ReportingFrequency: config.ReportingFrequency,
+		RequestParameters:  requestParameters,
+		StresstestTimeout:  config.ExecutionTime,
+		RunnerID:           config.RunnerID,
+		RunnerCount:        runnerCount,
+		DataFile:           config.DataFile,
+		Feeder:             feeder,
+		FeederPosition:     config.FeederPosition,
+		AbortPolicy: types.AbortPolicy{
+			ErrorRatio:          config.AbortErrorRatio,
+			MinSamples:          config.AbortMinSamples,
+			ConsecutiveTimeouts: config.AbortConsecutiveTimeouts,
+			Statuses:            config.AbortStatuses,
+		},
+	}
+	if lambdaSettings.ClientTimeout <= 0 {
+		lambdaSettings.ClientTimeout = 15 * time.Second
+	}
+	if lambdaSettings.ReportingFrequency <= 0 {
+		lambdaSettings.ReportingFrequency = 15 * time.Second
+	}
+	if lambdaSettings.FunctionName == "" {
+		lambdaSettings.FunctionName = defaultFunctionName
+	}
+	return lambdaSettings, nil
+}
+
+// runnerConfig returns the configuration the settings were created from, it
+// is passed to the forks of the runner
+func (s LambdaSettings) runnerConfig() api.RunnerConfig {
+	config := api.NewRunnerConfig()
+	config.URL = s.RequestParameters.URL
+	config.Method = s.RequestParameters.RequestMethod
+	config.Body = s.RequestParameters.RequestBody
+	config.Headers = s.RequestParameters.RequestHeaders
+	config.Assertions = assertionSpecs(s.RequestParameters.Assertions)
+	config.RunnerID = s.RunnerID
+	config.RunnerCount = s.RunnerCount
+	config.Region = s.LambdaRegion
+	config.QueueRegion = s.QueueRegion
+	config.ResultsURL = s.ResultsURL
+	config.ControlURL = s.ControlURL
+	config.FunctionName = s.FunctionName
+	config.FunctionTimeout = s.FunctionTimeout
+	config.AWSEndpoint = s.AWSEndpoint
+	config.Concurrency = s.ConcurrencyCount
+	config.Requests = s.MaxRequestCount
+	config.CompletedRequests = s.CompletedJobCount
+	config.ExecutionTime = s.StresstestTimeout
+	config.ClientTimeout = s.ClientTimeout
+	config.ReportingFrequency = s.ReportingFrequency
+	config.Rate = s.Rate
+	for _, stage := range s.Stages {
+		config.Stages = append(config.Stages, stage.String())
+	}
+	config.StageOffset = s.StageOffset
+	config.Scenario = s.Scenario
+	config.RequestsFile = s.RequestsFile
+	config.DataFile = s.DataFile
+	config.Feeder = s.Feeder
+	config.FeederPosition = s.FeederPosition
+	config.AbortErrorRatio = s.AbortPolicy.ErrorRatio
+	config.AbortMinSamples = s.AbortPolicy.MinSamples
+	config.AbortConsecutiveTimeouts = s.AbortPolicy.ConsecutiveTimeouts
+	config.AbortStatuses = s.AbortPolicy.Statuses
+	return config
+}
+
+// LambdaSettings represent the Lambdas configuration. MaxRequestCount and
+// CompletedJobCount count jobs, single requests or iterations of the
+// scenario, CompletedRequestCount counts the requests made.
+type LambdaSettings struct {
+	LambdaExecTimeoutSeconds int
+	ResultsURL               string
+	ControlURL               string
+	FunctionName             string
+	FunctionTimeout          int
+	AWSEndpoint              string
+	MaxRequestCount          int
+	CompletedJobCount        int
+	CompletedRequestCount    int
+	StresstestTimeout        int
+	ConcurrencyCount         int
+	Rate                     float64
+	Stages                   []types.Stage
+	StageOffset              time.Duration
+	Scenario                 *scenario.Scenario
+	RequestsFile             string
+	Requests                 []requestfile.Request
+	StepAssertions           [][]*assertion.Assertion
+	MixAssertions            [][]*assertion.Assertion
+	QueueRegion              string
+	LambdaRegion             string
+	ReportingFrequency       time.Duration
+	ClientTimeout            time.Duration
+	RequestParameters        requestParameters
+	RunnerID                 int
+	RunnerCount              int
+	DataFile                 string
+	Feeder                   string
+	FeederPosition           int
+	AbortPolicy              types.AbortPolicy
+	// Local runners run in the process of the cli, they are not limited by
+	// the lambda timeout
+	Local bool
+}
+
+// goadLambda holds the current state of the execution
+type goadLambda struct {
+	Settings      LambdaSettings
+	HTTPClient    *http.Client
+	Metrics       *requestMetric
+	lambdaService lambdaiface.LambdaAPI
+	session       *session.Session
+	resultSender  resultSender
+	results       chan requestResult
+	jobs          chan struct{}
+	StartTime     time.Time
+	wg            sync.WaitGroup
+	droppedCount  int64
+	lateCount     int64
+	requestPicker *requestfile.Picker
+	feeder        *templating.Feeder
+	abortMonitor  *abortMonitor
+	control       StopSignal
+	// out receives the progress messages of the runner
+	out io.Writer
+	// stop is closed to stop all workers when the abort policy triggers or
+	// the test is stopped through the control queue
+	stop     chan struct{}
+	stopOnce sync.Once
+	// renderFailureOnce reports only the first template that failed to
+	// render
+	renderFailureOnce sync.Once
+}
+
+type requestParameters struct {
+	Name           string
+	URL            string
+	Requestcount   int
+	RequestMethod  string
+	RequestBody    string
+	RequestHeaders []string
+	Assertions     []*assertion.Assertion
+}
+
+type requestResult struct {
+	Name             string `json:"name"`
+	Time             int64  `json:"time"`
+	Host             string `json:"host"`
+	Type             string `json:"type"`
+	Status           int    `json:"status"`
+	ElapsedFirstByte int64  `json:"elapsed-first-byte"`
+	ElapsedLastByte  int64  `json:"elapsed-last-byte"`
+	Elapsed          int64  `json:"elapsed"`
+	Bytes            int    `json:"bytes"`
+	Timeout          bool   `json:"timeout"`
+	ConnectionError  bool   `json:"connection-error"`
+	State            string `json:"state"`
+	DNS              int64  `json:"dns"`
+	Connect          int64  `json:"connect"`
+	TLS              int64  `json:"tls"`
+	Server           int64  `json:"server"`
+	Transfer         int64  `json:"transfer"`
+
+	FailedAssertions []string `json:"failed-assertions"`
+
+	// completesJob is set on the last result of a job
+	completesJob bool
+}
+
+// runLoadTest runs the load test until it finished, was stopped or has to
+// fork, the workers and schedulers have returned once it returns. If the
+// results can not be sent the test is aborted and the error is returned.
+func (l *goadLambda) runLoadTest() error {
+	fmt.Fprintf(l.out, "Using a timeout of %s\n", l.Settings.ClientTimeout)
+	fmt.Fprintf(l.out, "Using a reporting frequency of %s\n", l.Settings.ReportingFrequency)
+	if l.Settings.Rate > 0 {
+		fmt.Fprintf(l.out, "Will schedule %.2f requests/s with up to %d in flight making %d requests to %s\n", l.Settings.Rate, l.Settings.ConcurrencyCount, l.Settings.MaxRequestCount, l.Settings.RequestParameters.URL)
+	} else {
+		fmt.Fprintf(l.out, "Will spawn %d workers making %d requests to %s\n", l.Settings.ConcurrencyCount, l.Settings.MaxRequestCount, l.Settings.RequestParameters.URL)
+	}
+
+	l.StartTime = time.Now()
+	if l.control != nil {
+		l.watchControl(l.control)
+	}
+
+	if l.Settings.Rate > 0 {
+		l.spawnRateScheduler()
+	} else if len(l.Settings.Stages) > 0 {
+		l.spawnStagedWorkers()
+	} else {
+		l.spawnConcurrentWorkers()
+	}
+
+	// done is closed once the workers and the schedulers returned
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(l.Settings.ReportingFrequency)
+	defer ticker.Stop()
+	quit := time.NewTimer(time.Duration(l.Settings.LambdaExecTimeoutSeconds) * time.Second)
+	defer quit.Stop()
+	timedOut := false
+	finished := false
+	var sendErr error
+
+	for !timedOut && !finished {
+		select {
+		case r := <-l.results:
+			l.addResult(r)
+			continue
+
+		case <-ticker.C:
+			// results are sent on every tick, even without new requests, so
+			// the cli knows the runner is alive
+			progress := l.collectSchedulerStats() || l.Metrics.requestCountSinceLastSend > 0
+			if err := l.Metrics.sendAggregatedResults(l.resultSender); err != nil && sendErr == nil {
+				// nobody receives the results, the workers are stopped
+				sendErr = err
+				l.abort()
+			}
+			if progress {
+				fmt.Fprintf(l.out, "\nYay🎈  - %d requests completed\n", l.Settings.CompletedRequestCount)
+			}
+			continue
+
+		case <-done:
+			finished = true
+			continue
+
+		case <-quit.C:
+			timedOut = true
+			finished = l.updateStresstestTimeout()
+		}
+	}
+	fork := timedOut && !finished && !l.aborted()
+	// nothing may keep running once the runner returned, runners handle
+	// further invocations in the same process
+	l.abort()
+	for waiting := true; waiting; {
+		select {
+		case r := <-l.results:
+			l.addResult(r)
+		case <-done:
+			waiting = false
+		}
+	}
+	if fork && l.Settings.MaxRequestCount > 0 && l.Settings.CompletedJobCount >= l.Settings.MaxRequestCount {
+		// the requests in flight completed the last jobs
+		fork = false
+		finished = true
+	}
+	forked := false
+	if fork {
+		forked = l.forkNewLambda()
+	}
+	l.collectSchedulerStats()
+	l.Metrics.aggregatedResults.Finished = finished
+	l.Metrics.aggregatedResults.Forked = forked
+	if err := l.Metrics.sendAggregatedResults(l.resultSender); err != nil && sendErr == nil {
+		sendErr = err
+	}
+	fmt.Fprintf(l.out, "\nYay🎈  - %d requests completed\n", l.Settings.CompletedRequestCount)
+	return sendErr
+}
+
+// addResult counts the result of a request and checks the abort policy
+func (l *goadLambda) addResult(r requestResult) {
+	l.Settings.CompletedRequestCount++
+	if r.completesJob {
+		l.Settings.CompletedJobCount++
+	}
+
+	l.Metrics.addRequest(&r)
+	if reason := l.abortMonitor.check(&r); reason != "" && !l.aborted() {
+		fmt.Fprintf(l.out, "\n%s\n", reason)
+		l.Metrics.fatalError = reason
+		l.abort()
+	}
+	if r.completesJob && (l.Settings.CompletedJobCount%1000 == 0 || l.Settings.CompletedJobCount == l.Settings.MaxRequestCount) {
+		fmt.Fprintf(l.out, "\r%.2f%% done (%d jobs out of %d)", (float64(l.Settings.CompletedJobCount)/float64(l.Settings.MaxRequestCount))*100.0, l.Settings.CompletedJobCount, l.Settings.MaxRequestCount)
+	}
+}
+
+// newLambda creates a new Lambda to execute a load test from a given
+// LambdaSettings
+func newLambda(s LambdaSettings) (*goadLambda, error) {
+	setLambdaExecTimeout(&s)
+	setDefaultConcurrencyCount(&s)
+
+	l := &goadLambda{}
+	l.Settings = s
+
+	l.Metrics = NewRequestMetric(s.LambdaRegion, s.RunnerID)
+	l.abortMonitor = newAbortMonitor(s.AbortPolicy)
+	l.stop = make(chan struct{})
+	l.out = os.Stdout
+	remainingJobCount := s.MaxRequestCount - s.CompletedJobCount
+	if remainingJobCount < 0 {
+		remainingJobCount = 0
+	}
+	if len(s.Requests) > 0 {
+		l.requestPicker = requestfile.NewPicker(s.Requests)
+	}
+	if s.DataFile != "" {
+		feeder, err := templating.LoadFeeder(s.DataFile, s.Feeder, s.RunnerID, s.RunnerCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load data file: %s", err)
+		}
+		feeder.Seek(s.FeederPosition)
+		l.feeder = feeder
+	}
+	l.setupHTTPClientForSelfsignedTLS()
+	l.setupJobQueue(remainingJobCount)
+	l.results = make(chan requestResult)
+	return l, nil
+}
+
+func setDefaultConcurrencyCount(s *LambdaSettings) {
+	if s.ConcurrencyCount < 1 {
+		s.ConcurrencyCount = 1
+	}
+}
+
+func setLambdaExecTimeout(s *LambdaSettings) {
+	if s.Local {
+		s.LambdaExecTimeoutSeconds = s.StresstestTimeout
+		if s.StresstestTimeout <= 0 {
+			s.LambdaExecTimeoutSeconds = math.MaxInt32
+		}
+	} else if maxTimeout := s.maxExecTimeout(); s.StresstestTimeout <= 0 || s.StresstestTimeout > maxTimeout {
+		s.LambdaExecTimeoutSeconds = maxTimeout
+	} else {
+		s.LambdaExecTimeoutSeconds = s.StresstestTimeout
+	}
+}
+
+// maxExecTimeout returns the seconds a runner may run before it has to fork,
+// configurations without a function timeout are of functions deployed with
+// the former fixed timeout
+func (s *LambdaSettings) maxExecTimeout() int {
+	if s.FunctionTimeout <= forkMargin {
+		return AWS_MAX_TIMEOUT
+	}
+	return s.FunctionTimeout - forkMargin
+}
+
+func (l *goadLambda) setupHTTPClientForSelfsignedTLS() {
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	l.HTTPClient = &http.Client{Transport: tr}
+	l.HTTPClient.Timeout = l.Settings.ClientTimeout
+}
+
+func (l *goadLambda) setupAwsConfig() *aws.Config {
+	return aws.NewConfig().WithRegion(l.Settings.QueueRegion)
+}
+
+// awsSession returns the session shared by the AWS clients of the runner
+func (l *goadLambda) awsSession() *session.Session {
+	if l.session == nil {
+		config := aws.NewConfig()
+		if l.Settings.AWSEndpoint != "" {
+			config = config.WithEndpoint(l.Settings.AWSEndpoint)
+		}
+		l.session = session.New(config)
+	}
+	return l.session
+}
+
+func failOnError(err error, msg string) {
+	if err != nil {
+		log.Fatalf("%s: %s", msg, err)
+		panic(fmt.Sprintf("%s: %s", msg, err))
+	}
+}
+
+func (l *goadLambda) setupJobQueue(count int) {
+	l.jobs = make(chan struct{}, count)
+	for i := 0; i < count; i++ {
+		l.jobs <- struct{}{}
+	}
+	close(l.jobs)
+}
+
+func (l *goadLambda) updateStresstestTimeout() bool {
+	if l.Settings.StresstestTimeout != 0 {
+		l.Settings.StresstestTimeout -= l.Settings.LambdaExecTimeoutSeconds
+		return l.Settings.StresstestTimeout <= 0
+	}
+	return false
+}
+
+func (l *goadLambda) spawnConcurrentWorkers() {
+	fmt.Fprint(l.out, "Spawning workers…")
+	for i := 0; i < l.Settings.ConcurrencyCount; i++ {
+		l.spawnWorker(nil)
+		fmt.Fprint(l.out, ".")
+	}
+	fmt.Fprintln(l.out, " done.\nWaiting for results…")
+}
+
+// stageAdjustInterval is the pause between two adjustments of the number of
+// workers while following a load profile
+const stageAdjustInterval = 100 * time.Millisecond
+
+// spawnStagedWorkers adds and retires workers over time to follow the
+// configured stages. The stage offset accounts for the time spent by previous
+// lambdas in case this one was forked after a lambda timeout.
+func (l *goadLambda) spawnStagedWorkers() {
+	fmt.Fprintf(l.out, "Following %d stages starting at %s\nWaiting for results…\n", len(l.Settings.Stages), l.Settings.StageOffset)
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		workers := make([]chan struct{}, 0)
+		ticker := time.NewTicker(stageAdjustInterval)
+		defer ticker.Stop()
+		for {
+			_, concurrency, done := types.StageAt(l.Settings.Stages, l.stageElapsed())
+			target := int(concurrency + 0.5)
+			done = done || l.jobsExhausted() || l.aborted()
+			if done {
+				target = 0
+			}
+			for len(workers) < target {
+				stop := make(chan struct{})
+				workers = append(workers, stop)
+				l.spawnWorker(stop)
+			}
+			for len(workers) > target {
+				close(workers[len(workers)-1])
+				workers = workers[:len(workers)-1]
+			}
+			if done {
+				return
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func (l *goadLambda) stageElapsed() time.Duration {
+	return l.Settings.StageOffset + time.Since(l.StartTime)
+}
+
+// abort stops all workers, requests in flight are completed
+func (l *goadLambda) abort() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+}
+
+func (l *goadLambda) aborted() bool {
+	select {
+	case <-l.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *goadLambda) jobsExhausted() bool {
+	return l.Settings.MaxRequestCount > 0 && len(l.jobs) == 0
+}
+
+func (l *goadLambda) spawnWorker(stop <-chan struct{}) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		work(l, stop)
+	}()
+}
+
+// spawnRateScheduler starts requests at a fixed rate independent of the
+// response times of the target (open workload model). ConcurrencyCount bounds
+// the number of requests in flight, sends that find the pool exhausted are
+// dropped and do not use up the requests of the test. A schedule that falls
+// behind by more than one interval continues from now instead of catching up
+// in a burst, the missed sends are counted as late.
+func (l *goadLambda) spawnRateScheduler() {
+	interval := time.Duration(float64(time.Second) / l.Settings.Rate)
+	inFlight := make(chan struct{}, l.Settings.ConcurrencyCount)
+	fmt.Fprintf(l.out, "Scheduling a request every %s\nWaiting for results…\n", interval)
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		next := time.Now()
+		for {
+			if l.aborted() || l.jobsExhausted() {
+				return
+			}
+			var missed int64
+			next, missed = catchUp(next, time.Now(), interval)
+			atomic.AddInt64(&l.lateCount, missed)
+			if wait := next.Sub(time.Now()); wait > 0 {
+				select {
+				case <-l.stop:
+					return
+				case <-time.After(wait):
+				}
+			}
+			next = next.Add(interval)
+			select {
+			case inFlight <- struct{}{}:
+				if l.Settings.MaxRequestCount > 0 {
+					if _, ok := <-l.jobs; !ok {
+						return
+					}
+				}
+				l.wg.Add(1)
+				go func() {
+					defer l.wg.Done()
+					defer func() { <-inFlight }()
+					l.execute(l.newWorker())
+				}()
+			default:
+				atomic.AddInt64(&l.droppedCount, 1)
+			}
+		}
+	}()
+}
+
+// catchUp returns when the send scheduled at next is due. If now is more than
+// one interval past next, the send is due now and the number of missed
+// intervals is returned.
+func catchUp(next, now time.Time, interval time.Duration) (time.Time, int64) {
+	behind := now.Sub(next)
+	if behind <= interval {
+		return next, 0
+	}
+	return now, int64(behind / interval)
+}
+
+// collectSchedulerStats moves the counters of the rate scheduler into the
+// metrics that are sent with the next report, it returns true if there is
+// anything to report.
+func (l *goadLambda) collectSchedulerStats() bool {
+	agg := l.Metrics.aggregatedResults
+	agg.Dropped += int(atomic.SwapInt64(&l.droppedCount, 0))
+	agg.Late += int(atomic.SwapInt64(&l.lateCount, 0))
+	return agg.Dropped > 0 || agg.Late > 0
+}
+
+// work executes requests until the jobs are exhausted, the stop channel is
+// closed or the test is aborted, a nil stop channel never stops the worker
+func work(l *goadLambda, stop <-chan struct{}) {
+	w := l.newWorker()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-l.stop:
+			return
+		default:
+		}
+		if l.Settings.MaxRequestCount > 0 {
+			_, ok := <-l.jobs
+			if !ok {
+				break
+			}
+		}
+		l.execute(w)
+	}
+}
+
+func fetch(client *http.Client, p requestParameters, loadTestStartTime time.Time) requestResult {
+	result, _ := fetchResponse(client, p, loadTestStartTime, false)
+	return result
+}
+
+// capturedResponse keeps the parts of a response needed to inspect it after
+// the request finished
+type capturedResponse struct {
+	Header http.Header
+	Body   []byte
+}
+
+// fetchResponse executes the request, if capture is set the headers and the
+// (decompressed) body of the response are returned as well
+func fetchResponse(client *http.Client, p requestParameters, loadTestStartTime time.Time, capture bool) (requestResult, *capturedResponse) {
+	inspect := capture || len(p.Assertions) > 0
+	timer := &phaseTimer{}
+	start := time.Now()
+	req := prepareHttpRequest(p)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timer.clientTrace()))
+	response, err := client.Do(req)
+
+	var status string
+	var elapsedFirstByte time.Duration
+	var elapsedLastByte time.Duration
+	var lastByte time.Time
+	var elapsed time.Duration
+	var statusCode int
+	var bytesRead int
+	var captured *capturedResponse
+	buf := []byte(" ")
+	timedOut := false
+	connectionError := false
+	isRedirect := err != nil && strings.Contains(err.Error(), "redirect")
+	if err != nil && !isRedirect {
+		status = fmt.Sprintf("ERROR: %s\n", err)
+		switch err := err.(type) {
+		case *url.Error:
+			if err, ok := err.Err.(net.Error); ok && err.Timeout() {
+				timedOut = true
+			}
+		case net.Error:
+			if err.Timeout() {
+				timedOut = true
+			}
+		}
+
+		if !timedOut {
+			connectionError = true
+		}
+	} else {
+		statusCode = response.StatusCode
+		elapsedFirstByte = time.Since(start)
+		if !isRedirect {
+			_, err = response.Body.Read(buf)
+			firstByteRead := true
+			if err != nil {
+				status = fmt.Sprintf("reading first byte failed: %s\n", err)
+				firstByteRead = false
+			}
+			body, err := ioutil.ReadAll(response.Body)
+			if firstByteRead {
+				bytesRead = len(body) + 1
+			}
+			lastByte = time.Now()
+			elapsedLastByte = lastByte.Sub(start)
+			if err != nil {
+				// todo: detect timeout here as well
+				status = fmt.Sprintf("reading response body failed: %s\n", err)
+				connectionError = true
+			} else {
+				status = "Success"
+			}
+			if inspect && firstByteRead {
+				captured = captureResponse(response.Header, append(buf, body...))
+			}
+		} else {
+			status = "Redirect"
+		}
+		response.Body.Close()
+
+		elapsed = time.Since(start)
+	}
+
+	result := requestResult{
+		Name:             p.Name,
+		Time:             start.Sub(loadTestStartTime).Nanoseconds(),
+		Host:             req.URL.Host,
+		Type:             req.Method,
+		Status:           statusCode,
+		ElapsedFirstByte: elapsedFirstByte.Nanoseconds(),
+		ElapsedLastByte:  elapsedLastByte.Nanoseconds(),
+		Elapsed:          elapsed.Nanoseconds(),
+		Bytes:            bytesRead,
+		Timeout:          timedOut,
+		ConnectionError:  connectionError,
+		State:            status,
+	}
+	timer.apply(&result, lastByte)
+	if len(p.Assertions) > 0 && !timedOut && !connectionError {
+		result.FailedAssertions = checkAssertions(p.Assertions, statusCode, elapsed, response, captured)
+	}
+	if !capture {
+		captured = nil
+	}
+	return result, captured
+}
+
+// checkAssertions returns the specs of the assertions the response failed
+func checkAssertions(assertions []*assertion.Assertion, status int, elapsed time.Duration, response *http.Response, captured *capturedResponse) []string {
+	checked := assertion.Response{
+		Status:  status,
+		Elapsed: elapsed,
+		Header:  response.Header,
+	}
+	if captured != nil {
+		checked.Body = captured.Body
+	}
+	return assertion.Failed(assertions, checked)
+}
+
+func captureResponse(header http.Header, body []byte) *capturedResponse {
+	if header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err == nil {
+			if decompressed, err := ioutil.ReadAll(reader); err == nil {
+				body = decompressed
+			}
+		}
+	}
+	return &capturedResponse{Header: header, Body: body}
+}
+
+func prepareHttpRequest(params requestParameters) *http.Request {
+	req, err := http.NewRequest(params.RequestMethod, params.URL, bytes.NewBufferString(params.RequestBody))
+	if err != nil {
+		fmt.Println("Error creating the HTTP request:", err)
+		panic("")
+	}
+	req.Header.Add("Accept-Encoding", "gzip")
+	for _, v := range params.RequestHeaders {
+		header := strings.Split(v, ":")
+		if strings.ToLower(strings.Trim(header[0], " ")) == "host" {
+			req.Host = strings.Trim(header[1], " ")
+		} else {
+			req.Header.Add(strings.Trim(header[0], " "), strings.Trim(header[1], " "))
+		}
+	}
+
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Add("User-Agent", "Mozilla/5.0 (compatible; Goad/1.0; +https://goad.io)")
+	}
+	return req
+}
+
+type requestMetric struct {
+	aggregatedResults         *api.RunnerResult
+	firstRequestTime          int64
+	lastRequestTime           int64
+	timeToFirstTotal          int64
+	requestTimeTotal          int64
+	requestCountSinceLastSend int64
+	// fatalError is the reason the runner aborted, it is reported with every
+	// following result
+	fatalError string
+}
+
+func NewRequestMetric(region string, runnerID int) *requestMetric {
+	metric := &requestMetric{
+		aggregatedResults: &api.RunnerResult{
+			Region:   region,
+			RunnerID: runnerID,
+		},
+	}
+	metric.resetAndKeepTotalReqs()
+	return metric
+}
+
+func (m *requestMetric) addRequest(r *requestResult) {
+	agg := m.aggregatedResults
+	agg.RequestCount++
+	m.requestCountSinceLastSend++
+	if m.firstRequestTime == 0 {
+		m.firstRequestTime = r.Time
+	}
+	m.lastRequestTime = r.Time + r.Elapsed
+
+	if r.Name != "" {
+		m.addToBreakdown(r)
+	}
+	if len(r.FailedAssertions) > 0 {
+		if agg.FailedAssertions == nil {
+			agg.FailedAssertions = make(map[string]int)
+		}
+		for _, spec := range r.FailedAssertions {
+			agg.FailedAssertions[spec]++
+		}
+		if r.Status < 400 {
+			agg.FailedAssertionRequests++
+		}
+	}
+
+	if r.Timeout {
+		agg.TimedOut++
+	} else if r.ConnectionError {
+		agg.ConnectionErrors++
+	} else {
+		agg.BytesRead += r.Bytes
+		m.requestTimeTotal += r.ElapsedLastByte
+		m.timeToFirstTotal += r.ElapsedFirstByte
+
+		agg.Fastest = Min(r.ElapsedLastByte, agg.Fastest)
+		agg.Slowest = Max(r.ElapsedLastByte, agg.Slowest)
+		agg.TimeToFirstHistogram.Record(r.ElapsedFirstByte)
+		agg.TimeForReqHistogram.Record(r.ElapsedLastByte)
+		addPhases(&agg.Phases, r)
+
+		statusStr := strconv.Itoa(r.Status)
+		_, ok := agg.Statuses[statusStr]
+		if !ok {
+			agg.Statuses[statusStr] = 1
+		} else {
+			agg.Statuses[statusStr]++
+		}
+	}
+	m.aggregate()
+}
+
+func addPhases(phases *api.Phases, r *requestResult) {
+	for _, phase := range []struct {
+		stats    *api.PhaseStats
+		duration int64
+	}{
+		{&phases.DNS, r.DNS},
+		{&phases.Connect, r.Connect},
+		{&phases.TLS, r.TLS},
+		{&phases.Server, r.Server},
+		{&phases.Transfer, r.Transfer},
+	} {
+		if phase.duration > 0 {
+			phase.stats.Add(phase.duration)
+		}
+	}
+}
+
+func (m *requestMetric) addToBreakdown(r *requestResult) {
+	agg := m.aggregatedResults
+	if agg.Breakdown == nil {
+		agg.Breakdown = make(map[string]*api.RequestStats)
+	}
+	stats, ok := agg.Breakdown[r.Name]
+	if !ok {
+		stats = api.NewRequestStats()
+		agg.Breakdown[r.Name] = stats
+	}
+	stats.RequestCount++
+	if r.Timeout {
+		stats.TimedOut++
+	} else if r.ConnectionError {
+		stats.ConnectionErrors++
+	} else {
+		stats.BytesRead += r.Bytes
+		stats.Statuses[strconv.Itoa(r.Status)]++
+		stats.TimeForReqTotal += r.ElapsedLastByte
+		stats.TimeForReqHistogram.Record(r.ElapsedLastByte)
+		if len(r.FailedAssertions) > 0 && r.Status < 400 {
+			stats.FailedAssertionRequests++
+		}
+	}
+}
+
+func (m *requestMetric) aggregate() {
+	agg := m.aggregatedResults
+	countOk := int(m.requestCountSinceLastSend) - (agg.TimedOut + agg.ConnectionErrors)
+	agg.TimeDelta = time.Duration(m.lastRequestTime-m.firstRequestTime) * time.Nanosecond
+	if countOk > 0 {
+		agg.AveTimeToFirst = m.timeToFirstTotal / int64(countOk)
+		agg.AveTimeForReq = m.requestTimeTotal / int64(countOk)
+	}
+	agg.FatalError = m.fatalError
+}
+
+func (m *requestMetric) sendAggregatedResults(sender resultSender) error {
+	if err := sender.SendResult(*m.aggregatedResults); err != nil {
+		return fmt.Errorf("failed to send results: %s", err)
+	}
+	m.resetAndKeepTotalReqs()
+	return nil
+}
+
+func (m *requestMetric) resetAndKeepTotalReqs() {
+	m.requestCountSinceLastSend = 0
+	m.firstRequestTime = 0
+	m.lastRequestTime = 0
+	m.requestTimeTotal = 0
+	m.timeToFirstTotal = 0
+	m.aggregatedResults = &api.RunnerResult{
+		Region:               m.aggregatedResults.Region,
+		RunnerID:             m.aggregatedResults.RunnerID,
+		Statuses:             make(map[string]int),
+		Fastest:              math.MaxInt64,
+		Finished:             false,
+		TimeToFirstHistogram: histogram.New(),
+		TimeForReqHistogram:  histogram.New(),
+	}
+}
+
+// forkNewLambda invokes a runner continuing the test and returns whether
+// the invocation succeeded. The fork is invoked asynchronously, this runner
+// returns before its own function timeout instead of waiting for the fork.
+func (l *goadLambda) forkNewLambda() bool {
+	svc := l.provideLambdaService()
+	config := l.getRunnerConfigForFork()
+
+	j, _ := json.Marshal(config)
+
+	output, err := svc.Invoke(&lambda.InvokeInput{
+		FunctionName:   aws.String(l.Settings.FunctionName),
+		InvocationType: aws.String(lambda.InvocationTypeEvent),
+		Payload:        j,
+	})
+	fmt.Fprintln(l.out, output)
+	fmt.Fprintln(l.out, err)
+	return err == nil
+}
+
+func (l *goadLambda) provideLambdaService() lambdaiface.LambdaAPI {
+	if l.lambdaService == nil {
+		l.lambdaService = lambda.New(l.awsSession(), aws.NewConfig().WithRegion(l.Settings.LambdaRegion))
+	}
+	return l.lambdaService
+}
+
+// getRunnerConfigForFork returns the configuration of the runner continuing
+// the test, it starts where this runner stopped
+func (l *goadLambda) getRunnerConfigForFork() api.RunnerConfig {
+	config := l.Settings.runnerConfig()
+	config.StageOffset = l.stageElapsed()
+	if l.feeder != nil {
+		config.FeederPosition = l.feeder.Position()
+	}
+	return config
+}
+
+// Min calculates minimum of two int64
+func Min(x, y int64) int64 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// Max calculates maximum of two int64
+func Max(x, y int64) int64 {
+	if x > y {
+		return x
+	}
+	return y
+}
@@ -0,0 +1,1258 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/goadapp/goad/api"
+	"github.com/goadapp/goad/goad/assertion"
+	"github.com/goadapp/goad/goad/requestfile"
+	"github.com/goadapp/goad/goad/scenario"
+	"github.com/goadapp/goad/goad/templating"
+	"github.com/goadapp/goad/goad/types"
+	"github.com/goadapp/goad/transport"
+)
+
+var port int
+var urlStr string
+var portStr string
+
+func TestMain(m *testing.M) {
+	port = 8080
+	urlStr = fmt.Sprintf("http://localhost:%d/", port)
+	portStr = fmt.Sprintf(":%d", port)
+	code := m.Run()
+	os.Exit(code)
+}
+
+func TestRequestMetric(t *testing.T) {
+	metric := NewRequestMetric("us-east-1", 0)
+	agg := metric.aggregatedResults
+	if agg.RequestCount != 0 {
+		t.Error("totalRequestsFinished should be initialized with 0")
+	}
+	if metric.requestCountSinceLastSend != 0 {
+		t.Error("totalRequestsFinished should be initialized with 0")
+	}
+	if agg.Fastest != math.MaxInt64 {
+		t.Error("Fastest should be initialized with a big value")
+	}
+	if agg.Slowest != 0 {
+		t.Error("Slowest should be initialized with a big value")
+	}
+	if metric.firstRequestTime != 0 {
+		t.Error("without requests this field should be 0")
+	}
+	if metric.lastRequestTime != 0 {
+		t.Error("without requests this field should be 0")
+	}
+	if metric.timeToFirstTotal != 0 {
+		t.Error("without requests this field should be 0")
+	}
+	if metric.requestTimeTotal != 0 {
+		t.Error("without requests this field should be 0")
+	}
+	if agg.BytesRead != 0 {
+		t.Error("without requests this field should be 0")
+	}
+}
+
+func TestAddRequestStatus(t *testing.T) {
+	success := 200
+	successStr := strconv.Itoa(success)
+	metric := NewRequestMetric("us-east-1", 0)
+	result := &requestResult{
+		Status: success,
+	}
+	metric.addRequest(result)
+	if metric.aggregatedResults.Statuses[successStr] != 1 {
+		t.Error("metric should update cound of statuses map")
+	}
+	metric.addRequest(result)
+	if metric.aggregatedResults.Statuses[successStr] != 2 {
+		t.Error("metric should update cound of statuses map")
+	}
+}
+
+func TestAddRequest(t *testing.T) {
+	bytes := 1000
+	elapsedFirst := int64(100)
+	elapsedLast := int64(300)
+
+	metric := NewRequestMetric("us-east-1", 0)
+	result := &requestResult{
+		Time:             400,
+		ElapsedFirstByte: elapsedFirst,
+		ElapsedLastByte:  elapsedLast,
+		Bytes:            bytes,
+		Timeout:          false,
+		ConnectionError:  false,
+		Elapsed:          100,
+	}
+	metric.addRequest(result)
+	if metric.lastRequestTime != result.Time+100 {
+		t.Error("metrics should update lastRequestTime")
+	}
+	if metric.aggregatedResults.RequestCount != 1 {
+		t.Error("metrics should update totalRequestsFinished")
+	}
+	if metric.requestCountSinceLastSend != 1 {
+		t.Error("metrics should upate totalRequestsFinished")
+	}
+	result.ElapsedLastByte = 400
+	result.Time = 800
+	metric.addRequest(result)
+	agg := metric.aggregatedResults
+	if agg.RequestCount != 2 {
+		t.Error("metrics should update totalRequestsFinished")
+	}
+	if metric.requestCountSinceLastSend != 2 {
+		t.Error("metrics should upate totalRequestsFinished")
+	}
+	if agg.BytesRead != 2*bytes {
+		t.Error("metrics should add successful requests Bytes to totalBytesRead")
+	}
+	if metric.requestTimeTotal != 700 {
+		t.Error("metrics should add successful requests elapsedLast to requestTimeTotal")
+	}
+	if metric.timeToFirstTotal != 2*elapsedFirst {
+		t.Error("metrics should add successful requests elapsedFirst to timeToFirstTotal")
+	}
+	if metric.firstRequestTime != 400 {
+		t.Error("metrics should keep first requests time")
+	}
+	if metric.lastRequestTime != 800+100 {
+		t.Error("metrics should update lastRequestsTime")
+	}
+	if agg.Fastest != 300 {
+		t.Errorf("Expected fastes requests to have taken 300, was: %d", agg.Fastest)
+	}
+	if agg.Slowest != 400 {
+		t.Errorf("Expected fastes requests to have taken 300, was: %d", agg.Fastest)
+	}
+	if agg.TimeForReqHistogram.Count() != 2 {
+		t.Errorf("Expected 2 requests in the latency histogram, was: %d", agg.TimeForReqHistogram.Count())
+	}
+	result.Timeout = true
+	metric.addRequest(result)
+	if agg.RequestCount != 3 {
+		t.Error("metrics should update totalRequestsFinished")
+	}
+	if metric.requestCountSinceLastSend != 3 {
+		t.Error("metrics should upate totalRequestsFinished")
+	}
+	if agg.BytesRead != 2*bytes {
+		t.Error("metrics should not add timedout requests Bytes to totalBytesRead")
+	}
+	if metric.requestTimeTotal != 700 {
+		t.Error("metrics should not add timedout requests elapsedLast to requestTimeTotal")
+	}
+	if metric.timeToFirstTotal != 2*elapsedFirst {
+		t.Error("metrics should not add timedout requests elapsedFirst to timeToFirstTotal")
+	}
+	if metric.firstRequestTime != 400 {
+		t.Error("metrics should keep first requests time")
+	}
+	if metric.lastRequestTime != 800+100 {
+		t.Error("metrics should update lastRequestsTime")
+	}
+	if agg.TimedOut != 1 {
+		t.Error("metrics should update TotalTimeOut")
+	}
+	result.ConnectionError = true
+	result.Timeout = false
+	metric.addRequest(result)
+	if agg.RequestCount != 4 {
+		t.Error("metrics should update totalRequestsFinished")
+	}
+	if metric.requestCountSinceLastSend != 4 {
+		t.Error("metrics should upate totalRequestsFinished")
+	}
+	if agg.BytesRead != 2*bytes {
+		t.Error("metrics should not add timedout requests Bytes to totalBytesRead")
+	}
+	if metric.requestTimeTotal != 700 {
+		t.Error("metrics should not add timedout requests elapsedLast to requestTimeTotal")
+	}
+	if metric.timeToFirstTotal != 2*elapsedFirst {
+		t.Error("metrics should not add timedout requests elapsedFirst to timeToFirstTotal")
+	}
+	if metric.firstRequestTime != 400 {
+		t.Error("metrics should keep first requests time")
+	}
+	if metric.lastRequestTime != 800+100 {
+		t.Error("metrics should update lastRequestsTime")
+	}
+	if agg.ConnectionErrors != 1 {
+		t.Error("metrics should update TotalConnectionError")
+	}
+}
+
+func TestResetAndKeepTotalReqs(t *testing.T) {
+	metric := NewRequestMetric("us-east-1", 0)
+	agg := metric.aggregatedResults
+	agg.RequestCount = 7
+	metric.firstRequestTime = 123
+	metric.lastRequestTime = 123
+	metric.requestCountSinceLastSend = 123
+	metric.requestTimeTotal = 123
+	metric.timeToFirstTotal = 123
+	agg.BytesRead = 123
+
+	metric.resetAndKeepTotalReqs()
+	agg = metric.aggregatedResults
+	if agg.RequestCount != 0 {
+		t.Error("TotalReqs should be reset to 0")
+	}
+	if metric.requestCountSinceLastSend != 0 {
+		t.Error("totalRequestsFinished should be reset to 0")
+	}
+	if agg.Fastest != math.MaxInt64 {
+		t.Error("Fastest should be re-initialized with a big value")
+	}
+	if agg.Slowest != 0 {
+		t.Error("Slowest should be re-initialized with a big value")
+	}
+	if metric.firstRequestTime != 0 {
+		t.Error("firstRequestTime should be reset")
+	}
+	if metric.lastRequestTime != 0 {
+		t.Error("lastRequestTime should be reset")
+	}
+	if metric.requestCountSinceLastSend != 0 {
+		t.Error("requestCuntSinceLastSend should be reset")
+	}
+	if metric.requestTimeTotal != 0 {
+		t.Error("requestTimeTotal should be reset")
+	}
+	if metric.timeToFirstTotal != 0 {
+		t.Error("timeToFirstTotal should be reset")
+	}
+	if agg.BytesRead != 0 {
+		t.Error("totalBytesRead should be reset")
+	}
+}
+
+func TestMetricsAggregate(t *testing.T) {
+	bytes := 1000
+	elapsedFirst := int64(100)
+	elapsedLast := int64(300)
+
+	metric := NewRequestMetric("us-east-1", 0)
+	result := &requestResult{
+		Time:             10000000,
+		Elapsed:          10000000,
+		ElapsedFirstByte: elapsedFirst,
+		ElapsedLastByte:  elapsedLast,
+		Bytes:            bytes,
+		Timeout:          false,
+		ConnectionError:  false,
+	}
+	metric.aggregate()
+	agg := metric.aggregatedResults
+	if agg.AveTimeToFirst != 0 {
+		t.Errorf("should result in 0, but was: %d", agg.AveTimeToFirst)
+	}
+	if agg.AveTimeForReq != 0 {
+		t.Errorf("should result in 0, but was: %d", agg.AveTimeForReq)
+	}
+	for i := 0; i < 10; i++ {
+		result.Time += 10000000
+		metric.addRequest(result)
+	}
+	metric.aggregate()
+	if agg.AveTimeToFirst != 100 {
+		t.Errorf("should result in 100 but was %d", agg.AveTimeToFirst)
+	}
+	if agg.AveTimeForReq != 300 {
+		t.Errorf("should result in 30 but was %d", agg.AveTimeForReq)
+	}
+	if agg.FatalError != "" {
+		t.Errorf("there should be no fatal error but received: %s", agg.FatalError)
+	}
+	metric.fatalError = "aborted"
+	metric.addRequest(result)
+	if agg.FatalError != "aborted" {
+		t.Errorf("the abort reason should be reported but received: %s", agg.FatalError)
+	}
+}
+
+func TestAbortMonitorErrorRatio(t *testing.T) {
+	monitor := newAbortMonitor(types.AbortPolicy{ErrorRatio: 0.5, MinSamples: 20})
+	ok := &requestResult{Status: 200}
+	failed := &requestResult{Timeout: true}
+	for i := 0; i < 10; i++ {
+		if reason := monitor.check(ok); reason != "" {
+			t.Fatal("should not abort on successful requests: ", reason)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if reason := monitor.check(failed); reason != "" {
+			t.Fatal("should not abort at 50% failed requests: ", reason)
+		}
+	}
+	if reason := monitor.check(failed); reason != "11 of 21 requests failed (over 50%), aborting" {
+		t.Error("should abort above 50% failed requests but received: ", reason)
+	}
+}
+
+func TestAbortMonitorMinSamples(t *testing.T) {
+	monitor := newAbortMonitor(types.AbortPolicy{ErrorRatio: 0.5, MinSamples: 5})
+	for i := 0; i < 4; i++ {
+		if reason := monitor.check(&requestResult{ConnectionError: true}); reason != "" {
+			t.Fatal("should not abort before the minimum sample size: ", reason)
+		}
+	}
+	if reason := monitor.check(&requestResult{ConnectionError: true}); reason == "" {
+		t.Error("should abort once the minimum sample size is reached")
+	}
+}
+
+func TestAbortMonitorConsecutiveTimeoutsAndStatuses(t *testing.T) {
+	monitor := newAbortMonitor(types.AbortPolicy{ConsecutiveTimeouts: 3, Statuses: []int{429}})
+	for _, r := range []*requestResult{{Timeout: true}, {Timeout: true}, {Status: 200}, {Timeout: true}, {Timeout: true}} {
+		if reason := monitor.check(r); reason != "" {
+			t.Fatal("should only count timeouts in a row: ", reason)
+		}
+	}
+	if reason := monitor.check(&requestResult{Timeout: true}); reason != "3 consecutive requests timed out, aborting" {
+		t.Error("should abort after 3 timeouts in a row but received: ", reason)
+	}
+	if reason := newAbortMonitor(types.AbortPolicy{Statuses: []int{429}}).check(&requestResult{Status: 429}); reason != "Received status 429, aborting" {
+		t.Error("should abort on status 429 but received: ", reason)
+	}
+}
+
+func TestRunLoadTestAbortsOnStatus(t *testing.T) {
+	server := createAndStartTestServerWithHandler(&statusHandler{Status: 503})
+	defer server.Stop()
+
+	resultSender := &TestResultSender{}
+	settings := LambdaSettings{
+		MaxRequestCount:    0,
+		ConcurrencyCount:   5,
+		StresstestTimeout:  10,
+		ReportingFrequency: time.Second,
+		ClientTimeout:      time.Second,
+		AbortPolicy:        types.AbortPolicy{Statuses: []int{503}},
+		RequestParameters: requestParameters{
+			URL: urlStr,
+		},
+	}
+	lambda := testLambda(t, settings)
+	lambda.resultSender = resultSender
+	start := time.Now()
+	lambda.runLoadTest()
+	if time.Since(start) > 5*time.Second {
+		t.Error("workers should stop when the abort policy triggers")
+	}
+	last := resultSender.sentResults[len(resultSender.sentResults)-1]
+	if last.FatalError != "Received status 503, aborting" || !last.Finished {
+		t.Error("the abort reason should be reported with the final result: ", last.FatalError)
+	}
+}
+
+func TestRunLoadTestStopsOnControl(t *testing.T) {
+	server := createAndStartTestServer()
+	defer server.Stop()
+
+	resultSender := &TestResultSender{}
+	settings := LambdaSettings{
+		MaxRequestCount:    0,
+		ConcurrencyCount:   2,
+		StresstestTimeout:  20,
+		ReportingFrequency: time.Second,
+		ClientTimeout:      time.Second,
+		RequestParameters: requestParameters{
+			URL: urlStr,
+		},
+	}
+	lambda := testLambda(t, settings)
+	lambda.resultSender = resultSender
+	lambda.control = &testControl{stopAfter: time.Now().Add(time.Second)}
+	start := time.Now()
+	lambda.runLoadTest()
+	if time.Since(start) > 5*time.Second {
+		t.Error("workers should stop once a stop is requested")
+	}
+	last := resultSender.sentResults[len(resultSender.sentResults)-1]
+	if last.FatalError != "" || !last.Finished {
+		t.Error("a stopped test should finish without an error: ", last.FatalError)
+	}
+}
+
+func TestRunLoadTestStopsWhenResultsCanNotBeSent(t *testing.T) {
+	server := createAndStartTestServer()
+	defer server.Stop()
+
+	settings := LambdaSettings{
+		MaxRequestCount:    0,
+		ConcurrencyCount:   2,
+		StresstestTimeout:  20,
+		ReportingFrequency: 100 * time.Millisecond,
+		ClientTimeout:      time.Second,
+		RequestParameters: requestParameters{
+			URL: urlStr,
+		},
+	}
+	lambda := testLambda(t, settings)
+	lambda.resultSender = &TestResultSender{err: errors.New("queue closed")}
+	start := time.Now()
+	err := lambda.runLoadTest()
+	if time.Since(start) > 5*time.Second {
+		t.Error("workers should stop once the results can not be sent")
+	}
+	if err == nil || err.Error() != "failed to send results: queue closed" {
+		t.Error("the send failure should be returned: ", err)
+	}
+}
+
+func TestRunLocalReturnsDataFileFailure(t *testing.T) {
+	config := api.NewRunnerConfig()
+	config.URL = urlStr
+	config.Method = "GET"
+	config.Requests = 1
+	config.Concurrency = 1
+	config.ResultsURL = "mem://goad-data-file-failure"
+	config.DataFile = "testdata/missing.csv"
+	err := RunLocal(config, &testControl{})
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to load data file: ") {
+		t.Error("a missing data file should be returned: ", err)
+	}
+}
+
+func TestRunLocalStopsRequestsOnceTimeLimitIsReached(t *testing.T) {
+	handler := &countingHandler{}
+	server := createAndStartTestServerWithHandler(handler)
+	defer server.Stop()
+	receiver, err := transport.NewReceiver("mem://goad-time-limit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+	go func() {
+		for {
+			receiver.Receive()
+		}
+	}()
+
+	config := api.NewRunnerConfig()
+	config.URL = urlStr
+	config.Method = "GET"
+	config.Concurrency = 5
+	config.Rate = 50
+	config.ExecutionTime = 1
+	config.ReportingFrequency = time.Second
+	config.ClientTimeout = time.Second
+	config.ResultsURL = "mem://goad-time-limit"
+	if err := RunLocal(config, &testControl{stopAfter: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	sent := handler.count()
+	time.Sleep(300 * time.Millisecond)
+	if sent == 0 || handler.count() != sent {
+		t.Errorf("no requests should be sent once the runner returned, %d were sent before and %d after", sent, handler.count()-sent)
+	}
+}
+
+// countingHandler counts the requests it served, it is safe for concurrent
+// use
+type countingHandler struct {
+	requests int64
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&h.requests, 1)
+	fmt.Fprintf(w, "Hello, %q", html.EscapeString(r.URL.Path))
+}
+
+func (h *countingHandler) count() int64 {
+	return atomic.LoadInt64(&h.requests)
+}
+
+type testControl struct {
+	stopAfter time.Time
+}
+
+func (c *testControl) StopRequested() bool {
+	return time.Now().After(c.stopAfter)
+}
+
+type statusHandler struct {
+	Status int
+}
+
+func (h *statusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(h.Status)
+}
+
+type TestResultSender struct {
+	sentResults []api.RunnerResult
+	err         error
+}
+
+func (s *TestResultSender) SendResult(data api.RunnerResult) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sentResults = append(s.sentResults, data)
+	return nil
+}
+
+// testLambda creates a runner and fails the test if that is not possible
+func testLambda(t *testing.T, settings LambdaSettings) *goadLambda {
+	lambda, err := newLambda(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lambda
+}
+
+type mockLambdaClient struct {
+	lambdaiface.LambdaAPI
+	input          *api.RunnerConfig
+	function       string
+	invocationType string
+}
+
+func (m *mockLambdaClient) Invoke(in *lambda.InvokeInput) (*lambda.InvokeOutput, error) {
+	args := &api.RunnerConfig{}
+	json.Unmarshal(in.Payload, args)
+	m.input = args
+	m.function = aws.StringValue(in.FunctionName)
+	m.invocationType = aws.StringValue(in.InvocationType)
+	return &lambda.InvokeOutput{}, nil
+}
+
+func TestQuitOnLambdaTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	handler := &delayRequstHandler{
+		DelayMilliseconds: 400,
+	}
+	server := &testServer{
+		Handler: handler,
+	}
+	server.Start()
+	defer server.Stop()
+
+	reportingFrequency := time.Duration(5) * time.Second
+	settings := LambdaSettings{
+		MaxRequestCount:    5,
+		ConcurrencyCount:   1,
+		ReportingFrequency: reportingFrequency,
+		StresstestTimeout:  10,
+		LambdaRegion:       "us-east-1",
+	}
+	settings.RequestParameters.URL = urlStr
+	sender := &TestResultSender{}
+	lambda := testLambda(t, settings)
+	lambda.resultSender = sender
+	mockClient := &mockLambdaClient{}
+	lambda.lambdaService = mockClient
+	function := &lambdaTestFunction{
+		lambda: lambda,
+	}
+	lambda.Settings.LambdaExecTimeoutSeconds = 1
+	RunOrFailAfterTimout(t, function, 1400)
+	resLength := len(sender.sentResults)
+	timeoutRemaining := lambda.Settings.StresstestTimeout
+	if timeoutRemaining != 9 {
+		t.Errorf("we shoud have 9 seconds of stresstest left, actual: %d", timeoutRemaining)
+	}
+	requestCount := lambda.Settings.MaxRequestCount
+	if requestCount != 5 {
+		t.Errorf("the request count of 5 should not have changed, actual: %d", requestCount)
+	}
+	if !workersReturned(lambda) {
+		t.Error("the workers should have returned with the runner")
+	}
+	if resLength != 1 {
+		t.Errorf("We should have received exactly 1 result but got %d instead.", resLength)
+		t.FailNow()
+	}
+	if sender.sentResults[0].Finished == true {
+		t.Error("lambda should not have finished all it's requests")
+	}
+	if !sender.sentResults[0].Forked {
+		t.Error("the last result should report the fork")
+	}
+	if mockClient.invocationType != "Event" {
+		t.Errorf("the fork should be invoked asynchronously, got %q", mockClient.invocationType)
+	}
+	// the request in flight at the timeout is completed
+	reqs := sender.sentResults[0].RequestCount
+	if reqs != 3 {
+		t.Errorf("should have completed 3 requests yet but registered %d.", reqs)
+	}
+}
+
+// workersReturned reports whether all workers and schedulers of the runner
+// have returned
+func workersReturned(lambda *goadLambda) bool {
+	returned := make(chan struct{})
+	go func() {
+		lambda.wg.Wait()
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		return true
+	case <-time.After(100 * time.Millisecond):
+		return false
+	}
+}
+
+func TestMetricSendResults(t *testing.T) {
+	bytes := 1024
+	elapsedFirst := int64(100)
+	elapsedLast := int64(300)
+	result := &requestResult{
+		Time:             400,
+		ElapsedFirstByte: elapsedFirst,
+		ElapsedLastByte:  elapsedLast,
+		Bytes:            bytes,
+		Timeout:          false,
+		ConnectionError:  false,
+	}
+
+	metric := NewRequestMetric("us-east-1", 0)
+	sender := &TestResultSender{}
+
+	metric.addRequest(result)
+	metric.sendAggregatedResults(sender)
+	if len(sender.sentResults) != 1 {
+		t.Error("sender should have received one item")
+		t.FailNow()
+	}
+}
+
+func TestRunLoadTestWithHighConcurrency(t *testing.T) {
+	server := createAndStartTestServer()
+	defer server.Stop()
+
+	runLoadTestWithDefaultExecTimeout(t, 500, 100, 500)
+}
+
+func TestRunLoadTestWithOneRequest(t *testing.T) {
+	server := createAndStartTestServer()
+	defer server.Stop()
+
+	runLoadTestWithDefaultExecTimeout(t, 1, -1, 50)
+}
+
+func TestRunLoadTestWithZeroRequests(t *testing.T) {
+	server := createAndStartTestServer()
+	defer server.Stop()
+
+	runLoadTestWithDefaultExecTimeout(t, 1, 1, 120)
+}
+
+func TestRunWithExecTimeout(t *testing.T) {
+	server := createAndStartTestServer()
+	defer server.Stop()
+
+	runLoadTestWith(t, 0, 1, 1, 1050)
+}
+
+func TestRunLoadTestWithRate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	server := createAndStartTestServer()
+	defer server.Stop()
+
+	settings := LambdaSettings{
+		MaxRequestCount:    20,
+		ConcurrencyCount:   5,
+		Rate:               100,
+		ReportingFrequency: time.Duration(5) * time.Second,
+	}
+	settings.RequestParameters.URL = urlStr
+	sender := &TestResultSender{}
+	lambda := testLambda(t, settings)
+	lambda.resultSender = sender
+	start := time.Now()
+	RunOrFailAfterTimout(t, &lambdaTestFunction{lambda: lambda}, 1000)
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("20 requests at 100/s should take about 200ms, took %s", elapsed)
+	}
+	if len(sender.sentResults) != 1 {
+		t.Fatalf("sender should have received one item, got %d", len(sender.sentResults))
+	}
+	results := sender.sentResults[0]
+	if results.RequestCount != 20 {
+		t.Errorf("expected 20 requests, got %d completed and %d dropped", results.RequestCount, results.Dropped)
+	}
+}
+
+func TestDroppedSendsDoNotUseUpRequests(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	server := createAndStartTestServerWithHandler(&delayRequstHandler{DelayMilliseconds: 50})
+	defer server.Stop()
+
+	settings := LambdaSettings{
+		MaxRequestCount:    5,
+		ConcurrencyCount:   1,
+		Rate:               100,
+		ReportingFrequency: time.Duration(5) * time.Second,
+		ClientTimeout:      time.Second,
+	}
+	settings.RequestParameters.URL = urlStr
+	sender := &TestResultSender{}
+	lambda := testLambda(t, settings)
+	lambda.resultSender = sender
+	RunOrFailAfterTimout(t, &lambdaTestFunction{lambda: lambda}, 2000)
+	if len(sender.sentResults) != 1 {
+		t.Fatalf("sender should have received one item, got %d", len(sender.sentResults))
+	}
+	results := sender.sentResults[0]
+	if results.RequestCount != 5 || results.Dropped == 0 {
+		t.Errorf("expected 5 requests and dropped sends while the request in flight takes longer than the interval, got %d requests and %d dropped", results.RequestCount, results.Dropped)
+	}
+}
+
+func TestScheduleContinuesFromNowWhenBehind(t *testing.T) {
+	start := time.Now()
+	interval := 100 * time.Millisecond
+	if next, missed := catchUp(start, start.Add(50*time.Millisecond), interval); next != start || missed != 0 {
+		t.Errorf("a send less than an interval late is due as scheduled, got %s and %d missed", next.Sub(start), missed)
+	}
+	now := start.Add(350 * time.Millisecond)
+	if next, missed := catchUp(start, now, interval); next != now || missed != 3 {
+		t.Errorf("a stalled schedule should continue from now and count 3 missed sends, got %s and %d missed", next.Sub(start), missed)
+	}
+}
+
+func TestRunLoadTestWithStages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	server := createAndStartTestServer()
+	defer server.Stop()
+
+	settings := LambdaSettings{
+		ConcurrencyCount:   4,
+		ReportingFrequency: time.Duration(5) * time.Second,
+		Stages: []types.Stage{
+			{Duration: 200 * time.Millisecond, Target: 4},
+			{Duration: 200 * time.Millisecond, Target: 0},
+		},
+	}
+	settings.RequestParameters.URL = urlStr
+	sender := &TestResultSender{}
+	lambda := testLambda(t, settings)
+	lambda.resultSender = sender
+	start := time.Now()
+	RunOrFailAfterTimout(t, &lambdaTestFunction{lambda: lambda}, 1500)
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("the load profile should have taken at least 400ms, took %s", elapsed)
+	}
+	if len(sender.sentResults) != 1 {
+		t.Fatalf("sender should have received one item, got %d", len(sender.sentResults))
+	}
+	if !sender.sentResults[0].Finished {
+		t.Error("the lambda should have finished after the last stage")
+	}
+	if sender.sentResults[0].RequestCount == 0 {
+		t.Error("the workers should have made requests during the stages")
+	}
+}
+
+func TestStageOffsetIsPassedToFork(t *testing.T) {
+	settings := LambdaSettings{
+		StageOffset: time.Minute,
+		Stages:      []types.Stage{{Duration: 2 * time.Minute, Target: 10}},
+	}
+	lambda := testLambda(t, settings)
+	lambda.StartTime = time.Now().Add(-10 * time.Second)
+	config := lambda.getRunnerConfigForFork()
+	if len(config.Stages) != 1 || config.Stages[0] != "2m0s:10" {
+		t.Errorf("forked lambda should receive the stages, got %v", config.Stages)
+	}
+	if config.StageOffset < 70*time.Second {
+		t.Errorf("expected a stage offset of at least 70s, got %s", config.StageOffset)
+	}
+}
+
+func TestForkKeepsRunnerConfig(t *testing.T) {
+	config := api.NewRunnerConfig()
+	config.URL = urlStr
+	config.Headers = []string{"Authorization: Bearer secret"}
+	config.Assertions = []string{"status:200"}
+	config.ResultsURL = "mem://fork"
+	config.Requests = 100
+	config.ExecutionTime = 600
+	settings, err := settingsFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lambda := testLambda(t, settings)
+	lambda.Settings.CompletedJobCount = 40
+	lambda.feeder, err = templating.NewFeeder(strings.NewReader("id\n1\n2\n3\n"), templating.Unique, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lambda.feeder.Next()
+	lambda.feeder.Next()
+	fork := lambda.getRunnerConfigForFork()
+	if fork.FeederPosition != 2 {
+		t.Error("forked lambda should continue with the next row, got ", fork.FeederPosition)
+	}
+	if fork.Version != api.RunnerConfigVersion || fork.ResultsURL != "mem://fork" {
+		t.Error("forked lambda should receive a valid configuration, got ", fork)
+	}
+	if len(fork.Headers) != 1 || fork.Headers[0] != "Authorization: Bearer secret" {
+		t.Error("forked lambda should receive the headers, got ", fork.Headers)
+	}
+	if len(fork.Assertions) != 1 || fork.CompletedRequests != 40 {
+		t.Error("forked lambda should continue the test, got ", fork)
+	}
+	if err := fork.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestForkInvokesConfiguredFunction(t *testing.T) {
+	config := api.NewRunnerConfig()
+	config.URL = urlStr
+	config.ResultsURL = "mem://fork"
+	config.FunctionName = "team-a"
+	config.FunctionTimeout = 900
+	settings, err := settingsFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lambda := testLambda(t, settings)
+	if lambda.Settings.LambdaExecTimeoutSeconds != 895 {
+		t.Error("runner should fork shortly before the function timeout, got ", lambda.Settings.LambdaExecTimeoutSeconds)
+	}
+	mockClient := &mockLambdaClient{}
+	lambda.lambdaService = mockClient
+	lambda.out = ioutil.Discard
+	lambda.forkNewLambda()
+	if mockClient.function != "team-a" {
+		t.Error("forked lambda should be invoked on the configured function, got ", mockClient.function)
+	}
+	if mockClient.input.FunctionName != "team-a" || mockClient.input.FunctionTimeout != 900 {
+		t.Error("forked lambda should receive the function settings, got ", mockClient.input)
+	}
+}
+
+func TestForkDefaultsToGoadFunction(t *testing.T) {
+	config := api.NewRunnerConfig()
+	config.URL = urlStr
+	config.ResultsURL = "mem://fork"
+	settings, err := settingsFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lambda := testLambda(t, settings)
+	if lambda.Settings.FunctionName != "goad" || lambda.Settings.LambdaExecTimeoutSeconds != AWS_MAX_TIMEOUT {
+		t.Error("configurations without function settings should keep the former defaults, got ", lambda.Settings)
+	}
+}
+
+type loginHandler struct {
+	authorized int
+}
+
+func (h *loginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/login" {
+		fmt.Fprint(w, `{"token": "secret"}`)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	h.authorized++
+	fmt.Fprint(w, "ok")
+}
+
+func TestRunScenario(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	handler := &loginHandler{}
+	server := createAndStartTestServerWithHandler(handler)
+	defer server.Stop()
+
+	settings := LambdaSettings{
+		MaxRequestCount:    3,
+		ConcurrencyCount:   1,
+		ReportingFrequency: time.Duration(5) * time.Second,
+		Scenario: &scenario.Scenario{Steps: []scenario.Step{
+			{Name: "login", URL: "/login", Extract: []scenario.Extraction{{Name: "token", From: scenario.FromJSON, Expression: "token"}}},
+			{Name: "api", URL: "/api", Headers: []string{"Authorization: Bearer ${token}"}},
+		}},
+	}
+	settings.RequestParameters.URL = urlStr
+	sender := &TestResultSender{}
+	lambda := testLambda(t, settings)
+	lambda.resultSender = sender
+	RunOrFailAfterTimout(t, &lambdaTestFunction{lambda: lambda}, 1000)
+	if handler.authorized != 3 {
+		t.Errorf("every iteration should call the api with the extracted token, got %d authorized calls", handler.authorized)
+	}
+	if lambda.Settings.CompletedJobCount != 3 || lambda.Settings.CompletedRequestCount != 6 {
+		t.Errorf("expected 3 iterations of 2 requests, got %d iterations and %d requests", lambda.Settings.CompletedJobCount, lambda.Settings.CompletedRequestCount)
+	}
+	if len(sender.sentResults) != 1 {
+		t.Fatalf("sender should have received one item, got %d", len(sender.sentResults))
+	}
+	breakdown := sender.sentResults[0].Breakdown
+	if breakdown["login"].RequestCount != 3 || breakdown["api"].Statuses["200"] != 3 {
+		t.Errorf("expected 3 requests per step, got %+v and %+v", breakdown["login"], breakdown["api"])
+	}
+}
+
+func TestRunRequestMix(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	server := createAndStartTestServer()
+	defer server.Stop()
+
+	settings := LambdaSettings{
+		MaxRequestCount:    50,
+		ConcurrencyCount:   2,
+		ReportingFrequency: time.Duration(5) * time.Second,
+		Requests: []requestfile.Request{
+			{Name: "home", Method: "GET", URL: "/"},
+			{Name: "search", Method: "GET", URL: "/search"},
+		},
+	}
+	settings.RequestParameters.URL = urlStr
+	sender := &TestResultSender{}
+	lambda := testLambda(t, settings)
+	lambda.resultSender = sender
+	RunOrFailAfterTimout(t, &lambdaTestFunction{lambda: lambda}, 1000)
+	if len(sender.sentResults) != 1 {
+		t.Fatalf("sender should have received one item, got %d", len(sender.sentResults))
+	}
+	breakdown := sender.sentResults[0].Breakdown
+	if breakdown["home"].RequestCount+breakdown["search"].RequestCount != 50 {
+		t.Errorf("expected 50 requests split between home and search, got %+v", breakdown)
+	}
+}
+
+func runLoadTestWithDefaultExecTimeout(t *testing.T, requestCount int, concurrency int, milliseconds int) {
+	runLoadTestWith(t, requestCount, 0, concurrency, milliseconds)
+}
+
+func runLoadTestWith(t *testing.T, requestCount int, stresstestTimeout int, concurrency int, milliseconds int) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	reportingFrequency := time.Duration(5) * time.Second
+	settings := LambdaSettings{
+		MaxRequestCount:    requestCount,
+		ConcurrencyCount:   concurrency,
+		ReportingFrequency: reportingFrequency,
+		StresstestTimeout:  stresstestTimeout,
+	}
+	settings.RequestParameters.URL = urlStr
+	sender := &TestResultSender{}
+	lambda := testLambda(t, settings)
+	lambda.resultSender = sender
+	function := &lambdaTestFunction{
+		lambda: lambda,
+	}
+	RunOrFailAfterTimout(t, function, milliseconds)
+	if len(sender.sentResults) != 1 {
+		t.Error("sender should have received one item")
+		t.FailNow()
+	}
+	results := sender.sentResults[0]
+	if results.Finished != true {
+		t.Error("the lambda should have finished it's results")
+	}
+	if results.RequestCount != requestCount && requestCount > 0 {
+		t.Errorf("the lambda generated results for %d request, expected %d", results.RequestCount, requestCount)
+	}
+}
+
+func RunOrFailAfterTimout(t *testing.T, f TestFunction, milliseconds int) {
+	timeout := time.Duration(milliseconds) * time.Millisecond
+	select {
+	case <-time.After(timeout):
+		t.Error("Test is stuck")
+		t.FailNow()
+	case <-func() chan bool {
+		quit := make(chan bool)
+		go func() {
+			f.Run()
+			quit <- true
+		}()
+		return quit
+	}():
+	}
+}
+
+type lambdaTestFunction struct {
+	lambda *goadLambda
+}
+
+type TestFunction interface {
+	Run()
+}
+
+func (a *lambdaTestFunction) Run() {
+	a.lambda.runLoadTest()
+}
+
+func TestFetchSuccess(t *testing.T) {
+	handler := &requestCountHandler{}
+	server := createAndStartTestServerWithHandler(handler)
+	defer server.Stop()
+
+	// setup for the fetch function
+	expectedRequestCount := 1
+	client := &http.Client{}
+	r := requestParameters{
+		URL: urlStr,
+	}
+	result := requestResult{}
+	for result.State != "Success" {
+		result = fetch(client, r, time.Now())
+	}
+	if handler.RequestCount != expectedRequestCount {
+		t.Error("Did not receive exactly one request, received: ", handler.RequestCount)
+	}
+}
+
+func TestFetchRecordsPhases(t *testing.T) {
+	handler := &delayRequstHandler{DelayMilliseconds: 50}
+	server := createAndStartTestServerWithHandler(handler)
+	defer server.Stop()
+
+	client := &http.Client{}
+	r := requestParameters{
+		URL: urlStr,
+	}
+	first := fetch(client, r, time.Now())
+	if first.State != "Success" {
+		t.Fatal("Request failed: ", first.State)
+	}
+	if first.Connect <= 0 {
+		t.Error("New connection did not record a connect time")
+	}
+	if first.TLS != 0 {
+		t.Error("Plain HTTP request recorded a TLS handshake: ", first.TLS)
+	}
+	if first.Server < (50*time.Millisecond).Nanoseconds() || first.Server > first.ElapsedFirstByte {
+		t.Error("Server time should include the handler delay, got: ", first.Server)
+	}
+
+	second := fetch(client, r, time.Now())
+	if second.DNS != 0 || second.Connect != 0 {
+		t.Error("Reused connection recorded DNS or connect time: ", second.DNS, second.Connect)
+	}
+	if second.Server <= 0 {
+		t.Error("Reused connection did not record server time")
+	}
+}
+
+func TestFetchChecksAssertions(t *testing.T) {
+	server := createAndStartTestServer()
+	defer server.Stop()
+
+	client := &http.Client{}
+	assertions, err := assertion.ParseAll([]string{"status:200", "body-contains:Hello", "json:$.status=ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := requestParameters{
+		URL:        urlStr,
+		Assertions: assertions,
+	}
+	result, response := fetchResponse(client, r, time.Now(), false)
+	if result.State != "Success" {
+		t.Fatal("Request failed: ", result.State)
+	}
+	if len(result.FailedAssertions) != 1 || result.FailedAssertions[0] != "json:$.status=ok" {
+		t.Error("Expected only the JSON assertion to fail, failed: ", result.FailedAssertions)
+	}
+	if response != nil {
+		t.Error("Response should only be returned when capture is requested")
+	}
+
+	metric := NewRequestMetric("eu-west-1", 0)
+	metric.addRequest(&result)
+	metric.addRequest(&requestResult{Status: 500, FailedAssertions: []string{"status:200"}})
+	agg := metric.aggregatedResults
+	if agg.FailedAssertions["json:$.status=ok"] != 1 || agg.FailedAssertions["status:200"] != 1 {
+		t.Error("Failed assertions not counted by spec: ", agg.FailedAssertions)
+	}
+	if agg.FailedAssertionRequests != 1 {
+		t.Error("Only successful statuses with failed assertions should add errors, got: ", agg.FailedAssertionRequests)
+	}
+}
+
+func TestAddRequestPhases(t *testing.T) {
+	metric := NewRequestMetric("eu-west-1", 0)
+	metric.addRequest(&requestResult{Status: 200, Connect: 300, Server: 1000, Transfer: 10})
+	metric.addRequest(&requestResult{Status: 200, Server: 3000, Transfer: 30})
+	phases := metric.aggregatedResults.Phases
+	if phases.Connect != (api.PhaseStats{Total: 300, Count: 1}) {
+		t.Error("Connect phase should only count requests that connected: ", phases.Connect)
+	}
+	if phases.Server != (api.PhaseStats{Total: 4000, Count: 2}) || phases.Server.Mean() != 2000 {
+		t.Error("Unexpected server phase: ", phases.Server)
+	}
+	if phases.TLS.Mean() != 0 {
+		t.Error("TLS phase without handshakes should average to zero")
+	}
+}
+
+func createAndStartTestServer() *testServer {
+	handler := &requestCountHandler{}
+	server := createAndStartTestServerWithHandler(handler)
+	return server
+}
+
+func createAndStartTestServerWithHandler(handler http.Handler) *testServer {
+	server := &testServer{
+		Handler: handler,
+	}
+	server.Start()
+	return server
+}
+
+type testServer struct {
+	Handler    http.Handler
+	Listener   net.Listener
+	HTTPServer http.Server
+	wg         sync.WaitGroup
+}
+
+type requestCountHandler struct {
+	RequestCount int
+}
+
+func (h *requestCountHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.RequestCount++
+	fmt.Fprintf(w, "Hello, %q", html.EscapeString(r.URL.Path))
+}
+
+type delayRequstHandler struct {
+	DelayMilliseconds int
+}
+
+func (h *delayRequstHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(time.Duration(h.DelayMilliseconds) * time.Millisecond)
+	fmt.Fprintf(w, "Hello, %q", html.EscapeString(r.URL.Path))
+}
+
+func (s *testServer) Start() {
+	listener, err := net.Listen("tcp", portStr)
+	if err != nil {
+		panic(err)
+	}
+	s.Listener = listener
+	s.HTTPServer = http.Server{
+		Addr:    portStr,
+		Handler: s.Handler,
+	}
+	s.wg.Add(1)
+	go func() {
+		s.HTTPServer.Serve(listener)
+		s.wg.Done()
+	}()
+}
+
+func (s *testServer) Stop() {
+	s.Listener.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.HTTPServer.Shutdown(ctx)
+	s.wg.Wait()
+}
+
+func _TestMultipleFetch(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		TestFetchSuccess(t)
+	}
+}
+
+func TestExtractedValuesAreNotRenderedAsTemplates(t *testing.T) {
+	w := &worker{
+		vars:     map[string]string{"token": `{{uuid}}`},
+		renderer: templating.NewRenderer(nil),
+	}
+	w.renderer.Next(w.vars)
+	step := scenario.Step{
+		URL:     "/api/{{print 42}}?token=${token}",
+		Headers: []string{"Authorization: Bearer ${token}"},
+	}
+	params := w.stepRequestParameters(step, requestParameters{URL: "http://localhost"}, nil)
+	if params.URL != "http://localhost/api/42?token={{uuid}}" {
+		t.Error("the step should be rendered before the extracted value is substituted, got ", params.URL)
+	}
+	if params.RequestHeaders[0] != "Authorization: Bearer {{uuid}}" {
+		t.Error("the extracted value should be sent unchanged, got ", params.RequestHeaders[0])
+	}
+}
+
+func TestAssertionsAreParsedOnce(t *testing.T) {
+	config := api.NewRunnerConfig()
+	config.URL = urlStr
+	config.ResultsURL = "mem://assertions"
+	config.Assertions = []string{"status:2xx"}
+	config.Scenario = &scenario.Scenario{Steps: []scenario.Step{
+		{Name: "home"},
+		{Name: "api", Assert: []string{"body-contains:ok"}},
+	}}
+	settings, err := settingsFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings.stepAssertions(0)) != 1 || len(settings.stepAssertions(1)) != 2 {
+		t.Errorf("every step should check the assertions of the test and its own, got %v", settings.StepAssertions)
+	}
+	if specs := settings.runnerConfig().Assertions; len(specs) != 1 || specs[0] != "status:2xx" {
+		t.Error("forks should receive the assertion specs, got ", specs)
+	}
+
+	config.Scenario.Steps[1].Assert = []string{"latency:1s"}
+	if _, err := settingsFromConfig(config); err == nil {
+		t.Error("an invalid assertion should fail the runner")
+	}
+}
+
+func TestRenderFailureIsReportedOnce(t *testing.T) {
+	var out bytes.Buffer
+	l := &goadLambda{out: &out}
+	w := l.newWorker()
+	w.renderer.Next(w.vars)
+	for i := 0; i < 3; i++ {
+		if rendered := w.renderString(`{{csv "id"}}`); rendered != `{{csv "id"}}` {
+			t.Error("a failing template should be sent unchanged, got ", rendered)
+		}
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 1 {
+		t.Errorf("the failure should be reported once, got %q", out.String())
+	}
+}
@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/goadapp/goad/api"
+)
+
+const (
+	// RuntimeAPIEnv holds the host of the Lambda Runtime API in functions
+	// using a custom runtime
+	RuntimeAPIEnv = "AWS_LAMBDA_RUNTIME_API"
+
+	runtimeAPIVersion = "2018-06-01"
+	requestIDHeader   = "Lambda-Runtime-Aws-Request-Id"
+	runnerErrorType   = "Runner.Error"
+)
+
+// runtimeClient fetches the invocations of a Lambda function from the Runtime
+// API and reports their outcome
+type runtimeClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// runtimeError is the structured error reported for a failed invocation
+type runtimeError struct {
+	ErrorMessage string `json:"errorMessage"`
+	ErrorType    string `json:"errorType"`
+}
+
+func newRuntimeClient(host string) *runtimeClient {
+	return &runtimeClient{
+		baseURL: fmt.Sprintf("http://%s/%s/runtime", host, runtimeAPIVersion),
+		// the next invocation is long polled, so there is no timeout
+		client: &http.Client{},
+	}
+}
+
+// Serve handles the invocations of the Lambda function until the instance is
+// shut down, runtimeAPI is the host of the Runtime API
+func Serve(runtimeAPI string) {
+	rand.Seed(time.Now().UnixNano())
+	c := newRuntimeClient(runtimeAPI)
+	for {
+		if err := c.handleNext(); err != nil {
+			log.Fatalf("Failed to talk to the runtime API: %s", err)
+		}
+	}
+}
+
+// handleNext runs the load test of the next invocation, an error is only
+// returned if the Runtime API could not be reached
+func (c *runtimeClient) handleNext() error {
+	resp, err := c.client.Get(c.baseURL + "/invocation/next")
+	if err != nil {
+		return err
+	}
+	payload, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("next invocation returned status %d", resp.StatusCode)
+	}
+	requestID := resp.Header.Get(requestIDHeader)
+
+	config, err := api.DecodeRunnerConfig(payload)
+	if err == nil {
+		err = run(config)
+	}
+	if err != nil {
+		return c.post("/invocation/"+requestID+"/error", runtimeError{
+			ErrorMessage: err.Error(),
+			ErrorType:    runnerErrorType,
+		})
+	}
+	return c.post("/invocation/"+requestID+"/response", "Process complete!")
+}
+
+func (c *runtimeClient) post(path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if report, ok := body.(runtimeError); ok {
+		req.Header.Set("Lambda-Runtime-Function-Error-Type", report.ErrorType)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("posting to %s returned status %d", path, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goadapp/goad/api"
+	"github.com/goadapp/goad/transport"
+)
+
+// runtimeAPI serves a single invocation and records the reported outcome
+type runtimeAPI struct {
+	payload  string
+	path     string
+	response string
+}
+
+func (a *runtimeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/invocation/next") {
+		w.Header().Set(requestIDHeader, "request-1")
+		fmt.Fprint(w, a.payload)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	a.path = r.URL.Path
+	a.response = string(body)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func handleInvocation(t *testing.T, invocation interface{}) *runtimeAPI {
+	payload, _ := json.Marshal(invocation)
+	handler := &runtimeAPI{payload: string(payload)}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := newRuntimeClient(strings.TrimPrefix(server.URL, "http://"))
+	if err := c.handleNext(); err != nil {
+		t.Fatal(err)
+	}
+	return handler
+}
+
+func TestRuntimeReportsInvalidInvocation(t *testing.T) {
+	handler := handleInvocation(t, map[string]interface{}{"version": api.RunnerConfigVersion + 1})
+	if handler.path != "/2018-06-01/runtime/invocation/request-1/error" {
+		t.Error("an invalid invocation should be reported as error, got ", handler.path)
+	}
+	report := runtimeError{}
+	json.Unmarshal([]byte(handler.response), &report)
+	if report.ErrorType != runnerErrorType || !strings.Contains(report.ErrorMessage, "version") {
+		t.Error("the error should be reported in a structured way, got ", handler.response)
+	}
+}
+
+func TestRuntimeRunsInvocation(t *testing.T) {
+	server := createAndStartTestServer()
+	defer server.Stop()
+	receiver, err := transport.NewReceiver("mem://runtime-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+
+	results := make(chan *api.RunnerResult)
+	go func() {
+		for {
+			for _, result := range receiver.Receive() {
+				if result.Finished {
+					results <- result
+					return
+				}
+			}
+		}
+	}()
+	config := api.NewRunnerConfig()
+	config.URL = urlStr
+	config.Requests = 5
+	config.Concurrency = 1
+	config.ReportingFrequency = time.Second
+	config.ResultsURL = "mem://runtime-test"
+	handler := handleInvocation(t, config)
+	if handler.path != "/2018-06-01/runtime/invocation/request-1/response" {
+		t.Error("a successful invocation should be reported as response, got ", handler.path)
+	}
+	if result := <-results; result.RequestCount != 5 {
+		t.Error("the results should be sent through the transport, got ", result.RequestCount)
+	}
+}
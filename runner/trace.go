@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// phaseTimer records when the connection phases of a single request start and
+// end. Dialing may report from other goroutines, so all marks are guarded.
+// Only the first occurrence of every event is kept, additional dial attempts
+// do not move the marks.
+type phaseTimer struct {
+	sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+}
+
+func (t *phaseTimer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mark(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mark(&t.dnsDone)
+		},
+		ConnectStart: func(network, addr string) {
+			t.mark(&t.connectStart)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				t.mark(&t.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mark(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				t.mark(&t.tlsDone)
+			}
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				t.mark(&t.wroteRequest)
+			}
+		},
+		GotFirstResponseByte: func() {
+			t.mark(&t.firstByte)
+		},
+	}
+}
+
+func (t *phaseTimer) mark(at *time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+// apply stores the phase durations in the result, phases which did not happen
+// (eg. DNS and connect on a reused connection) stay zero. lastByte is zero if
+// the body was not read.
+func (t *phaseTimer) apply(result *requestResult, lastByte time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	result.DNS = between(t.dnsStart, t.dnsDone)
+	result.Connect = between(t.connectStart, t.connectDone)
+	result.TLS = between(t.tlsStart, t.tlsDone)
+	result.Server = between(t.wroteRequest, t.firstByte)
+	result.Transfer = between(t.firstByte, lastByte)
+}
+
+func between(start, end time.Time) int64 {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start).Nanoseconds()
+}
@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"net/url"
+
+	"github.com/goadapp/goad/api"
+	"github.com/streadway/amqp"
+)
+
+// amqpQueue is the name of the RabbitMQ queue for the results
+const amqpQueue = "goad"
+
+// amqpTransport sends and receives results through a RabbitMQ queue
+type amqpTransport struct {
+	conn     *amqp.Connection
+	ch       *amqp.Channel
+	q        amqp.Queue
+	messages chan []byte
+}
+
+func dialAMQP(u *url.URL) (*amqpTransport, error) {
+	conn, err := amqp.Dial(u.String())
+	if err != nil {
+		return nil, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	q, err := ch.QueueDeclare(
+		amqpQueue, // name
+		false,     // durable
+		false,     // delete when unused
+		false,     // exclusive
+		false,     // no-wait
+		nil,       // arguments
+	)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &amqpTransport{conn: conn, ch: ch, q: q}, nil
+}
+
+func newAMQPSender(u *url.URL, _ options) (Sender, error) {
+	return dialAMQP(u)
+}
+
+func newAMQPReceiver(u *url.URL, _ options) (Receiver, error) {
+	t, err := dialAMQP(u)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := t.ch.Consume(
+		t.q.Name, // queue
+		"cli",    // consumer
+		true,     // auto-ack
+		false,    // exclusive
+		false,    // no-local
+		false,    // no-wait
+		nil,      // args
+	)
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	t.messages = make(chan []byte)
+	go func() {
+		defer close(t.messages)
+		for delivery := range deliveries {
+			t.messages <- delivery.Body
+		}
+	}()
+	return t, nil
+}
+
+// SendResult publishes a result to the queue
+func (t *amqpTransport) SendResult(result api.RunnerResult) error {
+	message, err := Encode(result)
+	if err != nil {
+		return err
+	}
+	return t.ch.Publish(
+		"",       // exchange
+		t.q.Name, // routing key
+		false,    // mandatory
+		false,    // immediate
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        message,
+		})
+}
+
+func (t *amqpTransport) Receive() []*api.RunnerResult {
+	return receiveBatch(t.messages)
+}
+
+func (t *amqpTransport) Close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,108 @@
+package transport
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/goadapp/goad/api"
+)
+
+// httpTransport posts every result to the URL
+type httpTransport struct {
+	client *http.Client
+	url    string
+}
+
+func newHTTPSender(u *url.URL, _ options) (Sender, error) {
+	return &httpTransport{&http.Client{Timeout: 10 * time.Second}, u.String()}, nil
+}
+
+func (t *httpTransport) SendResult(result api.RunnerResult) error {
+	message, err := Encode(result)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Post(t.url, "application/json", bytes.NewReader(message))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("posting the result to %s failed with status %d", t.url, resp.StatusCode)
+	}
+	return nil
+}
+
+func (t *httpTransport) Close() error {
+	return nil
+}
+
+// httpReceiver listens on the address of the URL and accepts results posted
+// to its path
+type httpReceiver struct {
+	server   *http.Server
+	listener net.Listener
+	messages chan []byte
+	closed   chan struct{}
+}
+
+func newHTTPReceiver(u *url.URL, _ options) (Receiver, error) {
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("receiving results over %s is not supported", u.Scheme)
+	}
+	listener, err := net.Listen("tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	r := &httpReceiver{
+		listener: listener,
+		messages: make(chan []byte),
+		closed:   make(chan struct{}),
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, r.handle)
+	r.server = &http.Server{Handler: mux}
+	go r.server.Serve(listener)
+	return r, nil
+}
+
+func (r *httpReceiver) handle(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	message, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	select {
+	case r.messages <- message:
+		w.WriteHeader(http.StatusNoContent)
+	case <-r.closed:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// Addr returns the address the receiver listens on
+func (r *httpReceiver) Addr() net.Addr {
+	return r.listener.Addr()
+}
+
+func (r *httpReceiver) Receive() []*api.RunnerResult {
+	return receiveBatch(r.messages)
+}
+
+func (r *httpReceiver) Close() error {
+	close(r.closed)
+	return r.server.Close()
+}
@@ -0,0 +1,88 @@
+package transport
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/goadapp/goad/api"
+)
+
+// memQueue passes results between runners and the cli in the same process
+type memQueue struct {
+	messages chan []byte
+	closed   chan struct{}
+	once     sync.Once
+}
+
+var (
+	memQueuesMutex sync.Mutex
+	memQueues      = make(map[string]*memQueue)
+)
+
+// newMemReceiver registers the queue named by the host of the URL, senders
+// can only be created while the receiver is open
+func newMemReceiver(u *url.URL, _ options) (Receiver, error) {
+	memQueuesMutex.Lock()
+	defer memQueuesMutex.Unlock()
+	if _, ok := memQueues[u.Host]; ok {
+		return nil, fmt.Errorf("queue %q is already received from", u.Host)
+	}
+	q := &memQueue{
+		messages: make(chan []byte),
+		closed:   make(chan struct{}),
+	}
+	memQueues[u.Host] = q
+	return &memReceiver{q, u.Host}, nil
+}
+
+func newMemSender(u *url.URL, _ options) (Sender, error) {
+	memQueuesMutex.Lock()
+	defer memQueuesMutex.Unlock()
+	q, ok := memQueues[u.Host]
+	if !ok {
+		return nil, fmt.Errorf("no receiver for queue %q", u.Host)
+	}
+	return &memSender{q}, nil
+}
+
+type memSender struct {
+	q *memQueue
+}
+
+// SendResult waits for the receiver to take the result, results sent after
+// the receiver was closed are dropped
+func (s *memSender) SendResult(result api.RunnerResult) error {
+	message, err := Encode(result)
+	if err != nil {
+		return err
+	}
+	select {
+	case s.q.messages <- message:
+	case <-s.q.closed:
+	}
+	return nil
+}
+
+func (s *memSender) Close() error {
+	return nil
+}
+
+type memReceiver struct {
+	q    *memQueue
+	name string
+}
+
+func (r *memReceiver) Receive() []*api.RunnerResult {
+	return receiveBatch(r.q.messages)
+}
+
+func (r *memReceiver) Close() error {
+	r.q.once.Do(func() {
+		memQueuesMutex.Lock()
+		delete(memQueues, r.name)
+		memQueuesMutex.Unlock()
+		close(r.q.closed)
+	})
+	return nil
+}
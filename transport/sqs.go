@@ -0,0 +1,144 @@
+package transport
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/goadapp/goad/api"
+	uuid "github.com/satori/go.uuid"
+)
+
+// sqsTransport sends and receives results through an SQS queue
+type sqsTransport struct {
+	Client   *sqs.SQS
+	QueueURL string
+}
+
+// SQSURL returns the transport URL of an SQS queue URL
+func SQSURL(queueURL string) string {
+	return "sqs://" + strings.TrimPrefix(queueURL, "https://")
+}
+
+// SQSEndpointURL returns the transport URL of a queue of an SQS compatible
+// service at endpoint, eg. a local emulator
+func SQSEndpointURL(queueURL, region, endpoint string) string {
+	query := url.Values{}
+	query.Set("region", region)
+	query.Set("endpoint", endpoint)
+	queueURL = strings.TrimPrefix(strings.TrimPrefix(queueURL, "https://"), "http://")
+	return "sqs://" + queueURL + "?" + query.Encode()
+}
+
+func newSQSTransport(u *url.URL, o options) *sqsTransport {
+	config := aws.NewConfig()
+	if region := sqsRegion(u); region != "" {
+		config = config.WithRegion(region)
+	}
+	queueURL := *u
+	queueURL.Scheme = "https"
+	queueURL.RawQuery = ""
+	if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+		config = config.WithEndpoint(endpoint)
+		if e, err := url.Parse(endpoint); err == nil && e.Scheme != "" {
+			queueURL.Scheme = e.Scheme
+		}
+	}
+	if o.session == nil {
+		o.session = session.New()
+	}
+	return &sqsTransport{sqs.New(o.session, config), queueURL.String()}
+}
+
+// sqsRegion returns the region given as query parameter or the region in the
+// host name of the queue, eg. sqs.eu-west-1.amazonaws.com or
+// eu-west-1.queue.amazonaws.com
+func sqsRegion(u *url.URL) string {
+	if region := u.Query().Get("region"); region != "" {
+		return region
+	}
+	parts := strings.Split(u.Hostname(), ".")
+	if len(parts) < 3 {
+		return ""
+	}
+	if parts[0] == "sqs" {
+		return parts[1]
+	}
+	if parts[1] == "queue" {
+		return parts[0]
+	}
+	return ""
+}
+
+func newSQSSender(u *url.URL, o options) (Sender, error) {
+	return newSQSTransport(u, o), nil
+}
+
+func newSQSReceiver(u *url.URL, o options) (Receiver, error) {
+	return newSQSTransport(u, o), nil
+}
+
+// SendResult adds a result to the queue
+func (t *sqsTransport) SendResult(result api.RunnerResult) error {
+	message, err := Encode(result)
+	if err != nil {
+		return err
+	}
+	_, err = t.Client.SendMessage(&sqs.SendMessageInput{
+		MessageBody:            aws.String(string(message)),
+		MessageGroupId:         aws.String("goad-lambda"),
+		MessageDeduplicationId: aws.String(uuid.NewV4().String()),
+		QueueUrl:               aws.String(t.QueueURL),
+	})
+	return err
+}
+
+// Receive results, or timeout in 1 second
+func (t *sqsTransport) Receive() []*api.RunnerResult {
+	resp, err := t.Client.ReceiveMessage(&sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(t.QueueURL),
+		MaxNumberOfMessages: aws.Int64(MaxBatchSize),
+		VisibilityTimeout:   aws.Int64(1),
+		WaitTimeSeconds:     aws.Int64(int64(ReceiveTimeout.Seconds())),
+	})
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil
+	}
+	if len(resp.Messages) == 0 {
+		return nil
+	}
+
+	results := make([]*api.RunnerResult, 0)
+	deleteEntries := make([]*sqs.DeleteMessageBatchRequestEntry, 0)
+	for _, item := range resp.Messages {
+		result, err := Decode([]byte(*item.Body))
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil
+		}
+		deleteEntries = append(deleteEntries, &sqs.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(*item.MessageId),
+			ReceiptHandle: aws.String(*item.ReceiptHandle),
+		})
+		results = append(results, result)
+	}
+
+	_, err = t.Client.DeleteMessageBatch(&sqs.DeleteMessageBatchInput{
+		Entries:  deleteEntries,
+		QueueUrl: aws.String(t.QueueURL),
+	})
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil
+	}
+	return results
+}
+
+// Close does nothing, the queue is removed by the infrastructure
+func (t *sqsTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,160 @@
+// Package transport delivers the results of the runners to the cli. The
+// scheme of the URL selects the transport: sqs:// for an SQS queue (the queue
+// URL with https replaced), amqp:// for RabbitMQ, mem:// for runners in the
+// same process and http:// for a receiver listening on the given address.
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/goadapp/goad/api"
+)
+
+const (
+	// ReceiveTimeout is how long Receive waits for results
+	ReceiveTimeout = time.Second
+	// MaxBatchSize is the maximum number of results returned by Receive
+	MaxBatchSize = 10
+)
+
+// Sender delivers the results of a runner
+type Sender interface {
+	SendResult(api.RunnerResult) error
+	Close() error
+}
+
+// Receiver collects the results sent by the runners
+type Receiver interface {
+	// Receive returns the next batch of results, or nil if none arrived
+	// within the ReceiveTimeout
+	Receive() []*api.RunnerResult
+	Close() error
+}
+
+type transport struct {
+	newSender   func(*url.URL, options) (Sender, error)
+	newReceiver func(*url.URL, options) (Receiver, error)
+}
+
+type options struct {
+	session client.ConfigProvider
+}
+
+// Option configures a sender or receiver, transports ignore options that do
+// not apply to them
+type Option func(*options)
+
+// WithAWSSession creates the clients of transports on AWS services from the
+// session, eg. to use the credentials of a profile
+func WithAWSSession(session client.ConfigProvider) Option {
+	return func(o *options) {
+		o.session = session
+	}
+}
+
+func applyOptions(opts []Option) options {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// transports by URL scheme, a new transport only needs an entry here
+var transports = map[string]transport{
+	"sqs":   {newSQSSender, newSQSReceiver},
+	"amqp":  {newAMQPSender, newAMQPReceiver},
+	"mem":   {newMemSender, newMemReceiver},
+	"http":  {newHTTPSender, newHTTPReceiver},
+	"https": {newHTTPSender, newHTTPReceiver},
+}
+
+func lookup(rawURL string) (transport, *url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return transport{}, nil, err
+	}
+	t, ok := transports[u.Scheme]
+	if !ok {
+		return transport{}, nil, fmt.Errorf("no transport for %q", rawURL)
+	}
+	return t, u, nil
+}
+
+// NewSender returns the sender for the transport selected by the URL
+func NewSender(rawURL string, opts ...Option) (Sender, error) {
+	t, u, err := lookup(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return t.newSender(u, applyOptions(opts))
+}
+
+// NewReceiver returns the receiver for the transport selected by the URL
+func NewReceiver(rawURL string, opts ...Option) (Receiver, error) {
+	t, u, err := lookup(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return t.newReceiver(u, applyOptions(opts))
+}
+
+// Encode returns the JSON message for a result
+func Encode(result api.RunnerResult) ([]byte, error) {
+	return json.Marshal(result)
+}
+
+// Decode reads a result from a JSON message
+func Decode(message []byte) (*api.RunnerResult, error) {
+	result := &api.RunnerResult{
+		Statuses: make(map[string]int),
+	}
+	err := json.Unmarshal(message, result)
+	return result, err
+}
+
+// receiveBatch waits up to the ReceiveTimeout for a message and decodes it
+// together with the messages that are already waiting
+func receiveBatch(messages <-chan []byte) []*api.RunnerResult {
+	timeout := time.NewTimer(ReceiveTimeout)
+	defer timeout.Stop()
+	var batch [][]byte
+	select {
+	case message, ok := <-messages:
+		if !ok {
+			return nil
+		}
+		batch = append(batch, message)
+	case <-timeout.C:
+		return nil
+	}
+	for len(batch) < MaxBatchSize {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				return decodeAll(batch)
+			}
+			batch = append(batch, message)
+		default:
+			return decodeAll(batch)
+		}
+	}
+	return decodeAll(batch)
+}
+
+func decodeAll(messages [][]byte) []*api.RunnerResult {
+	results := make([]*api.RunnerResult, 0, len(messages))
+	for _, message := range messages {
+		result, err := Decode(message)
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+		results = append(results, result)
+	}
+	return results
+}
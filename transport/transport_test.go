@@ -0,0 +1,108 @@
+package transport
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/goadapp/goad/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnknownScheme(t *testing.T) {
+	_, err := NewSender("ftp://example.com/results")
+	assert.Error(t, err)
+	_, err = NewReceiver("results")
+	assert.Error(t, err)
+}
+
+func TestMemRoundTrip(t *testing.T) {
+	receiver, err := NewReceiver("mem://round-trip")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer receiver.Close()
+	_, err = NewReceiver("mem://round-trip")
+	assert.Error(t, err, "a queue has a single receiver")
+
+	sender, err := NewSender("mem://round-trip")
+	if !assert.NoError(t, err) {
+		return
+	}
+	go func() {
+		for i := 0; i < 3; i++ {
+			sender.SendResult(api.RunnerResult{RunnerID: i, RequestCount: 10})
+		}
+	}()
+	received := 0
+	for received < 3 {
+		results := receiver.Receive()
+		if !assert.NotNil(t, results, "results should arrive within the timeout") {
+			return
+		}
+		for _, result := range results {
+			assert.Equal(t, 10, result.RequestCount)
+		}
+		received += len(results)
+	}
+}
+
+func TestMemSenderAfterClose(t *testing.T) {
+	_, err := NewSender("mem://unknown")
+	assert.Error(t, err)
+
+	receiver, _ := NewReceiver("mem://closed")
+	sender, _ := NewSender("mem://closed")
+	receiver.Close()
+	assert.NoError(t, sender.SendResult(api.RunnerResult{}), "results are dropped once the receiver is closed")
+}
+
+func TestHTTPRoundTrip(t *testing.T) {
+	receiver, err := NewReceiver("http://127.0.0.1:0/results")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer receiver.Close()
+	addr := receiver.(*httpReceiver).Addr()
+
+	sender, _ := NewSender(fmt.Sprintf("http://%s/results", addr))
+	errs := make(chan error)
+	go func() {
+		errs <- sender.SendResult(api.RunnerResult{RunnerID: 2, Statuses: map[string]int{"200": 5}})
+	}()
+	results := receiver.Receive()
+	assert.NoError(t, <-errs)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, 2, results[0].RunnerID)
+		assert.Equal(t, 5, results[0].Statuses["200"])
+	}
+}
+
+func TestSQSURL(t *testing.T) {
+	assert.Equal(t, "sqs://sqs.eu-west-1.amazonaws.com/123/goad", SQSURL("https://sqs.eu-west-1.amazonaws.com/123/goad"))
+
+	regions := map[string]string{
+		"sqs://sqs.eu-west-1.amazonaws.com/123/goad":            "eu-west-1",
+		"sqs://us-east-1.queue.amazonaws.com/123/goad":          "us-east-1",
+		"sqs://localhost:9324/queue/goad?region=ap-northeast-1": "ap-northeast-1",
+		"sqs://localhost:9324/queue/goad":                       "",
+	}
+	for raw, region := range regions {
+		u, _ := url.Parse(raw)
+		assert.Equal(t, region, sqsRegion(u), raw)
+	}
+
+	u, _ := url.Parse("sqs://sqs.eu-west-1.amazonaws.com/123/goad")
+	assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/123/goad", newSQSTransport(u, options{}).QueueURL)
+}
+
+func TestSQSEndpointURL(t *testing.T) {
+	raw := SQSEndpointURL("http://localhost:4566/000000000000/goad", "us-east-1", "http://localhost:4566")
+	assert.Equal(t, "sqs://localhost:4566/000000000000/goad?endpoint=http%3A%2F%2Flocalhost%3A4566&region=us-east-1", raw)
+
+	u, _ := url.Parse(raw)
+	sqsTransport := newSQSTransport(u, options{})
+	assert.Equal(t, "http://localhost:4566/000000000000/goad", sqsTransport.QueueURL)
+	assert.Equal(t, "http://localhost:4566", sqsTransport.Client.Endpoint)
+	assert.Equal(t, "us-east-1", sqsRegion(u))
+}
@@ -91,6 +91,8 @@ func serveResults(w http.ResponseWriter, r *http.Request) {
 		Timeout:     timeout,
 		Regions:     regions,
 		Method:      "GET",
+		AbortPolicy: types.DefaultAbortPolicy,
+		Function:    types.DefaultFunctionConfig,
 	}
 
 	testerr := config.Check()
@@ -106,8 +108,17 @@ func serveResults(w http.ResponseWriter, r *http.Request) {
 	}
 	defer c.Close()
 
-	resultChan, teardown := goad.Start(config)
-	defer teardown()
+	resultChan, errChan, teardown := goad.Start(config)
+	// the runners are stopped once nobody watches anymore, the remaining
+	// results are drained so receiving them ends
+	defer func() {
+		teardown()
+		for range resultChan {
+		}
+		if err := <-errChan; err != nil {
+			log.Println(err)
+		}
+	}()
 
 	for result := range resultChan {
 		message, jsonerr := jsonFromRegionsAggData(result)